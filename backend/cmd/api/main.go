@@ -8,15 +8,22 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	apimiddleware "github.com/leak-streaming/leak-streaming/backend/internal/api/middleware"
 	"github.com/leak-streaming/leak-streaming/backend/internal/api/router"
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
 	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/config"
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/database"
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/logger"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/telemetry"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
 	movieservice "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/watermark"
 )
 
 func main() {
@@ -30,6 +37,8 @@ func main() {
 	}
 
 	log := logger.New(cfg.Env)
+	// Handlers without a logger of their own report through the default.
+	slog.SetDefault(log)
 
 	telemetryShutdown := func(context.Context) error { return nil }
 	if shutdown, err := telemetry.Setup(ctx, cfg.Telemetry, log); err != nil {
@@ -69,14 +78,99 @@ func main() {
 
 	repo := repository.NewMovieRepository(db)
 	var tokenSigner movieservice.TokenSigner
-	if redisClient != nil {
+	switch {
+	case cfg.Stream.TokenKeys != "":
+		keyring, err := movieservice.ParseTokenKeyring(cfg.Stream.TokenKeys)
+		if err != nil {
+			log.Error("invalid STREAM_TOKEN_KEYS", "error", err)
+			os.Exit(1)
+		}
+		tokenSigner = movieservice.NewHMACTokenSigner(keyring)
+	case redisClient != nil:
 		tokenSigner = movieservice.NewRedisTokenSigner(redisClient)
-	} else {
-		tokenSigner = movieservice.NewInMemoryTokenSigner()
+	default:
+		memorySigner := movieservice.NewInMemoryTokenSigner()
+		go memorySigner.RunJanitor(ctx, time.Minute)
+		tokenSigner = memorySigner
+	}
+	serviceOpts := []movieservice.Option{
+		movieservice.WithUpstream(upstream.New(upstreamConfig(cfg.Stream))),
+		movieservice.WithMaxPlaylistBytes(cfg.Stream.MaxPlaylistBytes),
+	}
+	if cfg.Stream.SegmentCache.Enabled {
+		segmentCache, err := cache.NewSegmentCache(cfg.Stream.SegmentCache)
+		if err != nil {
+			log.Warn("failed to initialize segment cache", "error", err)
+		} else {
+			serviceOpts = append(serviceOpts, movieservice.WithSegmentCache(segmentCache))
+		}
+	}
+	if cfg.Stream.PlaylistCache.Enabled {
+		serviceOpts = append(serviceOpts, movieservice.WithPlaylistCache(cache.NewPlaylistCache(cfg.Stream.PlaylistCache)))
+	}
+	if cfg.Stream.CaptionCache.Enabled {
+		serviceOpts = append(serviceOpts, movieservice.WithCaptionCache(cache.NewCaptionCache(cfg.Stream.CaptionCache)))
+	}
+	if cfg.Stream.ReferenceSecret == "" {
+		log.Warn("STREAM_REFERENCE_SECRET not set, playlist references will not survive restarts or work across replicas")
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithReferenceSigner(
+		movieservice.NewReferenceSigner([]byte(cfg.Stream.ReferenceSecret), cfg.Stream.ReferenceTTL),
+	))
+	if cfg.Stream.SecretKey == "" {
+		log.Warn("STREAM_SECRET_KEY not set, sealed upstream headers will not be readable after a restart")
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithSecretBox(secrets.NewBox([]byte(cfg.Stream.SecretKey))))
+	if cfg.Stream.ContentKeyMaster == "" {
+		log.Warn("STREAM_CONTENT_KEY_MASTER not set, stored content keys will not be readable after a restart")
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithKeyManager(keys.NewManager(
+		repository.NewContentKeyRepository(db),
+		secrets.NewBox([]byte(cfg.Stream.ContentKeyMaster)),
+	)))
+	if cfg.Stream.WatermarkSecret == "" {
+		log.Warn("STREAM_WATERMARK_SECRET not set, watermarked sessions will not be decodable after a restart")
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithWatermark(
+		watermark.NewMarker([]byte(cfg.Stream.WatermarkSecret)),
+		repository.NewWatermarkRepository(db),
+	))
+	serviceOpts = append(serviceOpts, movieservice.WithStreamChecks(repository.NewStreamCheckRepository(db)))
+	renditionPolicies, err := movieservice.ParseRenditionPolicies(cfg.Stream.RenditionPolicies)
+	if err != nil {
+		log.Warn("ignoring malformed STREAM_RENDITION_POLICIES entries", "error", err)
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithRenditionPolicies(renditionPolicies))
+	serviceOpts = append(serviceOpts, movieservice.WithSessionMaxAge(cfg.Stream.SessionMaxAge))
+	var revocations movieservice.RevocationList
+	switch {
+	case redisClient != nil:
+		revocations = movieservice.NewRedisRevocationList(redisClient)
+	case cfg.Stream.TokenKeys != "":
+		// Signed tokens are accepted by every replica, so revocations kept
+		// by one of them would let the tokens play on through the others.
+		log.Error("STREAM_TOKEN_KEYS requires redis to share playback token revocations")
+		os.Exit(1)
+	default:
+		revocations = movieservice.NewInMemoryRevocationList()
+	}
+	serviceOpts = append(serviceOpts, movieservice.WithRevocations(revocations, repository.NewRevocationRepository(db)))
+	movieService := movieservice.NewService(repo, tokenSigner, cfg.Stream.TokenTTL, serviceOpts...)
+
+	if cfg.Stream.SourceCheckInterval > 0 {
+		go runStreamChecks(ctx, log, movieService, cfg.Stream.SourceCheckInterval)
 	}
-	movieService := movieservice.NewService(repo, tokenSigner, cfg.Stream.TokenTTL)
 
-	server := router.NewServer(cfg, log, redisClient, movieService)
+	adminTokens, err := apimiddleware.ParseAdminTokens(cfg.Admin.Tokens)
+	if err != nil {
+		log.Error("invalid ADMIN_API_TOKENS", "error", err)
+		os.Exit(1)
+	}
+	if len(adminTokens) == 0 {
+		log.Warn("ADMIN_API_TOKENS not set, the admin API refuses every request")
+	}
+
+	server := router.NewServer(cfg, log, redisClient, movieService, adminTokens)
 
 	go func() {
 		log.Info("api server starting", "addr", cfg.HTTP.Address())
@@ -97,3 +191,47 @@ func main() {
 
 	log.Info("api server stopped gracefully")
 }
+
+// runStreamChecks health checks every stream source each interval until ctx
+// is done.
+func runStreamChecks(ctx context.Context, log *slog.Logger, movieService *movieservice.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checks, err := movieService.CheckStreamSources(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warn("stream source check failed", "error", err)
+		}
+		for _, check := range checks {
+			if check.Status == movies.ValidationInvalid {
+				log.Warn("stream source unhealthy", "movie", check.Slug, "url", check.StreamURL, "reason", check.Reason)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// upstreamConfig maps the upstream settings of cfg onto the fetcher's.
+func upstreamConfig(cfg config.StreamConfig) upstream.Config {
+	return upstream.Config{
+		ConnectTimeout:      cfg.UpstreamConnectTimeout,
+		TTFBTimeout:         cfg.UpstreamTTFBTimeout,
+		TotalTimeout:        cfg.UpstreamTotalTimeout,
+		MaxConnsPerHost:     cfg.UpstreamMaxConnsPerHost,
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.UpstreamIdleConnTimeout,
+		MaxRetries:          cfg.UpstreamMaxRetries,
+		RetryBaseDelay:      cfg.UpstreamRetryBaseDelay,
+		RetryMaxDelay:       cfg.UpstreamRetryMaxDelay,
+		BreakerThreshold:    cfg.UpstreamBreakerThreshold,
+		BreakerCooldown:     cfg.UpstreamBreakerCooldown,
+		BlockingTimeout:     cfg.UpstreamBlockingTimeout,
+		AllowedNetworks:     cfg.UpstreamAllowedNetworks,
+	}
+}
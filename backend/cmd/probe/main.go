@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/config"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/database"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+	movieservice "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+// probe re-reads the upstream playlists of the given movies, or of every
+// movie with a stream source (hidden and scheduled ones included) without
+// arguments, and stores the stream metadata found.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	db, err := database.Connect(ctx, cfg.Database)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+	defer db.Close()
+
+	movieService := movieservice.NewService(
+		repository.NewMovieRepository(db),
+		movieservice.NewInMemoryTokenSigner(),
+		cfg.Stream.TokenTTL,
+		movieservice.WithUpstream(upstream.New(upstreamConfig(cfg.Stream))),
+		movieservice.WithMaxPlaylistBytes(cfg.Stream.MaxPlaylistBytes),
+		movieservice.WithSecretBox(secrets.NewBox([]byte(cfg.Stream.SecretKey))),
+	)
+
+	slugs := os.Args[1:]
+	if len(slugs) == 0 {
+		slugs, err = movieService.StreamSlugs(ctx)
+		if err != nil {
+			log.Fatalf("failed to list movies: %v", err)
+		}
+	}
+
+	failed := 0
+	for _, slug := range slugs {
+		metadata, err := movieService.ReprobeMovie(ctx, slug)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Fatalf("probe interrupted: %v", ctx.Err())
+			}
+			failed++
+			fmt.Printf("%s: probe failed: %v\n", slug, err)
+			continue
+		}
+		fmt.Printf("%s: %d min, %d renditions, %d tracks, encryption %s\n",
+			slug, metadata.DurationMinutes(), len(metadata.Renditions), len(metadata.Media), metadata.Encryption)
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d probes failed", failed, len(slugs))
+	}
+}
+
+// upstreamConfig maps the upstream settings of cfg onto the fetcher's.
+func upstreamConfig(cfg config.StreamConfig) upstream.Config {
+	return upstream.Config{
+		ConnectTimeout:      cfg.UpstreamConnectTimeout,
+		TTFBTimeout:         cfg.UpstreamTTFBTimeout,
+		TotalTimeout:        cfg.UpstreamTotalTimeout,
+		MaxConnsPerHost:     cfg.UpstreamMaxConnsPerHost,
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.UpstreamIdleConnTimeout,
+		MaxRetries:          cfg.UpstreamMaxRetries,
+		RetryBaseDelay:      cfg.UpstreamRetryBaseDelay,
+		RetryMaxDelay:       cfg.UpstreamRetryMaxDelay,
+		BreakerThreshold:    cfg.UpstreamBreakerThreshold,
+		BreakerCooldown:     cfg.UpstreamBreakerCooldown,
+		BlockingTimeout:     cfg.UpstreamBlockingTimeout,
+		AllowedNetworks:     cfg.UpstreamAllowedNetworks,
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const minAdminTokenBytes = 16
+
+// AdminTokens maps the bearer tokens of the admin API to the name of the
+// admin each one identifies.
+type AdminTokens map[string]string
+
+// ParseAdminTokens parses comma separated name:token pairs. Tokens must be at
+// least 16 bytes; an empty string yields no admins.
+func ParseAdminTokens(raw string) (AdminTokens, error) {
+	tokens := make(AdminTokens)
+	if strings.TrimSpace(raw) == "" {
+		return tokens, nil
+	}
+	for i, entry := range strings.Split(raw, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		switch {
+		case !ok || name == "":
+			return nil, fmt.Errorf("admin token %d: expected name:token", i+1)
+		case len(token) < minAdminTokenBytes:
+			return nil, fmt.Errorf("admin token of %q: must be at least %d bytes", name, minAdminTokenBytes)
+		case tokens[token] != "":
+			return nil, fmt.Errorf("admin token of %q: already used by %q", name, tokens[token])
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
+
+type adminContextKey struct{}
+
+// AdminAuth only lets requests with the bearer token of an admin through and
+// records who they are for AdminFromContext. Without admins every request is
+// refused.
+func AdminAuth(tokens AdminTokens) func(http.Handler) http.Handler {
+	digests := make(map[[sha256.Size]byte]string, len(tokens))
+	for token, name := range tokens {
+		digests[sha256.Sum256([]byte(token))] = name
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || presented == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// Compare against every token so the time taken does not tell
+			// how close a guess was.
+			digest := sha256.Sum256([]byte(presented))
+			var admin string
+			for candidate, name := range digests {
+				if subtle.ConstantTimeCompare(digest[:], candidate[:]) == 1 {
+					admin = name
+				}
+			}
+			if admin == "" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey{}, admin)))
+		})
+	}
+}
+
+// AdminFromContext returns the admin authenticated by AdminAuth, or an empty
+// string outside the admin API.
+func AdminFromContext(ctx context.Context) string {
+	admin, _ := ctx.Value(adminContextKey{}).(string)
+	return admin
+}
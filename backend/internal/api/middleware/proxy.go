@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type trustedProxyContextKey struct{}
+
+// TrustedProxies resolves the client of requests relayed by the reverse
+// proxies in trusted. Their X-Forwarded-For is read from the right, the end
+// the proxies append to, and RemoteAddr is replaced by the first address not
+// in trusted; entries further left were sent by the client and are ignored.
+// Such requests are also marked for ViaTrustedProxy, so headers set by the
+// gateway can be believed. Requests from any other peer are left as they
+// are.
+func TrustedProxies(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := netip.ParseAddrPort(r.RemoteAddr)
+			if err != nil || !isTrusted(peer.Addr().Unmap()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := peer.Addr().Unmap()
+			forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(forwarded) - 1; i >= 0; i-- {
+				entry := strings.TrimSpace(forwarded[i])
+				if entry == "" {
+					continue
+				}
+				addr, err := netip.ParseAddr(entry)
+				if err != nil {
+					break
+				}
+				client = addr.Unmap()
+				if !isTrusted(client) {
+					break
+				}
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), trustedProxyContextKey{}, true))
+			r.RemoteAddr = netip.AddrPortFrom(client, 0).String()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ViaTrustedProxy reports whether the request was relayed by a proxy trusted
+// by TrustedProxies.
+func ViaTrustedProxy(ctx context.Context) bool {
+	via, _ := ctx.Value(trustedProxyContextKey{}).(bool)
+	return via
+}
@@ -5,7 +5,6 @@ import (
 	"net"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +30,9 @@ func RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
 	if cfg.Window <= 0 {
 		cfg.Window = time.Minute
 	}
+	if cfg.KeyExtractor == nil {
+		cfg.KeyExtractor = clientIP
+	}
 
 	limiterStore := newLimiterStore(cfg)
 
@@ -68,6 +70,9 @@ func RateLimitRedis(client *redis.Client, cfg RateLimitConfig) func(http.Handler
 	if cfg.Burst <= 0 {
 		cfg.Burst = cfg.RequestsPerMinute
 	}
+	if cfg.KeyExtractor == nil {
+		cfg.KeyExtractor = clientIP
+	}
 
 	limiter := newRedisLimiter(client, cfg)
 
@@ -198,13 +203,9 @@ func (l *redisLimiter) redisKey(key string) string {
 	return "rate:limiter:" + key
 }
 
+// clientIP returns the address of the client, which TrustedProxies resolves
+// for requests relayed by a trusted proxy.
 func clientIP(r *http.Request) string {
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		parts := strings.Split(xForwardedFor, ",")
-		return strings.TrimSpace(parts[0])
-	}
-
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return ""
@@ -0,0 +1,38 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+type SegmentCacheStatsHandler struct {
+	service *service.Service
+}
+
+func NewSegmentCacheStatsHandler(service *service.Service) *SegmentCacheStatsHandler {
+	return &SegmentCacheStatsHandler{service: service}
+}
+
+func (h *SegmentCacheStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats, enabled := h.service.SegmentCacheStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(segmentCacheStatsResponse{
+		Enabled:           enabled,
+		SegmentCacheStats: stats,
+	})
+}
+
+type segmentCacheStatsResponse struct {
+	Enabled bool `json:"enabled"`
+	cache.SegmentCacheStats
+}
@@ -0,0 +1,55 @@
+package movies
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+// CaptionHandler serves a movie's caption tracks as WebVTT to holders of a
+// playback token, so caption origins stay hidden like the stream's.
+type CaptionHandler struct {
+	service *service.Service
+}
+
+func NewCaptionHandler(service *service.Service) *CaptionHandler {
+	return &CaptionHandler{service: service}
+}
+
+func (h *CaptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	lang := chi.URLParam(r, "lang")
+	token := r.URL.Query().Get("token")
+	if slug == "" || lang == "" || token == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
+	if err != nil {
+		writeResolveError(w, err)
+		return
+	}
+
+	body, err := h.service.FetchCaption(r.Context(), streamAccess, lang)
+	switch {
+	case errors.Is(err, service.ErrCaptionNotFound):
+		http.Error(w, "caption not found", http.StatusNotFound)
+		return
+	case err != nil:
+		writeUpstreamError(w, err, "failed to fetch captions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(body)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
 	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
 )
 
@@ -61,9 +62,15 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		AvailabilityEnd:   payload.AvailabilityEnd,
 		IsVisible:         isVisible,
 		StreamURL:         payload.StreamURL,
+		MirrorURLs:        payload.MirrorURLs,
 		DRMKeyID:          payload.DRMKeyID,
+		ContentKey:        payload.ContentKey,
+		EncryptSegments:   payload.EncryptSegments,
+		WatermarkURL:      payload.WatermarkURL,
 		AllowedHosts:      payload.AllowedHosts,
+		UpstreamHeaders:   payload.UpstreamHeaders,
 		Captions:          inputs,
+		TokenBinding:      payload.TokenBinding.binding(),
 	})
 	if err != nil {
 		var validationErr service.ValidationError
@@ -96,9 +103,34 @@ type createMovieRequest struct {
 	AvailabilityEnd   string               `json:"availabilityEnd"`
 	IsVisible         *bool                `json:"isVisible"`
 	StreamURL         string               `json:"streamUrl"`
+	MirrorURLs        []string             `json:"mirrorUrls"`
 	DRMKeyID          string               `json:"drmKeyId"`
+	ContentKey        string               `json:"contentKey"`
+	EncryptSegments   bool                 `json:"encryptSegments"`
+	WatermarkURL      string               `json:"watermarkUrl"`
 	AllowedHosts      []string             `json:"allowedHosts"`
+	UpstreamHeaders   map[string]string    `json:"upstreamHeaders"`
 	Captions          []createCaptionInput `json:"captions"`
+	TokenBinding      *createTokenBinding  `json:"tokenBinding"`
+}
+
+type createTokenBinding struct {
+	IPv4Prefix int  `json:"ipv4Prefix"`
+	IPv6Prefix int  `json:"ipv6Prefix"`
+	UserAgent  bool `json:"userAgent"`
+}
+
+// binding returns the requested token binding, or the default one when the
+// request has none.
+func (b *createTokenBinding) binding() domain.TokenBinding {
+	if b == nil {
+		return domain.DefaultTokenBinding
+	}
+	return domain.TokenBinding{
+		IPv4Prefix: b.IPv4Prefix,
+		IPv6Prefix: b.IPv6Prefix,
+		UserAgent:  b.UserAgent,
+	}
 }
 
 type createCaptionInput struct {
@@ -118,3 +150,14 @@ func writeJSONError(w http.ResponseWriter, status int, message string, details m
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(response)
 }
+
+// writeJSONErrorCode writes an error with a stable code clients can switch on
+// next to the human-readable message.
+func writeJSONErrorCode(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  code,
+	})
+}
@@ -0,0 +1,109 @@
+package movies
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/dash"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+// DASHManifestHandler serves the MPD of a DASH stream with its BaseURL,
+// SegmentTemplate and SegmentList URLs pointed at the segment proxy.
+type DASHManifestHandler struct {
+	service *service.Service
+}
+
+func NewDASHManifestHandler(service *service.Service) *DASHManifestHandler {
+	return &DASHManifestHandler{service: service}
+}
+
+func (h *DASHManifestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	token := r.URL.Query().Get("token")
+	if slug == "" || token == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
+	if err != nil {
+		writeResolveError(w, err)
+		return
+	}
+	if !streamAccess.IsDASH() {
+		http.Error(w, "stream is not available as DASH", http.StatusNotFound)
+		return
+	}
+
+	manifestURL, err := url.Parse(streamAccess.URL)
+	if err != nil {
+		http.Error(w, "invalid upstream url", http.StatusBadGateway)
+		return
+	}
+
+	doc, err := h.service.FetchDASHManifest(r.Context(), streamAccess, manifestURL.String())
+	if err != nil {
+		writeUpstreamError(w, err, "failed to fetch stream")
+		return
+	}
+
+	doc.Rewrite(manifestURL, dashProxy{
+		playlistRewriter: newPlaylistRewriter(h.service, streamAccess, manifestURL, slug, token),
+	})
+
+	w.Header().Set("Content-Type", "application/dash+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	doc.Encode(w)
+}
+
+// dashProxy builds segment proxy URLs for MPD rewriting. Templated URLs are
+// signed as a whole and carry their identifiers as v0, v1, ... parameters,
+// left unescaped so the player substitutes them like in the original
+// template.
+type dashProxy struct {
+	playlistRewriter
+}
+
+func (p dashProxy) Resource(target *url.URL) string {
+	return p.proxyURL("segment", p.sign("segment", target.String()))
+}
+
+func (p dashProxy) Template(target string, identifiers []string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "/movies/%s/segment?token=%s&tpl=%s",
+		p.slug, url.QueryEscape(p.token), url.QueryEscape(p.sign("segment-template", target)))
+	for i, identifier := range identifiers {
+		fmt.Fprintf(&builder, "&v%d=%s", i, identifier)
+	}
+	return builder.String()
+}
+
+// resolveTemplateReference opens a signed SegmentTemplate and fills in the
+// identifier values the player sent.
+func resolveTemplateReference(svc *service.Service, streamAccess service.StreamAccess, tpl string, query url.Values) (*url.URL, int, error) {
+	template, err := svc.OpenReference(streamAccess, "segment-template", tpl)
+	if err != nil {
+		return nil, http.StatusForbidden, err
+	}
+
+	var values []string
+	for i := 0; query.Has("v" + strconv.Itoa(i)); i++ {
+		values = append(values, query.Get("v"+strconv.Itoa(i)))
+	}
+	target, err := dash.ExpandTemplate(template, values)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return resolveTarget(streamAccess, target)
+}
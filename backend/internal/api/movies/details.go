@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -56,22 +57,56 @@ type movieResponse struct {
 	AvailabilityEnd   string         `json:"availabilityEnd"`
 	IsVisible         bool           `json:"isVisible"`
 	Captions          []captionModel `json:"captions"`
+	DurationMinutes   int            `json:"durationMinutes,omitempty"`
+	StreamMetadata    *metadataModel `json:"streamMetadata,omitempty"`
 }
 
 type captionModel struct {
 	LanguageCode string `json:"languageCode"`
 	Label        string `json:"label"`
 	CaptionURL   string `json:"captionUrl"`
+	Proxied      bool   `json:"proxied,omitempty"`
+}
+
+type metadataModel struct {
+	DurationSeconds float64          `json:"durationSeconds"`
+	Renditions      []renditionModel `json:"renditions"`
+	Media           []mediaModel     `json:"media"`
+	Encryption      string           `json:"encryption"`
+	ProbedAt        string           `json:"probedAt"`
+}
+
+type renditionModel struct {
+	Bandwidth  int64  `json:"bandwidth"`
+	Resolution string `json:"resolution,omitempty"`
+	Codecs     string `json:"codecs,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+	Subtitles  string `json:"subtitles,omitempty"`
+}
+
+type mediaModel struct {
+	Type     string `json:"type"`
+	GroupID  string `json:"groupId"`
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Default  bool   `json:"default"`
 }
 
 func movieResponseFromDomain(movie domain.Movie) movieResponse {
 	captions := make([]captionModel, 0, len(movie.Captions))
 	for _, c := range movie.Captions {
-		captions = append(captions, captionModel{
+		caption := captionModel{
 			LanguageCode: c.LanguageCode,
 			Label:        c.Label,
 			CaptionURL:   c.CaptionURL,
-		})
+		}
+		// Proxied captions are only reachable with a playback token, which
+		// the player appends to this path.
+		if service.CaptionProxied(c) {
+			caption.CaptionURL = "/movies/" + url.PathEscape(movie.Slug) + "/captions/" + url.PathEscape(c.LanguageCode)
+			caption.Proxied = true
+		}
+		captions = append(captions, caption)
 	}
 
 	response := movieResponse{
@@ -89,6 +124,22 @@ func movieResponseFromDomain(movie domain.Movie) movieResponse {
 	if !movie.AvailabilityEnd.IsZero() {
 		response.AvailabilityEnd = movie.AvailabilityEnd.Format(time.RFC3339)
 	}
+	if metadata := movie.Metadata; metadata != nil {
+		response.DurationMinutes = metadata.DurationMinutes()
+		response.StreamMetadata = &metadataModel{
+			DurationSeconds: metadata.Duration.Seconds(),
+			Renditions:      make([]renditionModel, 0, len(metadata.Renditions)),
+			Media:           make([]mediaModel, 0, len(metadata.Media)),
+			Encryption:      metadata.Encryption,
+			ProbedAt:        metadata.ProbedAt.Format(time.RFC3339),
+		}
+		for _, rendition := range metadata.Renditions {
+			response.StreamMetadata.Renditions = append(response.StreamMetadata.Renditions, renditionModel(rendition))
+		}
+		for _, media := range metadata.Media {
+			response.StreamMetadata.Media = append(response.StreamMetadata.Media, mediaModel(media))
+		}
+	}
 
 	return response
 }
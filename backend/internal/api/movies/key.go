@@ -0,0 +1,117 @@
+package movies
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+const maxKeyBytes = 4 << 10
+
+// KeyHandler proxies encryption keys referenced by #EXT-X-KEY and
+// #EXT-X-SESSION-KEY. Keys are never cacheable by the client.
+type KeyHandler struct {
+	service *service.Service
+}
+
+func NewKeyHandler(service *service.Service) *KeyHandler {
+	return &KeyHandler{service: service}
+}
+
+func (h *KeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	token := r.URL.Query().Get("token")
+	ref := r.URL.Query().Get("ref")
+	if slug == "" || token == "" || ref == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
+	if err != nil {
+		writeResolveError(w, err)
+		return
+	}
+
+	targetURL, status, err := resolveReference(h.service, streamAccess, "key", ref)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	resp, err := h.service.FetchUpstream(r.Context(), streamAccess, http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		writeUpstreamError(w, err, "failed to fetch key")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+
+	key, err := io.ReadAll(io.LimitReader(resp.Body, maxKeyBytes))
+	if err != nil {
+		http.Error(w, "failed to read key", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(key)
+}
+
+// ContentKeyHandler is the built-in AES-128 key server for streams with a
+// DRM key ID. The key is only released to a valid playback token for the
+// movie it belongs to.
+type ContentKeyHandler struct {
+	service *service.Service
+}
+
+func NewContentKeyHandler(service *service.Service) *ContentKeyHandler {
+	return &ContentKeyHandler{service: service}
+}
+
+func (h *ContentKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	token := r.URL.Query().Get("token")
+	if slug == "" || token == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
+	if err != nil {
+		writeResolveError(w, err)
+		return
+	}
+
+	key, err := h.service.ContentKey(r.Context(), streamAccess)
+	if errors.Is(err, service.ErrNoContentKey) {
+		http.Error(w, "stream has no content key", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(key)
+}
@@ -4,13 +4,17 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
 	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/watermark"
 )
 
 type ManifestHandler struct {
@@ -34,65 +38,337 @@ func (h *ManifestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token)
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
 	if err != nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeResolveError(w, err)
+		return
+	}
+	if streamAccess.IsDASH() {
+		http.Error(w, "stream is not available as HLS", http.StatusNotFound)
 		return
 	}
 
-	resp, err := http.Get(streamAccess.URL) //nolint:gosec
+	baseURL, err := url.Parse(streamAccess.URL)
 	if err != nil {
-		http.Error(w, "failed to fetch stream", http.StatusBadGateway)
+		http.Error(w, "invalid upstream url", http.StatusBadGateway)
+		return
+	}
+
+	servePlaylist(w, r, h.service, streamAccess, baseURL, slug, token)
+}
+
+// VariantHandler proxies the media playlists referenced by a multivariant
+// playlist so that their segment URIs are rewritten as well.
+type VariantHandler struct {
+	service *service.Service
+}
+
+func NewVariantHandler(service *service.Service) *VariantHandler {
+	return &VariantHandler{service: service}
+}
+
+func (h *VariantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	slug := chi.URLParam(r, "slug")
+	token := r.URL.Query().Get("token")
+	ref := r.URL.Query().Get("ref")
+	if slug == "" || token == "" || ref == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
 		return
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
 	if err != nil {
-		http.Error(w, "failed to read manifest", http.StatusBadGateway)
+		writeResolveError(w, err)
 		return
 	}
 
-	baseURL, err := url.Parse(streamAccess.URL)
+	targetURL, status, err := resolveReference(h.service, streamAccess, "variant.m3u8", ref)
 	if err != nil {
-		http.Error(w, "invalid upstream url", http.StatusBadGateway)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	servePlaylist(w, r, h.service, streamAccess, targetURL, slug, token)
+}
+
+func servePlaylist(w http.ResponseWriter, r *http.Request, svc *service.Service, access service.StreamAccess, playlistURL *url.URL, slug, token string) {
+	reload := url.Values{}
+	for _, key := range service.BlockingReloadParams {
+		if value := r.URL.Query().Get(key); value != "" {
+			reload.Set(key, value)
+		}
+	}
+
+	playlist, err := svc.FetchPlaylist(r.Context(), access, playlistURL.String(), reload)
+	if err != nil {
+		writeUpstreamError(w, err, "failed to fetch stream")
 		return
 	}
+	playlist = svc.FilterRenditions(access, playlist)
 
-	rewritten := rewriteManifest(string(data), baseURL, slug, token)
+	rewriter := newPlaylistRewriter(svc, access, playlistURL, slug, token)
+	if access.Watermarked() && !playlist.Master {
+		// Without a usable B variant the session gets plain A segments
+		// rather than an error.
+		if variant, variantURL, err := svc.WatermarkVariant(r.Context(), access, playlistURL.String(), reload); err == nil && !variant.Master {
+			rewriter.watermark = newWatermarkVariant(access.WatermarkPattern, variant, variantURL)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
 	w.Header().Set("Cache-Control", "no-store")
-	io.WriteString(w, rewritten)
+	if err := rewriter.rewrite(w, playlist); err != nil {
+		// The status is already sent; this is usually the player going away.
+		slog.WarnContext(r.Context(), "failed to write playlist", "slug", slug, "path", r.URL.Path, "error", err)
+	}
+}
+
+// uriTagEndpoints maps tags that carry a URI attribute to the proxy endpoint
+// serving that kind of resource.
+var uriTagEndpoints = map[string]string{
+	"#EXT-X-KEY":                "key",
+	"#EXT-X-SESSION-KEY":        "key",
+	"#EXT-X-MAP":                "segment",
+	"#EXT-X-PART":               "segment",
+	"#EXT-X-PRELOAD-HINT":       "segment",
+	"#EXT-X-SESSION-DATA":       "segment",
+	"#EXT-X-MEDIA":              "variant.m3u8",
+	"#EXT-X-I-FRAME-STREAM-INF": "variant.m3u8",
+	"#EXT-X-RENDITION-REPORT":   "variant.m3u8",
+}
+
+// playlistRewriter points the URIs of one upstream playlist back at the
+// proxy for a single playback session.
+type playlistRewriter struct {
+	base  *url.URL
+	slug  string
+	token string
+	sign  func(endpoint, target string) string
+	// contentKey is set for streams whose AES-128 keys are served by the
+	// built-in key server.
+	contentKey bool
+	// encrypt is set for streams whose clear segments the proxy encrypts.
+	encrypt bool
+	// watermark is set for media playlists of watermarked streams.
+	watermark *watermarkVariant
+	// renditionReports is unset for sessions whose rendition policy the
+	// reported renditions might break.
+	renditionReports bool
+}
+
+// watermarkVariant holds the B variant of a media playlist for A/B
+// watermarking: the session pattern and the absolute URL of each B segment
+// by media sequence number.
+type watermarkVariant struct {
+	pattern  uint64
+	segments map[int64]string
+}
+
+func newWatermarkVariant(pattern uint64, playlist hls.Playlist, playlistURL *url.URL) *watermarkVariant {
+	variant := &watermarkVariant{pattern: pattern, segments: make(map[int64]string)}
+	sequence := playlist.FirstListedSequence()
+	for _, line := range playlist.Lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if rel, err := url.Parse(trimmed); err == nil {
+			variant.segments[sequence] = playlistURL.ResolveReference(rel).String()
+		}
+		sequence++
+	}
+	return variant
+}
+
+func newPlaylistRewriter(svc *service.Service, access service.StreamAccess, base *url.URL, slug, token string) playlistRewriter {
+	return playlistRewriter{
+		base:  base,
+		slug:  slug,
+		token: token,
+		sign: func(endpoint, target string) string {
+			return svc.SignReference(access, endpoint, target)
+		},
+		contentKey:       access.HasContentKey(),
+		encrypt:          access.EncryptsSegments(),
+		renditionReports: access.RenditionReports(),
+	}
 }
 
-func rewriteManifest(manifest string, base *url.URL, slug, token string) string {
-	var builder strings.Builder
-	scanner := bufio.NewScanner(strings.NewReader(manifest))
-	for scanner.Scan() {
-		line := scanner.Text()
+// rewrite writes the playlist to w line by line with every URI pointed back
+// at the proxy. URI lines following #EXT-X-STREAM-INF are variant playlists
+// and are routed to the variant endpoint, other URI lines are media segments,
+// and URI attributes of the tags in uriTagEndpoints go to the endpoint for
+// their resource kind.
+//
+// Rendition reports are dropped unless the session may play every rendition.
+//
+// For watermarked streams each segment comes from the A or B variant picked
+// by the session pattern.
+//
+// For streams with segment encryption, clear media playlists get an
+// #EXT-X-KEY pointing at the key server right after #EXTM3U and their
+// segments are routed through encryptedSegmentURI.
+func (rw playlistRewriter) rewrite(w io.Writer, playlist hls.Playlist) error {
+	out := bufio.NewWriter(w)
+	encrypt := rw.encrypt && encryptable(playlist)
+	sequence := playlist.FirstListedSequence()
+	expectVariant := false
+	for i, line := range playlist.Lines {
 		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			rel, err := url.Parse(trimmed)
-			if err == nil {
-				resolved := base.ResolveReference(rel)
-				backendURL := url.URL{
-					Path: fmt.Sprintf("/movies/%s/segment", slug),
-				}
-				q := backendURL.Query()
-				q.Set("token", token)
-				q.Set("target", resolved.String())
-				backendURL.RawQuery = q.Encode()
-				line = backendURL.String()
+		switch {
+		case strings.HasPrefix(trimmed, "#EXT-X-STREAM-INF"):
+			expectVariant = true
+		case strings.HasPrefix(trimmed, "#EXT-X-RENDITION-REPORT") && !rw.renditionReports:
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			line = rw.rewriteTagURI(line)
+		case trimmed != "":
+			var (
+				rewritten string
+				ok        bool
+			)
+			switch {
+			case expectVariant:
+				rewritten, ok = rw.rewriteURI(trimmed, "variant.m3u8")
+			case encrypt:
+				rewritten, ok = rw.encryptedSegmentURI(rw.watermarkURI(trimmed, sequence), uint64(sequence))
+				sequence++
+			default:
+				rewritten, ok = rw.rewriteURI(rw.watermarkURI(trimmed, sequence), "segment")
+				sequence++
+			}
+			if ok {
+				line = rewritten
 			}
+			expectVariant = false
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+		if i == 0 && encrypt {
+			fmt.Fprintf(out, "#EXT-X-KEY:METHOD=AES-128,URI=\"%s\"\n", rw.contentKeyURL())
+		}
+	}
+	return out.Flush()
+}
+
+// watermarkURI returns the URI of the variant the session receives for the
+// segment with the given media sequence number. Segments missing from the B
+// playlist stay on A.
+func (rw playlistRewriter) watermarkURI(raw string, sequence int64) string {
+	if rw.watermark == nil || watermark.VariantFor(rw.watermark.pattern, sequence) != watermark.VariantB {
+		return raw
+	}
+	if variant, ok := rw.watermark.segments[sequence]; ok {
+		return variant
+	}
+	return raw
+}
+
+// encryptable reports whether the proxy can encrypt the segments of a media
+// playlist: they must be clear, whole-resource MPEG-TS or packed audio
+// segments, since AES-128 also covers the EXT-X-MAP of fMP4 streams and
+// applies per resource rather than per byte range or partial segment.
+func encryptable(playlist hls.Playlist) bool {
+	if playlist.Master {
+		return false
+	}
+	for _, line := range playlist.Lines {
+		name, _ := hls.SplitTag(strings.TrimSpace(line))
+		switch name {
+		case "#EXT-X-KEY", "#EXT-X-MAP", "#EXT-X-BYTERANGE", "#EXT-X-PART", "#EXT-X-PRELOAD-HINT":
+			return false
+		}
+	}
+	return true
+}
+
+func (rw playlistRewriter) rewriteTagURI(line string) string {
+	name, value := hls.SplitTag(strings.TrimSpace(line))
+	endpoint, ok := uriTagEndpoints[name]
+	if !ok || value == "" {
+		return line
+	}
+
+	attrs, err := hls.ParseAttributeList(value)
+	if err != nil {
+		return line
+	}
+	if endpoint == "key" && rw.contentKey {
+		if method, _ := attrs.Get("METHOD"); method == "AES-128" {
+			return name + ":" + attrs.Set("URI", rw.contentKeyURL(), true).String()
 		}
-		builder.WriteString(line)
-		builder.WriteByte('\n')
 	}
-	return builder.String()
+	uri, ok := attrs.Get("URI")
+	if !ok {
+		return line
+	}
+	rewritten, ok := rw.rewriteURI(uri, endpoint)
+	if !ok {
+		return line
+	}
+	return name + ":" + attrs.Set("URI", rewritten, true).String()
+}
+
+// rewriteURI resolves raw against the playlist URL and returns the proxied
+// form. Non-HTTP URIs such as skd:// or data: are left untouched.
+func (rw playlistRewriter) rewriteURI(raw, endpoint string) (string, bool) {
+	resolved, ok := rw.resolve(raw)
+	if !ok {
+		return "", false
+	}
+	return rw.proxyURL(endpoint, rw.sign(endpoint, resolved.String())), true
+}
+
+// encryptedSegmentURI is rewriteURI for segments the proxy encrypts. The
+// media sequence number that selects the IV is signed together with the URL,
+// so the segment can neither be fetched in the clear nor under another IV.
+func (rw playlistRewriter) encryptedSegmentURI(raw string, sequence uint64) (string, bool) {
+	resolved, ok := rw.resolve(raw)
+	if !ok {
+		return "", false
+	}
+	ref := rw.sign(encryptedSegmentKind, strconv.FormatUint(sequence, 10)+" "+resolved.String())
+	return rw.endpointURL("segment", "eref", ref), true
+}
+
+func (rw playlistRewriter) resolve(raw string) (*url.URL, bool) {
+	rel, err := url.Parse(raw)
+	if err != nil {
+		return nil, false
+	}
+	resolved := rw.base.ResolveReference(rel)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return nil, false
+	}
+	return resolved, true
+}
+
+// contentKeyURL points at the built-in key server, which only needs the
+// playback token to find the movie's key.
+func (rw playlistRewriter) contentKeyURL() string {
+	backendURL := url.URL{
+		Path:     fmt.Sprintf("/movies/%s/content-key", rw.slug),
+		RawQuery: url.Values{"token": {rw.token}}.Encode(),
+	}
+	return backendURL.String()
+}
+
+func (rw playlistRewriter) proxyURL(endpoint, ref string) string {
+	return rw.endpointURL(endpoint, "ref", ref)
+}
+
+func (rw playlistRewriter) endpointURL(endpoint, param, ref string) string {
+	backendURL := url.URL{
+		Path: fmt.Sprintf("/movies/%s/%s", rw.slug, endpoint),
+	}
+	q := backendURL.Query()
+	q.Set("token", rw.token)
+	q.Set(param, ref)
+	backendURL.RawQuery = q.Encode()
+	return backendURL.String()
 }
@@ -0,0 +1,162 @@
+package movies
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apimiddleware "github.com/leak-streaming/leak-streaming/backend/internal/api/middleware"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+// RevokeHandler revokes playback tokens after a leak: a single token with its
+// session, every token of a viewer or every token of a movie. Revocations are
+// audited under the admin authenticated by the admin API.
+type RevokeHandler struct {
+	service *service.Service
+}
+
+func NewRevokeHandler(service *service.Service) *RevokeHandler {
+	return &RevokeHandler{service: service}
+}
+
+func (h *RevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "service unavailable", nil)
+		return
+	}
+
+	var payload revocationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+	reason := strings.TrimSpace(payload.Reason)
+	actor := apimiddleware.AdminFromContext(r.Context())
+
+	var (
+		revocation repository.TokenRevocation
+		err        error
+	)
+	switch service.RevocationScope(payload.Scope) {
+	case service.RevokeToken:
+		if payload.Movie == "" || payload.Token == "" {
+			writeJSONError(w, http.StatusBadRequest, "movie and token are required", nil)
+			return
+		}
+		revocation, err = h.service.RevokePlaybackToken(r.Context(), payload.Movie, payload.Token, reason, actor)
+	case service.RevokeViewer:
+		revocation, err = h.service.RevokeViewerTokens(r.Context(), payload.ViewerID, reason, actor)
+	case service.RevokeMovie:
+		if payload.Movie == "" {
+			writeJSONError(w, http.StatusBadRequest, "movie is required", nil)
+			return
+		}
+		revocation, err = h.service.RevokeMovieTokens(r.Context(), payload.Movie, reason, actor)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "scope must be token, viewer or movie", nil)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMovieNotFound):
+			writeJSONError(w, http.StatusNotFound, "movie not found", nil)
+		case errors.Is(err, service.ErrInvalidToken):
+			writeJSONError(w, http.StatusUnprocessableEntity, "not a playback token of this movie", nil)
+		case errors.Is(err, service.ErrMissingViewerID):
+			writeJSONError(w, http.StatusBadRequest, "viewerId is required", nil)
+		case errors.Is(err, service.ErrRevocationNotAudited):
+			writeJSONError(w, http.StatusInternalServerError, "tokens revoked, but the audit record could not be written", nil)
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "failed to revoke tokens", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(revocationResponseFromRecord(revocation))
+}
+
+// RevocationsHandler lists the audit trail of revocations, newest first; the
+// limit query parameter caps how many are returned.
+type RevocationsHandler struct {
+	service *service.Service
+}
+
+func NewRevocationsHandler(service *service.Service) *RevocationsHandler {
+	return &RevocationsHandler{service: service}
+}
+
+func (h *RevocationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "service unavailable", nil)
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive number", nil)
+			return
+		}
+		limit = parsed
+	}
+
+	revocations, err := h.service.Revocations(r.Context(), limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to load revocations", nil)
+		return
+	}
+
+	response := revocationsResponse{Revocations: make([]revocationResponse, 0, len(revocations))}
+	for _, revocation := range revocations {
+		response.Revocations = append(response.Revocations, revocationResponseFromRecord(revocation))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+type revocationRequest struct {
+	Scope    string `json:"scope"`
+	Movie    string `json:"movie"`
+	Token    string `json:"token"`
+	ViewerID string `json:"viewerId"`
+	Reason   string `json:"reason"`
+}
+
+type revocationsResponse struct {
+	Revocations []revocationResponse `json:"revocations"`
+}
+
+type revocationResponse struct {
+	ID        int64     `json:"id"`
+	Scope     string    `json:"scope"`
+	MovieID   string    `json:"movieId,omitempty"`
+	ViewerID  string    `json:"viewerId,omitempty"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+func revocationResponseFromRecord(revocation repository.TokenRevocation) revocationResponse {
+	return revocationResponse{
+		ID:        revocation.ID,
+		Scope:     revocation.Scope,
+		MovieID:   revocation.MovieID,
+		ViewerID:  revocation.ViewerID,
+		SessionID: revocation.SessionID,
+		Reason:    revocation.Reason,
+		Actor:     revocation.Actor,
+		RevokedAt: revocation.RevokedAt,
+	}
+}
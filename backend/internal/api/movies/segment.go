@@ -1,14 +1,23 @@
 package movies
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/captions"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/dash"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
 	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
 )
 
 type SegmentHandler struct {
@@ -27,85 +36,270 @@ func (h *SegmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	slug := chi.URLParam(r, "slug")
 	token := r.URL.Query().Get("token")
-	target := r.URL.Query().Get("target")
-	if slug == "" || token == "" || target == "" {
+	ref := r.URL.Query().Get("ref")
+	tpl := r.URL.Query().Get("tpl")
+	eref := r.URL.Query().Get("eref")
+	if slug == "" || token == "" || (ref == "" && tpl == "" && eref == "") {
 		http.Error(w, "missing parameters", http.StatusBadRequest)
 		return
 	}
 
-	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token)
+	streamAccess, err := h.service.ResolveStream(r.Context(), slug, token, viewerFromRequest(r))
 	if err != nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		writeResolveError(w, err)
 		return
 	}
 
-	baseURL, err := url.Parse(streamAccess.URL)
-	if err != nil {
-		http.Error(w, "invalid base url", http.StatusBadRequest)
+	if eref != "" {
+		h.serveEncryptedSegment(w, r, streamAccess, eref)
 		return
 	}
 
-	targetURL, err := url.Parse(target)
+	var (
+		targetURL *url.URL
+		status    int
+	)
+	if tpl != "" {
+		targetURL, status, err = resolveTemplateReference(h.service, streamAccess, tpl, r.URL.Query())
+	} else {
+		targetURL, status, err = resolveReference(h.service, streamAccess, "segment", ref)
+	}
 	if err != nil {
-		http.Error(w, "invalid target", http.StatusBadRequest)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	if !targetURL.IsAbs() {
-		targetURL = baseURL.ResolveReference(targetURL)
+	// Range and HEAD requests are answered from the cache when the whole
+	// segment is already there, and passed through otherwise so a seek never
+	// waits on a full download.
+	if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+		if entry, ok := h.service.CachedSegment(targetURL.String()); ok {
+			serveCachedSegment(w, r, entry, true)
+			return
+		}
+		h.proxySegment(w, r, streamAccess, targetURL)
+		return
 	}
 
-	targetHost := targetURL.Hostname()
-	if !isAllowedHost(targetHost, streamAccess.AllowedHosts) {
-		http.Error(w, "forbidden host", http.StatusForbidden)
-		return
+	entry, hit, err := h.service.FetchSegment(r.Context(), streamAccess, targetURL.String())
+	switch {
+	case err == nil:
+		serveCachedSegment(w, r, entry, hit)
+	case errors.Is(err, service.ErrSegmentNotCacheable):
+		var oversized *service.OversizedSegmentError
+		if errors.As(err, &oversized) && oversized.Response != nil {
+			writeProxiedSegment(w, r, oversized.Response)
+			return
+		}
+		h.proxySegment(w, r, streamAccess, targetURL)
+	default:
+		writeUpstreamError(w, err, "failed to fetch segment")
 	}
+}
 
-	if targetURL.Scheme != baseURL.Scheme {
-		http.Error(w, "forbidden host", http.StatusForbidden)
-		return
+func (h *SegmentHandler) proxySegment(w http.ResponseWriter, r *http.Request, streamAccess service.StreamAccess, targetURL *url.URL) {
+	upstreamHeader := make(http.Header)
+	for _, key := range forwardedRequestHeaders {
+		if value := r.Header.Get(key); value != "" {
+			upstreamHeader.Set(key, value)
+		}
 	}
 
-	resp, err := http.Get(targetURL.String()) //nolint:gosec
+	resp, err := h.service.FetchUpstream(r.Context(), streamAccess, r.Method, targetURL.String(), upstreamHeader)
 	if err != nil {
-		http.Error(w, "failed to fetch segment", http.StatusBadGateway)
+		writeUpstreamError(w, err, "failed to fetch segment")
 		return
 	}
+	writeProxiedSegment(w, r, resp)
+}
+
+// writeProxiedSegment streams an upstream segment response to the client and
+// closes it.
+func writeProxiedSegment(w http.ResponseWriter, r *http.Request, resp *http.Response) {
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+			w.Header().Set("Content-Range", contentRange)
+		}
+		http.Error(w, "range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	case resp.StatusCode >= 400:
 		http.Error(w, "upstream unavailable", http.StatusBadGateway)
 		return
 	}
 
-	for key, values := range resp.Header {
-		if key == "Content-Length" || key == "Content-Type" {
-			for _, v := range values {
-				w.Header().Add(key, v)
-			}
+	for _, key := range passthroughResponseHeaders {
+		for _, v := range resp.Header.Values(key) {
+			w.Header().Add(key, v)
 		}
 	}
+	if w.Header().Get("Accept-Ranges") == "" && resp.StatusCode == http.StatusPartialContent {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if r.Method == http.MethodHead {
+		return
+	}
 	io.Copy(w, resp.Body)
 }
 
-func isAllowedHost(host string, allowed []string) bool {
-	if host == "" {
-		return false
+// encryptedSegmentKind is the reference kind of segments the proxy encrypts.
+// Its payload is the media sequence number and the upstream URL separated by
+// a space.
+const encryptedSegmentKind = "segment-aes128"
+
+// serveEncryptedSegment answers with the whole segment encrypted under the
+// stream's content key. Range requests apply to the ciphertext.
+func (h *SegmentHandler) serveEncryptedSegment(w http.ResponseWriter, r *http.Request, streamAccess service.StreamAccess, eref string) {
+	payload, err := h.service.OpenReference(streamAccess, encryptedSegmentKind, eref)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
-	for _, entry := range allowed {
-		entry = strings.TrimSpace(entry)
-		if entry == "" {
-			continue
-		}
-		if strings.EqualFold(host, entry) {
-			return true
-		}
-		if strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry) {
-			return true
+	rawSequence, target, _ := strings.Cut(payload, " ")
+	sequence, err := strconv.ParseUint(rawSequence, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid reference", http.StatusForbidden)
+		return
+	}
+	targetURL, status, err := resolveTarget(streamAccess, target)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	encrypted, err := h.service.EncryptedSegment(r.Context(), streamAccess, targetURL.String(), sequence)
+	switch {
+	case errors.Is(err, service.ErrNoContentKey):
+		http.Error(w, "stream has no content key", http.StatusNotFound)
+		return
+	case err != nil:
+		writeUpstreamError(w, err, "failed to fetch segment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(encrypted))
+}
+
+func serveCachedSegment(w http.ResponseWriter, r *http.Request, entry cache.SegmentEntry, hit bool) {
+	if entry.ContentType != "" {
+		w.Header().Set("Content-Type", entry.ContentType)
+	}
+	if entry.ETag != "" {
+		w.Header().Set("ETag", entry.ETag)
+	}
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	var modified time.Time
+	if entry.LastModified != "" {
+		if parsed, err := http.ParseTime(entry.LastModified); err == nil {
+			modified = parsed
 		}
-		if !strings.HasPrefix(entry, ".") && strings.HasSuffix(host, "."+entry) {
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(entry.Body))
+}
+
+// forwardedRequestHeaders are the client headers needed for byte-range
+// requests (#EXT-X-BYTERANGE playlists and native player seeking).
+var forwardedRequestHeaders = []string{"Range", "If-Range"}
+
+var passthroughResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"ETag",
+	"Last-Modified",
+}
+
+// resolveReference opens a signed playlist reference issued for this session
+// and resource kind, then validates the upstream URL it carries like
+// resolveTarget. Tampered, foreign or expired references are forbidden.
+func resolveReference(svc *service.Service, streamAccess service.StreamAccess, kind, ref string) (*url.URL, int, error) {
+	target, err := svc.OpenReference(streamAccess, kind, ref)
+	if err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	return resolveTarget(streamAccess, target)
+}
+
+// resolveTarget parses a proxied target URL relative to the movie's stream URL
+// and checks it against the allowed hosts. The returned status is the HTTP
+// status to reply with when the target is rejected.
+func resolveTarget(streamAccess service.StreamAccess, target string) (*url.URL, int, error) {
+	baseURL, err := url.Parse(streamAccess.URL)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid base url")
+	}
+
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid target")
+	}
+
+	if !targetURL.IsAbs() {
+		targetURL = baseURL.ResolveReference(targetURL)
+	}
+
+	if !upstream.HostAllowed(targetURL.Hostname(), streamAccess.AllowedHosts) {
+		return nil, http.StatusForbidden, errors.New("forbidden host")
+	}
+
+	if !sourceScheme(streamAccess, targetURL.Scheme) {
+		return nil, http.StatusForbidden, errors.New("forbidden host")
+	}
+
+	return targetURL, http.StatusOK, nil
+}
+
+// sourceScheme reports whether one of the stream sources uses scheme, so a
+// target cannot downgrade an https stream to plain http.
+func sourceScheme(streamAccess service.StreamAccess, scheme string) bool {
+	for _, source := range streamAccess.Sources {
+		if sourceURL, err := url.Parse(source); err == nil && sourceURL.Scheme == scheme {
 			return true
 		}
 	}
 	return false
 }
+
+// writeUpstreamError reports a failed upstream fetch as JSON with a
+// machine-readable code the player can show.
+func writeUpstreamError(w http.ResponseWriter, err error, message string) {
+	status, code := http.StatusBadGateway, "upstream_error"
+	var statusErr service.UpstreamStatusError
+	switch {
+	case errors.Is(err, upstream.ErrCircuitOpen):
+		status, code, message = http.StatusServiceUnavailable, "upstream_unavailable", "upstream unavailable"
+	case errors.Is(err, upstream.ErrForbiddenDestination):
+		code, message = "upstream_forbidden_destination", "forbidden upstream destination"
+	case errors.Is(err, hls.ErrNotPlaylist):
+		code, message = "upstream_not_hls", "upstream did not return an HLS playlist"
+	case errors.Is(err, dash.ErrNotMPD):
+		code, message = "upstream_not_dash", "upstream did not return a DASH manifest"
+	case errors.Is(err, hls.ErrPlaylistTooLarge), errors.Is(err, dash.ErrManifestTooLarge):
+		code, message = "upstream_too_large", "upstream playlist exceeds the size limit"
+	case errors.Is(err, hls.ErrInvalidTag):
+		code, message = "upstream_invalid_playlist", "upstream playlist is malformed"
+	case errors.Is(err, captions.ErrMalformed):
+		code, message = "upstream_invalid_captions", err.Error()
+	case errors.Is(err, captions.ErrTooLarge):
+		code, message = "upstream_too_large", "upstream caption file exceeds the size limit"
+	case errors.As(err, &statusErr):
+		code = "upstream_status"
+	}
+	writeJSONErrorCode(w, status, code, message)
+}
@@ -0,0 +1,81 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+// StreamChecksHandler lists the latest health check of every stream source
+// so dead links can be fixed before viewers run into them.
+type StreamChecksHandler struct {
+	service *service.Service
+}
+
+func NewStreamChecksHandler(service *service.Service) *StreamChecksHandler {
+	return &StreamChecksHandler{service: service}
+}
+
+func (h *StreamChecksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "service unavailable", nil)
+		return
+	}
+
+	status := domain.ValidationStatus(strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("status"))))
+	switch status {
+	case "", domain.ValidationValid, domain.ValidationInvalid, domain.ValidationUnknown:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "status must be VALID, INVALID or UNKNOWN", nil)
+		return
+	}
+
+	checks, err := h.service.StreamChecks(r.Context(), status)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to load stream checks", nil)
+		return
+	}
+
+	response := streamChecksResponse{Sources: make([]streamCheckResponse, 0, len(checks))}
+	for _, check := range checks {
+		item := streamCheckResponse{
+			MovieID:   check.MovieID,
+			Slug:      check.Slug,
+			Title:     check.Title,
+			StreamURL: check.StreamURL,
+			Priority:  check.Priority,
+			Status:    string(check.Status),
+			Reason:    check.Reason,
+		}
+		if !check.CheckedAt.IsZero() {
+			checkedAt := check.CheckedAt
+			latency := check.Latency.Milliseconds()
+			item.CheckedAt, item.LatencyMS = &checkedAt, &latency
+		}
+		response.Sources = append(response.Sources, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+type streamChecksResponse struct {
+	Sources []streamCheckResponse `json:"sources"`
+}
+
+type streamCheckResponse struct {
+	MovieID   string     `json:"movieId"`
+	Slug      string     `json:"slug"`
+	Title     string     `json:"title"`
+	StreamURL string     `json:"streamUrl"`
+	Priority  int        `json:"priority"`
+	Status    string     `json:"status"`
+	LatencyMS *int64     `json:"latencyMs,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+}
@@ -3,11 +3,14 @@ package movies
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	apimiddleware "github.com/leak-streaming/leak-streaming/backend/internal/api/middleware"
 	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
 )
 
@@ -42,9 +45,7 @@ func (h *StreamTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	viewerID := viewerIDFromRequest(r)
-
-	token, err := h.service.CreatePlaybackToken(ctx, movie, viewerID)
+	token, err := h.service.CreatePlaybackToken(ctx, movie, viewerFromRequest(r))
 	if err != nil {
 		if errors.Is(err, service.ErrMovieUnavailable) {
 			http.Error(w, "movie unavailable", http.StatusConflict)
@@ -54,22 +55,108 @@ func (h *StreamTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeStreamToken(w, token)
+}
+
+// StreamTokenRefreshHandler renews the playback session of a token, see
+// service.RefreshPlaybackToken. Players call it as a heartbeat.
+type StreamTokenRefreshHandler struct {
+	service *service.Service
+}
+
+func NewStreamTokenRefreshHandler(service *service.Service) *StreamTokenRefreshHandler {
+	return &StreamTokenRefreshHandler{service: service}
+}
+
+func (h *StreamTokenRefreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<10)).Decode(&payload); err != nil || slug == "" || payload.Token == "" {
+		http.Error(w, "missing parameters", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.service.RefreshPlaybackToken(r.Context(), slug, payload.Token, viewerFromRequest(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMovieNotFound):
+			http.Error(w, "movie not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrMovieUnavailable):
+			http.Error(w, "movie unavailable", http.StatusConflict)
+		default:
+			writeResolveError(w, err)
+		}
+		return
+	}
+	writeStreamToken(w, token)
+}
+
+type streamTokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt,omitzero"`
+	SessionExpiresAt time.Time `json:"sessionExpiresAt,omitzero"`
+}
+
+func writeStreamToken(w http.ResponseWriter, token service.PlaybackToken) {
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	json.NewEncoder(w).Encode(streamTokenResponse{
-		Token: token,
+		Token:            token.Token,
+		ExpiresAt:        token.ExpiresAt,
+		SessionExpiresAt: token.SessionExpiresAt,
 	})
 }
 
-type streamTokenResponse struct {
-	Token string `json:"token"`
+// viewerFromRequest returns the viewer a playback token is issued to, and
+// checked against on every stream request.
+func viewerFromRequest(r *http.Request) service.Viewer {
+	return service.Viewer{
+		ID:        viewerIDFromRequest(r),
+		Tier:      viewerTierFromRequest(r),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
 }
 
-func viewerIDFromRequest(r *http.Request) string {
-	if id := r.Header.Get("X-Viewer-ID"); id != "" {
-		return id
+// writeResolveError answers a stream request whose token ResolveStream
+// refused, with a code telling the player how to recover: refresh an expired
+// token, request a new one once the session is over or the token was
+// refreshed elsewhere, or give up.
+func writeResolveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTokenRevoked):
+		writeJSONErrorCode(w, http.StatusForbidden, "token_revoked", "playback token revoked")
+	case errors.Is(err, service.ErrTokenBindingMismatch):
+		writeJSONErrorCode(w, http.StatusForbidden, "token_binding_mismatch", "playback token was issued to another viewer")
+	case errors.Is(err, service.ErrTokenExpired):
+		writeJSONErrorCode(w, http.StatusUnauthorized, "token_expired", "playback token expired")
+	case errors.Is(err, service.ErrSessionExpired):
+		writeJSONErrorCode(w, http.StatusUnauthorized, "session_expired", "playback session expired")
+	case errors.Is(err, service.ErrTokenSuperseded):
+		writeJSONErrorCode(w, http.StatusUnauthorized, "token_superseded", "playback token was replaced by a refresh")
+	case errors.Is(err, service.ErrRevocationsUnavailable):
+		writeJSONErrorCode(w, http.StatusServiceUnavailable, "revocations_unavailable", "playback token could not be checked, try again")
+	default:
+		writeJSONErrorCode(w, http.StatusUnauthorized, "token_invalid", "unauthorized")
 	}
-	if cookie, err := r.Cookie("viewer_id"); err == nil {
-		return cookie.Value
+}
+
+// viewerIDFromRequest returns the viewer ID the gateway sets after
+// authenticating the viewer, on requests relayed by a trusted proxy. Anyone
+// else is identified by their address, which leaves telling viewers apart to
+// the movie's IP prefix binding.
+func viewerIDFromRequest(r *http.Request) string {
+	if apimiddleware.ViaTrustedProxy(r.Context()) {
+		if id := strings.TrimSpace(r.Header.Get("X-Viewer-ID")); id != "" {
+			return id
+		}
 	}
 	if ip := clientIP(r); ip != "" {
 		return "ip:" + ip
@@ -77,18 +164,23 @@ func viewerIDFromRequest(r *http.Request) string {
 	return "anonymous"
 }
 
-func clientIP(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		parts := strings.Split(forwarded, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	host := r.RemoteAddr
-	if host == "" {
+// viewerTierFromRequest returns the tier selecting the session's rendition
+// policy. It is set by the gateway in front of the API, after authenticating
+// the viewer, and only believed on requests relayed by a trusted proxy;
+// everyone else gets the default tier.
+func viewerTierFromRequest(r *http.Request) string {
+	if !apimiddleware.ViaTrustedProxy(r.Context()) {
 		return ""
 	}
-	if idx := strings.LastIndex(host, ":"); idx >= 0 {
-		return host[:idx]
+	return strings.TrimSpace(r.Header.Get("X-Viewer-Tier"))
+}
+
+// clientIP returns the address of the client, as resolved from the trusted
+// proxies' X-Forwarded-For by middleware.TrustedProxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return strings.Trim(r.RemoteAddr, "[]")
 	}
 	return host
 }
@@ -0,0 +1,99 @@
+package movies
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/watermark"
+)
+
+// WatermarkDecodeHandler traces an A/B sequence observed in a leaked copy
+// back to the playback sessions most likely to have produced it. It is an
+// admin endpoint: the matches name viewers and their sessions.
+type WatermarkDecodeHandler struct {
+	service *service.Service
+}
+
+func NewWatermarkDecodeHandler(service *service.Service) *WatermarkDecodeHandler {
+	return &WatermarkDecodeHandler{service: service}
+}
+
+func (h *WatermarkDecodeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.service == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "service unavailable", nil)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var payload watermarkDecodeRequest
+	if err := decoder.Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	observations, err := watermark.ParseObservations(payload.StartSequence, payload.Variants)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		return
+	}
+
+	matches, err := h.service.DecodeWatermark(r.Context(), slug, observations)
+	if err != nil {
+		if errors.Is(err, service.ErrMovieNotFound) {
+			writeJSONError(w, http.StatusNotFound, "movie not found", nil)
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "failed to decode watermark", nil)
+		return
+	}
+
+	response := watermarkDecodeResponse{
+		Observed: len(observations),
+		Matches:  make([]watermarkMatchResponse, 0, len(matches)),
+	}
+	for _, match := range matches {
+		response.Matches = append(response.Matches, watermarkMatchResponse{
+			SessionID:  match.SessionID,
+			ViewerID:   match.ViewerID,
+			IssuedAt:   match.IssuedAt,
+			Matched:    match.Matched,
+			Mismatched: match.Mismatched,
+			Coverage:   match.Coverage,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// watermarkDecodeRequest describes the leaked copy: Variants holds one
+// character per segment starting at StartSequence, A or B, or - when the
+// variant could not be determined.
+type watermarkDecodeRequest struct {
+	StartSequence int64  `json:"startSequence"`
+	Variants      string `json:"variants"`
+}
+
+type watermarkDecodeResponse struct {
+	Observed int                      `json:"observed"`
+	Matches  []watermarkMatchResponse `json:"matches"`
+}
+
+type watermarkMatchResponse struct {
+	SessionID  string    `json:"sessionId"`
+	ViewerID   string    `json:"viewerId,omitempty"`
+	IssuedAt   time.Time `json:"issuedAt"`
+	Matched    int       `json:"matched"`
+	Mismatched int       `json:"mismatched"`
+	Coverage   int       `json:"coverage"`
+}
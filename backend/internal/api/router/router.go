@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,18 +19,18 @@ import (
 	servicemovies "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
 )
 
-func NewServer(cfg config.Config, log *slog.Logger, redisClient *redis.Client, movieService *servicemovies.Service) *http.Server {
+func NewServer(cfg config.Config, log *slog.Logger, redisClient *redis.Client, movieService *servicemovies.Service, adminTokens apimiddleware.AdminTokens) *http.Server {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(apimiddleware.TrustedProxies(cfg.HTTP.TrustedProxies))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(cfg.HTTP.WriteTimeout))
 	r.Use(apimiddleware.SecureHeaders())
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"X-Correlation-ID"},
+		ExposedHeaders:   []string{"X-Correlation-ID", "Content-Length", "Content-Range", "Accept-Ranges"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -40,14 +39,6 @@ func NewServer(cfg config.Config, log *slog.Logger, redisClient *redis.Client, m
 		RequestsPerMinute: 120,
 		Burst:             240,
 		Window:            time.Minute,
-		KeyExtractor: func(r *http.Request) string {
-			forwarded := r.Header.Get("X-Forwarded-For")
-			if forwarded == "" {
-				return ""
-			}
-			parts := strings.Split(forwarded, ",")
-			return strings.TrimSpace(parts[0])
-		},
 	}
 	if redisClient != nil {
 		r.Use(apimiddleware.RateLimitRedis(redisClient, rateLimitCfg))
@@ -58,20 +49,44 @@ func NewServer(cfg config.Config, log *slog.Logger, redisClient *redis.Client, m
 
 	health.RegisterRoutes(r)
 
+	if movieService != nil {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(apimiddleware.AdminAuth(adminTokens))
+			r.Get("/metrics/segment-cache", apimovies.NewSegmentCacheStatsHandler(movieService).ServeHTTP)
+			r.Get("/stream-sources", apimovies.NewStreamChecksHandler(movieService).ServeHTTP)
+			r.Post("/movies/{slug}/watermark/decode", apimovies.NewWatermarkDecodeHandler(movieService).ServeHTTP)
+			r.Get("/revocations", apimovies.NewRevocationsHandler(movieService).ServeHTTP)
+			r.Post("/revocations", apimovies.NewRevokeHandler(movieService).ServeHTTP)
+		})
+	}
+
 	if movieService != nil {
 		listHandler := apimovies.NewListHandler(movieService)
 		detailsHandler := apimovies.NewDetailsHandler(movieService)
 		streamHandler := apimovies.NewStreamTokenHandler(movieService)
+		streamRefreshHandler := apimovies.NewStreamTokenRefreshHandler(movieService)
 		manifestHandler := apimovies.NewManifestHandler(movieService)
+		dashManifestHandler := apimovies.NewDASHManifestHandler(movieService)
+		variantHandler := apimovies.NewVariantHandler(movieService)
 		segmentHandler := apimovies.NewSegmentHandler(movieService)
+		keyHandler := apimovies.NewKeyHandler(movieService)
+		contentKeyHandler := apimovies.NewContentKeyHandler(movieService)
 		createHandler := apimovies.NewCreateHandler(movieService)
+		captionHandler := apimovies.NewCaptionHandler(movieService)
 		r.Route("/movies", func(r chi.Router) {
 			r.Get("/", listHandler.ServeHTTP)
 			r.Post("/", createHandler.ServeHTTP)
 			r.Get("/{slug}", detailsHandler.ServeHTTP)
 			r.Post("/{slug}/playback-token", streamHandler.ServeHTTP)
+			r.Post("/{slug}/playback-token/refresh", streamRefreshHandler.ServeHTTP)
 			r.Get("/{slug}/manifest.m3u8", manifestHandler.ServeHTTP)
+			r.Get("/{slug}/manifest.mpd", dashManifestHandler.ServeHTTP)
+			r.Get("/{slug}/variant.m3u8", variantHandler.ServeHTTP)
 			r.Get("/{slug}/segment", segmentHandler.ServeHTTP)
+			r.Head("/{slug}/segment", segmentHandler.ServeHTTP)
+			r.Get("/{slug}/key", keyHandler.ServeHTTP)
+			r.Get("/{slug}/content-key", contentKeyHandler.ServeHTTP)
+			r.Get("/{slug}/captions/{lang}", captionHandler.ServeHTTP)
 		})
 	}
 
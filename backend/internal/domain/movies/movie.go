@@ -12,9 +12,15 @@ type Movie struct {
 	AvailabilityEnd    time.Time
 	IsVisible          bool
 	StreamURL          string
+	MirrorURLs         []string
 	DRMKeyID           string
+	EncryptSegments    bool
+	WatermarkURL       string
 	Captions           []Caption
 	AllowedStreamHosts []string
+	UpstreamHeaders    []UpstreamHeader
+	Metadata           *StreamMetadata
+	TokenBinding       TokenBinding
 }
 
 type Caption struct {
@@ -23,6 +29,80 @@ type Caption struct {
 	CaptionURL   string
 }
 
+// UpstreamHeader is a request header sent to the stream origin. Secret
+// values are stored sealed and only opened when a stream is resolved.
+type UpstreamHeader struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+// TokenBinding selects what playback tokens for a movie are bound to besides
+// the viewer ID: the leading bits of the viewer's IP address and the
+// User-Agent. A zero prefix leaves addresses of that family unchecked.
+type TokenBinding struct {
+	IPv4Prefix int
+	IPv6Prefix int
+	UserAgent  bool
+}
+
+// DefaultTokenBinding is the binding of movies created without one: tokens
+// only work from the network they were issued to. The User-Agent is left
+// out, since native players fetch segments with a User-Agent of their own.
+var DefaultTokenBinding = TokenBinding{IPv4Prefix: 24, IPv6Prefix: 64}
+
+// StreamMetadata describes a stream as found by the latest probe of its
+// upstream playlists. Duration is zero for live streams.
+type StreamMetadata struct {
+	Duration   time.Duration
+	Renditions []Rendition
+	Media      []MediaRendition
+	Encryption string
+	ProbedAt   time.Time
+}
+
+// DurationMinutes returns Duration rounded up to whole minutes.
+func (m StreamMetadata) DurationMinutes() int {
+	return int((m.Duration + time.Minute - 1) / time.Minute)
+}
+
+// Rendition is one step of the stream's bitrate ladder.
+type Rendition struct {
+	Bandwidth  int64
+	Resolution string
+	Codecs     string
+	Audio      string
+	Subtitles  string
+}
+
+// MediaRendition is an alternative audio, subtitle or closed caption track.
+type MediaRendition struct {
+	Type     string
+	GroupID  string
+	Name     string
+	Language string
+	Default  bool
+}
+
+// ValidationStatus is the outcome of the latest health check of a stream
+// source.
+type ValidationStatus string
+
+const (
+	ValidationUnknown ValidationStatus = "UNKNOWN"
+	ValidationValid   ValidationStatus = "VALID"
+	ValidationInvalid ValidationStatus = "INVALID"
+)
+
+// StreamSources returns the primary stream URL followed by its mirrors, in
+// priority order.
+func (m Movie) StreamSources() []string {
+	if m.StreamURL == "" {
+		return nil
+	}
+	return append([]string{m.StreamURL}, m.MirrorURLs...)
+}
+
 func (m Movie) IsAvailable(now time.Time) bool {
 	if !m.IsVisible {
 		return false
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Per-stream request headers sent to the origin. Secret values are sealed by the API.
+ALTER TABLE movie_streams
+    ADD COLUMN upstream_headers JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- +goose Down
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS upstream_headers;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- AES-128 content keys served by the built-in key server, looked up through
+-- movie_streams.drm_key_id. Keys are sealed with the content key master key
+-- and only released for the movie they were assigned to; movie_id is NULL
+-- until the movie they were stored for has been created.
+CREATE TABLE content_keys (
+    key_id VARCHAR(128) PRIMARY KEY,
+    sealed_key TEXT NOT NULL,
+    movie_id BIGINT NULL REFERENCES movies(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS content_keys;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Encrypt clear upstream segments with the stream's content key (AES-128) while proxying.
+ALTER TABLE movie_streams
+    ADD COLUMN encrypt_segments BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS encrypt_segments;
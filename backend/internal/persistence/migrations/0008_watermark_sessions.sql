@@ -0,0 +1,24 @@
+-- +goose Up
+-- A/B forensic watermarking: the B variant of a stream mirrors the layout of
+-- stream_url, and every playback session is recorded with its bit pattern so
+-- a leaked A/B sequence can be traced back to it. Sessions are recorded by ID,
+-- never by their bearer token, so decoding a leak hands out no working token.
+ALTER TABLE movie_streams
+    ADD COLUMN watermark_url VARCHAR(1024) NULL;
+
+CREATE TABLE watermark_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    movie_id BIGINT NOT NULL REFERENCES movies(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    viewer_id VARCHAR(128) NULL,
+    pattern BIGINT NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_watermark_sessions_movie ON watermark_sessions (movie_id, issued_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS watermark_sessions;
+
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS watermark_url;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- A movie may have several stream sources: the primary (priority 0) plus
+-- mirrors with the same layout. Stream settings such as keys, headers and
+-- allowed hosts are read from the primary row.
+ALTER TABLE movie_streams
+    DROP CONSTRAINT IF EXISTS movie_streams_movie_id_key;
+
+ALTER TABLE movie_streams
+    ADD COLUMN priority INT NOT NULL DEFAULT 0;
+
+ALTER TABLE movie_streams
+    ADD CONSTRAINT movie_streams_movie_priority_key UNIQUE (movie_id, priority);
+
+-- +goose Down
+DELETE FROM movie_streams WHERE priority <> 0;
+
+ALTER TABLE movie_streams
+    DROP CONSTRAINT IF EXISTS movie_streams_movie_priority_key;
+
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS priority;
+
+ALTER TABLE movie_streams
+    ADD CONSTRAINT movie_streams_movie_id_key UNIQUE (movie_id);
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Results of the background stream source health checker.
+ALTER TABLE movie_streams
+    ADD COLUMN validation_status VARCHAR(16) NOT NULL DEFAULT 'UNKNOWN'
+        CHECK (validation_status IN ('VALID', 'INVALID', 'UNKNOWN')),
+    ADD COLUMN last_validated_at TIMESTAMPTZ NULL,
+    ADD COLUMN validation_latency_ms INT NULL,
+    ADD COLUMN validation_error TEXT NULL;
+
+CREATE INDEX idx_movie_streams_validation ON movie_streams (validation_status, last_validated_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_movie_streams_validation;
+
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS validation_error,
+    DROP COLUMN IF EXISTS validation_latency_ms,
+    DROP COLUMN IF EXISTS last_validated_at,
+    DROP COLUMN IF EXISTS validation_status;
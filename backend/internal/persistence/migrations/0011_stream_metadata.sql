@@ -0,0 +1,18 @@
+-- +goose Up
+-- Stream metadata found by probing the upstream playlists: duration on the
+-- movie, the rendition ladder, alternative tracks and encryption method on
+-- the primary stream.
+ALTER TABLE movies
+    ADD COLUMN duration_minutes INT NULL;
+
+ALTER TABLE movie_streams
+    ADD COLUMN stream_metadata JSONB NULL,
+    ADD COLUMN probed_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS probed_at,
+    DROP COLUMN IF EXISTS stream_metadata;
+
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS duration_minutes;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Bind playback tokens to the viewer's IP prefix and User-Agent (0 / FALSE = unchecked).
+-- Streams are bound to the viewer's network unless they opt out.
+ALTER TABLE movie_streams
+    ADD COLUMN bind_ipv4_prefix SMALLINT NOT NULL DEFAULT 24 CHECK (bind_ipv4_prefix BETWEEN 0 AND 32),
+    ADD COLUMN bind_ipv6_prefix SMALLINT NOT NULL DEFAULT 64 CHECK (bind_ipv6_prefix BETWEEN 0 AND 128),
+    ADD COLUMN bind_user_agent BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE movie_streams
+    DROP COLUMN IF EXISTS bind_user_agent,
+    DROP COLUMN IF EXISTS bind_ipv6_prefix,
+    DROP COLUMN IF EXISTS bind_ipv4_prefix;
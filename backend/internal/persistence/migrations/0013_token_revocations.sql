@@ -0,0 +1,18 @@
+-- +goose Up
+-- Audit trail of playback token revocations. The revocations themselves are
+-- enforced from Redis; this table records who revoked what and why.
+CREATE TABLE token_revocations (
+    id BIGSERIAL PRIMARY KEY,
+    scope VARCHAR(16) NOT NULL CHECK (scope IN ('token', 'viewer', 'movie')),
+    movie_id BIGINT NULL REFERENCES movies(id) ON DELETE SET NULL,
+    viewer_id VARCHAR(128) NULL,
+    session_id VARCHAR(255) NULL,
+    reason TEXT NULL,
+    actor VARCHAR(128) NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_token_revocations_revoked_at ON token_revocations (revoked_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS token_revocations;
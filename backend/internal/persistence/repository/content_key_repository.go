@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrContentKeyNotFound = errors.New("content key not found")
+	ErrContentKeyExists   = errors.New("content key already exists")
+	ErrContentKeyAssigned = errors.New("content key is assigned to another movie")
+)
+
+// SealedContentKey is a stored content key and the movie it is assigned to,
+// empty until AssignSealedKey.
+type SealedContentKey struct {
+	Sealed  string
+	MovieID string
+}
+
+// ContentKeyRepository stores sealed content keys by key ID. Without a
+// database the keys are kept in memory for the lifetime of the process.
+type ContentKeyRepository struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	memory map[string]SealedContentKey
+}
+
+func NewContentKeyRepository(db *sql.DB) *ContentKeyRepository {
+	return &ContentKeyRepository{db: db, memory: make(map[string]SealedContentKey)}
+}
+
+func (r *ContentKeyRepository) GetSealedKey(ctx context.Context, keyID string) (SealedContentKey, error) {
+	if r.db == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		key, ok := r.memory[keyID]
+		if !ok {
+			return SealedContentKey{}, ErrContentKeyNotFound
+		}
+		return key, nil
+	}
+
+	var (
+		key     SealedContentKey
+		movieID sql.NullInt64
+	)
+	err := r.db.QueryRowContext(ctx, `SELECT sealed_key, movie_id FROM content_keys WHERE key_id = $1`, keyID).Scan(&key.Sealed, &movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SealedContentKey{}, ErrContentKeyNotFound
+	}
+	if movieID.Valid {
+		key.MovieID = strconv.FormatInt(movieID.Int64, 10)
+	}
+	return key, err
+}
+
+// CreateSealedKey stores a new key, not assigned to a movie yet, and fails
+// with ErrContentKeyExists if the key ID is taken. Keys are never replaced,
+// since content already encrypted with the old key would become unplayable.
+func (r *ContentKeyRepository) CreateSealedKey(ctx context.Context, keyID, sealed string) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, exists := r.memory[keyID]; exists {
+			return ErrContentKeyExists
+		}
+		r.memory[keyID] = SealedContentKey{Sealed: sealed}
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO content_keys (key_id, sealed_key) VALUES ($1, $2)`, keyID, sealed)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "content_keys_pkey" {
+		return ErrContentKeyExists
+	}
+	return err
+}
+
+// AssignSealedKey assigns the key stored under keyID to movieID. A key is
+// only ever assigned to one movie: assigning it to another fails with
+// ErrContentKeyAssigned.
+func (r *ContentKeyRepository) AssignSealedKey(ctx context.Context, keyID, movieID string) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		key, ok := r.memory[keyID]
+		switch {
+		case !ok:
+			return ErrContentKeyNotFound
+		case key.MovieID != "" && key.MovieID != movieID:
+			return ErrContentKeyAssigned
+		}
+		key.MovieID = movieID
+		r.memory[keyID] = key
+		return nil
+	}
+
+	id, err := strconv.ParseInt(movieID, 10, 64)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE content_keys SET movie_id = $2
+		 WHERE key_id = $1 AND (movie_id IS NULL OR movie_id = $2)`,
+		keyID, id,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+	if _, err := r.GetSealedKey(ctx, keyID); err != nil {
+		return err
+	}
+	return ErrContentKeyAssigned
+}
+
+// DeleteUnusedSealedKey deletes the key stored under keyID unless it was
+// assigned to a movie or a stream refers to it.
+func (r *ContentKeyRepository) DeleteUnusedSealedKey(ctx context.Context, keyID string) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.memory[keyID].MovieID == "" {
+			delete(r.memory, keyID)
+		}
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM content_keys
+		 WHERE key_id = $1 AND movie_id IS NULL
+		   AND NOT EXISTS (SELECT 1 FROM movie_streams WHERE drm_key_id = $1)`,
+		keyID,
+	)
+	return err
+}
@@ -64,8 +64,8 @@ func (r *MovieRepository) CreateMovie(ctx context.Context, params CreateMoviePar
 	var movieID int64
 	insertMovieErr := tx.QueryRowContext(
 		ctx,
-		`INSERT INTO movies (slug, title, synopsis, poster_url, availability_start, availability_end, is_visible)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`INSERT INTO movies (slug, title, synopsis, poster_url, availability_start, availability_end, is_visible, duration_minutes)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		 RETURNING id`,
 		params.Slug,
 		params.Title,
@@ -74,6 +74,7 @@ func (r *MovieRepository) CreateMovie(ctx context.Context, params CreateMoviePar
 		availabilityStart,
 		availabilityEnd,
 		params.IsVisible,
+		durationMinutes(params.Metadata),
 	).Scan(&movieID)
 	if insertMovieErr != nil {
 		return movies.Movie{}, translateCreateMovieError(insertMovieErr)
@@ -85,6 +86,12 @@ func (r *MovieRepository) CreateMovie(ctx context.Context, params CreateMoviePar
 		drmKey.String = params.DRMKeyID
 	}
 
+	watermarkURL := sql.NullString{}
+	if strings.TrimSpace(params.WatermarkURL) != "" {
+		watermarkURL.Valid = true
+		watermarkURL.String = params.WatermarkURL
+	}
+
 	allowedHosts := params.AllowedHosts
 	if allowedHosts == nil {
 		allowedHosts = []string{}
@@ -94,18 +101,55 @@ func (r *MovieRepository) CreateMovie(ctx context.Context, params CreateMoviePar
 		return movies.Movie{}, err
 	}
 
+	upstreamHeadersJSON, err := marshalUpstreamHeaders(params.UpstreamHeaders)
+	if err != nil {
+		return movies.Movie{}, err
+	}
+
+	metadataJSON, err := marshalStreamMetadata(params.Metadata)
+	if err != nil {
+		return movies.Movie{}, err
+	}
+	probedAt := sql.NullTime{}
+	if params.Metadata != nil {
+		probedAt.Valid = true
+		probedAt.Time = params.Metadata.ProbedAt.UTC()
+	}
+
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO movie_streams (movie_id, stream_url, drm_key_id, allowed_hosts)
-		 VALUES ($1, $2, $3, $4)`,
+		`INSERT INTO movie_streams (movie_id, stream_url, drm_key_id, encrypt_segments, watermark_url, allowed_hosts, upstream_headers, stream_metadata, probed_at,
+		                            bind_ipv4_prefix, bind_ipv6_prefix, bind_user_agent)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
 		movieID,
 		params.StreamURL,
 		drmKey,
+		params.EncryptSegments,
+		watermarkURL,
 		allowedHostsJSON,
+		upstreamHeadersJSON,
+		metadataJSON,
+		probedAt,
+		params.TokenBinding.IPv4Prefix,
+		params.TokenBinding.IPv6Prefix,
+		params.TokenBinding.UserAgent,
 	); err != nil {
 		return movies.Movie{}, translateCreateMovieError(err)
 	}
 
+	for i, mirrorURL := range params.MirrorURLs {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO movie_streams (movie_id, stream_url, priority)
+			 VALUES ($1, $2, $3)`,
+			movieID,
+			mirrorURL,
+			i+1,
+		); err != nil {
+			return movies.Movie{}, translateCreateMovieError(err)
+		}
+	}
+
 	if len(params.Captions) > 0 {
 		for _, caption := range params.Captions {
 			if _, err := tx.ExecContext(
@@ -157,9 +201,15 @@ func (r *MovieRepository) createMovieInMemory(params CreateMovieParams) (movies.
 		PosterURL:          params.PosterURL,
 		IsVisible:          params.IsVisible,
 		StreamURL:          params.StreamURL,
+		MirrorURLs:         append([]string(nil), params.MirrorURLs...),
 		DRMKeyID:           params.DRMKeyID,
+		EncryptSegments:    params.EncryptSegments,
+		WatermarkURL:       params.WatermarkURL,
 		Captions:           append([]movies.Caption(nil), params.Captions...),
 		AllowedStreamHosts: append([]string(nil), params.AllowedHosts...),
+		UpstreamHeaders:    append([]movies.UpstreamHeader(nil), params.UpstreamHeaders...),
+		Metadata:           params.Metadata,
+		TokenBinding:       params.TokenBinding,
 	}
 	if movie.Captions == nil {
 		movie.Captions = []movies.Caption{}
@@ -206,9 +256,15 @@ type CreateMovieParams struct {
 	AvailabilityEnd   *time.Time
 	IsVisible         bool
 	StreamURL         string
+	MirrorURLs        []string
 	DRMKeyID          string
+	EncryptSegments   bool
+	WatermarkURL      string
 	AllowedHosts      []string
+	UpstreamHeaders   []movies.UpstreamHeader
 	Captions          []movies.Caption
+	Metadata          *movies.StreamMetadata
+	TokenBinding      movies.TokenBinding
 }
 
 func (r *MovieRepository) ListMovies(ctx context.Context) ([]movies.Movie, error) {
@@ -292,6 +348,47 @@ ORDER BY COALESCE(availability_start, NOW()) ASC, slug ASC;
 	return moviesList, nil
 }
 
+// ListStreamSlugs returns the slugs of every movie with a stream source,
+// including hidden movies and those outside their availability window.
+func (r *MovieRepository) ListStreamSlugs(ctx context.Context) ([]string, error) {
+	if r.db == nil {
+		slugs := make([]string, 0, len(sampleMovies))
+		for slug, movie := range sampleMovies {
+			if len(movie.StreamSources()) > 0 {
+				slugs = append(slugs, slug)
+			}
+		}
+		sort.Strings(slugs)
+		return slugs, nil
+	}
+
+	const query = `
+SELECT m.slug
+FROM movies m
+WHERE EXISTS (SELECT 1 FROM movie_streams s WHERE s.movie_id = m.id)
+ORDER BY m.slug ASC;
+`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slugs := make([]string, 0, 8)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slugs, nil
+}
+
 func (r *MovieRepository) GetMovieWithStreams(ctx context.Context, slug string) (movies.Movie, error) {
 	if r.db == nil {
 		movie, ok := sampleMovies[slug]
@@ -312,24 +409,39 @@ SELECT m.id,
        m.is_visible,
        s.stream_url,
        s.drm_key_id,
-       COALESCE(s.allowed_hosts, '[]'::jsonb)
+       COALESCE(s.encrypt_segments, FALSE),
+       s.watermark_url,
+       COALESCE(s.allowed_hosts, '[]'::jsonb),
+       COALESCE(s.upstream_headers, '[]'::jsonb),
+       s.stream_metadata,
+       s.probed_at,
+       COALESCE(s.bind_ipv4_prefix, 0),
+       COALESCE(s.bind_ipv6_prefix, 0),
+       COALESCE(s.bind_user_agent, FALSE)
 FROM movies m
 LEFT JOIN movie_streams s ON s.movie_id = m.id
 WHERE m.slug = $1
+ORDER BY s.priority
 LIMIT 1;
 `
 
 	var (
-		movieID           int64
-		title             string
-		synopsis          sql.NullString
-		posterURL         sql.NullString
-		availabilityStart sql.NullTime
-		availabilityEnd   sql.NullTime
-		isVisible         bool
-		streamURL         sql.NullString
-		drmKeyID          sql.NullString
-		allowedHostsRaw   []byte
+		movieID            int64
+		title              string
+		synopsis           sql.NullString
+		posterURL          sql.NullString
+		availabilityStart  sql.NullTime
+		availabilityEnd    sql.NullTime
+		isVisible          bool
+		streamURL          sql.NullString
+		drmKeyID           sql.NullString
+		encryptSegments    bool
+		watermarkURL       sql.NullString
+		allowedHostsRaw    []byte
+		upstreamHeadersRaw []byte
+		metadataRaw        []byte
+		probedAt           sql.NullTime
+		tokenBinding       movies.TokenBinding
 	)
 
 	row := r.db.QueryRowContext(ctx, movieQuery, slug)
@@ -344,16 +456,26 @@ LIMIT 1;
 		&isVisible,
 		&streamURL,
 		&drmKeyID,
+		&encryptSegments,
+		&watermarkURL,
 		&allowedHostsRaw,
+		&upstreamHeadersRaw,
+		&metadataRaw,
+		&probedAt,
+		&tokenBinding.IPv4Prefix,
+		&tokenBinding.IPv6Prefix,
+		&tokenBinding.UserAgent,
 	); err != nil {
 		return movies.Movie{}, err
 	}
 
 	movie := movies.Movie{
-		ID:        strconv.FormatInt(movieID, 10),
-		Slug:      slug,
-		Title:     title,
-		IsVisible: isVisible,
+		ID:              strconv.FormatInt(movieID, 10),
+		Slug:            slug,
+		Title:           title,
+		IsVisible:       isVisible,
+		EncryptSegments: encryptSegments,
+		TokenBinding:    tokenBinding,
 	}
 	if synopsis.Valid {
 		movie.Synopsis = synopsis.String
@@ -373,12 +495,41 @@ LIMIT 1;
 	if drmKeyID.Valid {
 		movie.DRMKeyID = drmKeyID.String
 	}
+	if watermarkURL.Valid {
+		movie.WatermarkURL = watermarkURL.String
+	}
 	if len(allowedHostsRaw) > 0 {
 		var hosts []string
 		if err := json.Unmarshal(allowedHostsRaw, &hosts); err == nil {
 			movie.AllowedStreamHosts = hosts
 		}
 	}
+	movie.UpstreamHeaders = unmarshalUpstreamHeaders(upstreamHeadersRaw)
+	movie.Metadata = unmarshalStreamMetadata(metadataRaw, probedAt)
+
+	const mirrorsQuery = `
+SELECT stream_url
+FROM movie_streams
+WHERE movie_id = $1 AND priority > 0
+ORDER BY priority;
+`
+
+	mirrorRows, err := r.db.QueryContext(ctx, mirrorsQuery, movieID)
+	if err != nil {
+		return movies.Movie{}, err
+	}
+	defer mirrorRows.Close()
+
+	for mirrorRows.Next() {
+		var mirrorURL string
+		if err := mirrorRows.Scan(&mirrorURL); err != nil {
+			return movies.Movie{}, err
+		}
+		movie.MirrorURLs = append(movie.MirrorURLs, mirrorURL)
+	}
+	if err := mirrorRows.Err(); err != nil {
+		return movies.Movie{}, err
+	}
 
 	const captionsQuery = `
 SELECT language_code, label, caption_url
@@ -427,6 +578,32 @@ ORDER BY language_code;
 	return movie, nil
 }
 
+type upstreamHeaderRecord struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+func marshalUpstreamHeaders(headers []movies.UpstreamHeader) ([]byte, error) {
+	records := make([]upstreamHeaderRecord, 0, len(headers))
+	for _, header := range headers {
+		records = append(records, upstreamHeaderRecord(header))
+	}
+	return json.Marshal(records)
+}
+
+func unmarshalUpstreamHeaders(raw []byte) []movies.UpstreamHeader {
+	var records []upstreamHeaderRecord
+	if len(raw) == 0 || json.Unmarshal(raw, &records) != nil {
+		return nil
+	}
+	headers := make([]movies.UpstreamHeader, 0, len(records))
+	for _, record := range records {
+		headers = append(headers, movies.UpstreamHeader(record))
+	}
+	return headers
+}
+
 func extractHost(raw string) string {
 	parsed, err := url.Parse(raw)
 	if err != nil {
@@ -449,6 +626,7 @@ var sampleMovies = map[string]movies.Movie{
 		AvailabilityStart: time.Now().Add(-24 * time.Hour).UTC(),
 		AvailabilityEnd:   time.Now().Add(24 * time.Hour).UTC(),
 		IsVisible:         true,
+		TokenBinding:      movies.DefaultTokenBinding,
 		StreamURL:         "https://main.24playerhd.com/m3u8/0378b65549cda348e910faf0/0378b65549cda348e910faf0168.m3u8", // m3u8 URL ของสตรีมมิ่ง
 		AllowedStreamHosts: []string{
 			"main.24playerhd.com",
@@ -472,6 +650,7 @@ var sampleMovies = map[string]movies.Movie{
 		AvailabilityStart: time.Now().Add(-12 * time.Hour).UTC(),
 		AvailabilityEnd:   time.Now().Add(48 * time.Hour).UTC(),
 		IsVisible:         true,
+		TokenBinding:      movies.DefaultTokenBinding,
 		StreamURL:         "https://main.24playerhd.com/m3u8/f87ff8ffe0151aec3f5d55bc/f87ff8ffe0151aec3f5d55bc168.m3u8",
 		AllowedStreamHosts: []string{
 			"main.24playerhd.com",
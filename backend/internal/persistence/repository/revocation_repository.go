@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TokenRevocation is the audit record of a playback token revocation. Scope
+// is "token", "viewer" or "movie"; the IDs it does not use are empty.
+type TokenRevocation struct {
+	ID        int64
+	Scope     string
+	MovieID   string
+	ViewerID  string
+	SessionID string
+	Reason    string
+	Actor     string
+	RevokedAt time.Time
+}
+
+// RevocationRepository records token revocations. Without a database the
+// records are kept in memory for the lifetime of the process.
+type RevocationRepository struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	memory []TokenRevocation
+}
+
+func NewRevocationRepository(db *sql.DB) *RevocationRepository {
+	return &RevocationRepository{db: db}
+}
+
+// RecordRevocation stores revocation and returns it with its ID set.
+func (r *RevocationRepository) RecordRevocation(ctx context.Context, revocation TokenRevocation) (TokenRevocation, error) {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		revocation.ID = int64(len(r.memory) + 1)
+		r.memory = append(r.memory, revocation)
+		return revocation, nil
+	}
+
+	var movieID sql.NullInt64
+	if revocation.MovieID != "" {
+		id, err := strconv.ParseInt(revocation.MovieID, 10, 64)
+		if err != nil {
+			return TokenRevocation{}, err
+		}
+		movieID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO token_revocations (scope, movie_id, viewer_id, session_id, reason, actor, revoked_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id`,
+		revocation.Scope,
+		movieID,
+		nullString(revocation.ViewerID),
+		nullString(revocation.SessionID),
+		nullString(revocation.Reason),
+		nullString(revocation.Actor),
+		revocation.RevokedAt.UTC(),
+	).Scan(&revocation.ID)
+	if err != nil {
+		return TokenRevocation{}, err
+	}
+	return revocation, nil
+}
+
+// ListRevocations returns up to limit revocations, newest first.
+func (r *RevocationRepository) ListRevocations(ctx context.Context, limit int) ([]TokenRevocation, error) {
+	if r.db == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		revocations := make([]TokenRevocation, 0, min(limit, len(r.memory)))
+		for i := len(r.memory) - 1; i >= 0 && len(revocations) < limit; i-- {
+			revocations = append(revocations, r.memory[i])
+		}
+		return revocations, nil
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, scope, movie_id, viewer_id, session_id, reason, actor, revoked_at
+		 FROM token_revocations
+		 ORDER BY revoked_at DESC, id DESC
+		 LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revocations := make([]TokenRevocation, 0)
+	for rows.Next() {
+		var (
+			revocation                         TokenRevocation
+			movieID                            sql.NullInt64
+			viewerID, sessionID, reason, actor sql.NullString
+		)
+		if err := rows.Scan(&revocation.ID, &revocation.Scope, &movieID, &viewerID, &sessionID, &reason, &actor, &revocation.RevokedAt); err != nil {
+			return nil, err
+		}
+		if movieID.Valid {
+			revocation.MovieID = strconv.FormatInt(movieID.Int64, 10)
+		}
+		revocation.ViewerID = viewerID.String
+		revocation.SessionID = sessionID.String
+		revocation.Reason = reason.String
+		revocation.Actor = actor.String
+		revocation.RevokedAt = revocation.RevokedAt.UTC()
+		revocations = append(revocations, revocation)
+	}
+	return revocations, rows.Err()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+// StreamCheck is the latest health check result of one stream source.
+type StreamCheck struct {
+	MovieID   string
+	Slug      string
+	Title     string
+	StreamURL string
+	Priority  int
+	Status    movies.ValidationStatus
+	Latency   time.Duration
+	Reason    string
+	CheckedAt time.Time
+}
+
+// StreamCheckRepository stores stream source health checks on the
+// movie_streams rows. Without a database the results are kept in memory for
+// the lifetime of the process.
+type StreamCheckRepository struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	memory map[string]StreamCheck
+}
+
+func NewStreamCheckRepository(db *sql.DB) *StreamCheckRepository {
+	return &StreamCheckRepository{db: db, memory: make(map[string]StreamCheck)}
+}
+
+func (r *StreamCheckRepository) RecordCheck(ctx context.Context, check StreamCheck) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.memory[check.MovieID+"\n"+check.StreamURL] = check
+		return nil
+	}
+
+	movieID, err := strconv.ParseInt(check.MovieID, 10, 64)
+	if err != nil {
+		return err
+	}
+	reason := sql.NullString{String: check.Reason, Valid: check.Reason != ""}
+	_, err = r.db.ExecContext(
+		ctx,
+		`UPDATE movie_streams
+		 SET validation_status = $3,
+		     last_validated_at = $4,
+		     validation_latency_ms = $5,
+		     validation_error = $6,
+		     updated_at = NOW()
+		 WHERE movie_id = $1 AND stream_url = $2`,
+		movieID,
+		check.StreamURL,
+		string(check.Status),
+		check.CheckedAt.UTC(),
+		check.Latency.Milliseconds(),
+		reason,
+	)
+	return err
+}
+
+// ListChecks returns the latest check of every stream source ordered by movie
+// slug and source priority. A non-empty status keeps only sources in that
+// state.
+func (r *StreamCheckRepository) ListChecks(ctx context.Context, status movies.ValidationStatus) ([]StreamCheck, error) {
+	if r.db == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		checks := make([]StreamCheck, 0, len(r.memory))
+		for _, check := range r.memory {
+			if status == "" || check.Status == status {
+				checks = append(checks, check)
+			}
+		}
+		sort.Slice(checks, func(i, j int) bool {
+			if checks[i].Slug != checks[j].Slug {
+				return checks[i].Slug < checks[j].Slug
+			}
+			return checks[i].Priority < checks[j].Priority
+		})
+		return checks, nil
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT m.id, m.slug, m.title, s.stream_url, s.priority,
+		        s.validation_status, s.last_validated_at, s.validation_latency_ms, s.validation_error
+		 FROM movie_streams s
+		 JOIN movies m ON m.id = s.movie_id
+		 WHERE $1 = '' OR s.validation_status = $1
+		 ORDER BY m.slug, s.priority`,
+		string(status),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := make([]StreamCheck, 0)
+	for rows.Next() {
+		var (
+			check     StreamCheck
+			movieID   int64
+			rawStatus string
+			checkedAt sql.NullTime
+			latencyMS sql.NullInt64
+			reason    sql.NullString
+		)
+		if err := rows.Scan(&movieID, &check.Slug, &check.Title, &check.StreamURL, &check.Priority, &rawStatus, &checkedAt, &latencyMS, &reason); err != nil {
+			return nil, err
+		}
+		check.MovieID = strconv.FormatInt(movieID, 10)
+		check.Status = movies.ValidationStatus(rawStatus)
+		if checkedAt.Valid {
+			check.CheckedAt = checkedAt.Time.UTC()
+		}
+		check.Latency = time.Duration(latencyMS.Int64) * time.Millisecond
+		check.Reason = reason.String
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+type streamMetadataRecord struct {
+	DurationMS int64                  `json:"durationMs"`
+	Renditions []renditionRecord      `json:"renditions"`
+	Media      []mediaRenditionRecord `json:"media"`
+	Encryption string                 `json:"encryption"`
+}
+
+type renditionRecord struct {
+	Bandwidth  int64  `json:"bandwidth"`
+	Resolution string `json:"resolution,omitempty"`
+	Codecs     string `json:"codecs,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+	Subtitles  string `json:"subtitles,omitempty"`
+}
+
+type mediaRenditionRecord struct {
+	Type     string `json:"type"`
+	GroupID  string `json:"groupId"`
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+}
+
+// UpdateStreamMetadata stores the result of a new probe of a movie's primary
+// stream.
+func (r *MovieRepository) UpdateStreamMetadata(ctx context.Context, movieID string, metadata movies.StreamMetadata) error {
+	if r.db == nil {
+		for slug, movie := range sampleMovies {
+			if movie.ID == movieID {
+				movie.Metadata = &metadata
+				sampleMovies[slug] = movie
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	}
+
+	id, err := strconv.ParseInt(movieID, 10, 64)
+	if err != nil {
+		return err
+	}
+	raw, err := marshalStreamMetadata(&metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE movies SET duration_minutes = $2, updated_at = NOW() WHERE id = $1`,
+		id,
+		durationMinutes(&metadata),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE movie_streams
+		 SET stream_metadata = $2, probed_at = $3, updated_at = NOW()
+		 WHERE movie_id = $1 AND priority = 0`,
+		id,
+		raw,
+		metadata.ProbedAt.UTC(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func marshalStreamMetadata(metadata *movies.StreamMetadata) ([]byte, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	record := streamMetadataRecord{
+		DurationMS: metadata.Duration.Milliseconds(),
+		Renditions: make([]renditionRecord, 0, len(metadata.Renditions)),
+		Media:      make([]mediaRenditionRecord, 0, len(metadata.Media)),
+		Encryption: metadata.Encryption,
+	}
+	for _, rendition := range metadata.Renditions {
+		record.Renditions = append(record.Renditions, renditionRecord(rendition))
+	}
+	for _, media := range metadata.Media {
+		record.Media = append(record.Media, mediaRenditionRecord(media))
+	}
+	return json.Marshal(record)
+}
+
+func unmarshalStreamMetadata(raw []byte, probedAt sql.NullTime) *movies.StreamMetadata {
+	var record streamMetadataRecord
+	if len(raw) == 0 || !probedAt.Valid || json.Unmarshal(raw, &record) != nil {
+		return nil
+	}
+	metadata := &movies.StreamMetadata{
+		Duration:   time.Duration(record.DurationMS) * time.Millisecond,
+		Encryption: record.Encryption,
+		ProbedAt:   probedAt.Time.UTC(),
+	}
+	for _, rendition := range record.Renditions {
+		metadata.Renditions = append(metadata.Renditions, movies.Rendition(rendition))
+	}
+	for _, media := range record.Media {
+		metadata.Media = append(metadata.Media, movies.MediaRendition(media))
+	}
+	return metadata
+}
+
+func durationMinutes(metadata *movies.StreamMetadata) sql.NullInt64 {
+	if metadata == nil || metadata.Duration <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(metadata.DurationMinutes()), Valid: true}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WatermarkSession is a playback session of a watermarked movie together
+// with the A/B pattern its playlists were built from.
+type WatermarkSession struct {
+	MovieID   string
+	SessionID string
+	ViewerID  string
+	Pattern   uint64
+	IssuedAt  time.Time
+}
+
+// WatermarkRepository records watermark sessions. Without a database the
+// sessions are kept in memory for the lifetime of the process.
+type WatermarkRepository struct {
+	db *sql.DB
+
+	mu     sync.RWMutex
+	memory map[string][]WatermarkSession
+}
+
+func NewWatermarkRepository(db *sql.DB) *WatermarkRepository {
+	return &WatermarkRepository{db: db, memory: make(map[string][]WatermarkSession)}
+}
+
+func (r *WatermarkRepository) RecordSession(ctx context.Context, session WatermarkSession) error {
+	if r.db == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.memory[session.MovieID] = append(r.memory[session.MovieID], session)
+		return nil
+	}
+
+	movieID, err := strconv.ParseInt(session.MovieID, 10, 64)
+	if err != nil {
+		return err
+	}
+	viewerID := sql.NullString{String: session.ViewerID, Valid: session.ViewerID != ""}
+	_, err = r.db.ExecContext(
+		ctx,
+		`INSERT INTO watermark_sessions (movie_id, session_id, viewer_id, pattern, issued_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		movieID,
+		session.SessionID,
+		viewerID,
+		int64(session.Pattern),
+		session.IssuedAt.UTC(),
+	)
+	return err
+}
+
+// ListSessions returns the recorded sessions of a movie, oldest first.
+func (r *WatermarkRepository) ListSessions(ctx context.Context, movieID string) ([]WatermarkSession, error) {
+	if r.db == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return append([]WatermarkSession(nil), r.memory[movieID]...), nil
+	}
+
+	id, err := strconv.ParseInt(movieID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT session_id, viewer_id, pattern, issued_at
+		 FROM watermark_sessions
+		 WHERE movie_id = $1
+		 ORDER BY issued_at`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]WatermarkSession, 0)
+	for rows.Next() {
+		var (
+			session  = WatermarkSession{MovieID: movieID}
+			viewerID sql.NullString
+			pattern  int64
+		)
+		if err := rows.Scan(&session.SessionID, &viewerID, &pattern, &session.IssuedAt); err != nil {
+			return nil, err
+		}
+		session.ViewerID = viewerID.String
+		session.Pattern = uint64(pattern)
+		session.IssuedAt = session.IssuedAt.UTC()
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type CaptionCacheConfig struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// CaptionCache keeps caption files after their conversion to WebVTT, keyed
+// by source URL. Failed fetches and conversions are not cached.
+type CaptionCache struct {
+	cfg     CaptionCacheConfig
+	mu      sync.Mutex
+	entries map[string]captionItem
+	group   singleflight.Group
+	now     func() time.Time
+}
+
+type captionItem struct {
+	body    []byte
+	expires time.Time
+}
+
+func NewCaptionCache(cfg CaptionCacheConfig) *CaptionCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 256
+	}
+	return &CaptionCache{
+		cfg:     cfg,
+		entries: make(map[string]captionItem),
+		now:     time.Now,
+	}
+}
+
+func (c *CaptionCache) GetOrFetch(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	if body, ok := c.get(key); ok {
+		return body, nil
+	}
+
+	result, err, _ := c.group.Do(key, func() (any, error) {
+		body, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, body)
+		return body, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+func (c *CaptionCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(item.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return item.body, true
+}
+
+func (c *CaptionCache) put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= c.cfg.MaxEntries {
+		c.evictLocked(now)
+	}
+	c.entries[key] = captionItem{body: body, expires: now.Add(c.cfg.TTL)}
+}
+
+// evictLocked drops expired entries, then the entry closest to expiry if the
+// cache is still full.
+func (c *CaptionCache) evictLocked(now time.Time) {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+	for key, item := range c.entries {
+		if !now.Before(item.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || item.expires.Before(oldest) {
+			oldestKey, oldest = key, item.expires
+		}
+	}
+	if len(c.entries) >= c.cfg.MaxEntries && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
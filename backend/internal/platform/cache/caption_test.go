@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCaptionCacheTTLAndErrors(t *testing.T) {
+	c := NewCaptionCache(CaptionCacheConfig{TTL: time.Hour})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func(context.Context) ([]byte, error) {
+		fetches++
+		return []byte("WEBVTT\n"), nil
+	}
+	failing := func(context.Context) ([]byte, error) {
+		fetches++
+		return nil, errors.New("malformed")
+	}
+
+	c.GetOrFetch(context.Background(), "en", fetch)
+	c.GetOrFetch(context.Background(), "en", fetch)
+	if fetches != 1 {
+		t.Fatalf("expected the caption to be cached, got %d fetches", fetches)
+	}
+
+	c.GetOrFetch(context.Background(), "th", failing)
+	c.GetOrFetch(context.Background(), "th", failing)
+	if fetches != 3 {
+		t.Fatalf("expected failures not to be cached, got %d fetches", fetches)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if body, _ := c.GetOrFetch(context.Background(), "en", fetch); string(body) != "WEBVTT\n" || fetches != 4 {
+		t.Fatalf("expected the caption to expire after its TTL, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+)
+
+type PlaylistCacheConfig struct {
+	Enabled    bool
+	VODTTL     time.Duration
+	MaxEntries int
+}
+
+// PlaylistCache keeps parsed upstream playlists. VOD and master playlists
+// are kept for VODTTL; live playlists are never stored, so concurrent
+// requests for them are only coalesced into one upstream fetch. Entries are
+// grouped by scope (a movie) and the whole scope is dropped when its version
+// (the list of stream sources) changes, so edited sources take effect on the
+// next request without explicit invalidation.
+type PlaylistCache struct {
+	cfg      PlaylistCacheConfig
+	mu       sync.Mutex
+	entries  map[string]playlistItem
+	versions map[string]string
+	group    singleflight.Group
+	now      func() time.Time
+}
+
+type playlistItem struct {
+	scope    string
+	playlist hls.Playlist
+	expires  time.Time
+}
+
+func NewPlaylistCache(cfg PlaylistCacheConfig) *PlaylistCache {
+	if cfg.VODTTL <= 0 {
+		cfg.VODTTL = time.Hour
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1024
+	}
+	return &PlaylistCache{
+		cfg:      cfg,
+		entries:  make(map[string]playlistItem),
+		versions: make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+func (c *PlaylistCache) GetOrFetch(ctx context.Context, scope, version, key string, fetch func(context.Context) (hls.Playlist, error)) (hls.Playlist, error) {
+	if playlist, ok := c.get(scope, version, key); ok {
+		return playlist, nil
+	}
+
+	result, err, _ := c.group.Do(scope+"\n"+key, func() (any, error) {
+		playlist, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return hls.Playlist{}, err
+		}
+		if ttl := c.ttl(playlist); ttl > 0 {
+			c.put(scope, version, key, playlist, ttl)
+		}
+		return playlist, nil
+	})
+	if err != nil {
+		return hls.Playlist{}, err
+	}
+	return result.(hls.Playlist), nil
+}
+
+func (c *PlaylistCache) ttl(playlist hls.Playlist) time.Duration {
+	if playlist.Live() {
+		return 0
+	}
+	return c.cfg.VODTTL
+}
+
+func (c *PlaylistCache) get(scope, version, key string) (hls.Playlist, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if current, ok := c.versions[scope]; ok && current != version {
+		c.purgeLocked(scope)
+		delete(c.versions, scope)
+		return hls.Playlist{}, false
+	}
+
+	item, ok := c.entries[scope+"\n"+key]
+	if !ok {
+		return hls.Playlist{}, false
+	}
+	if !c.now().Before(item.expires) {
+		delete(c.entries, scope+"\n"+key)
+		return hls.Playlist{}, false
+	}
+	return item.playlist, true
+}
+
+func (c *PlaylistCache) put(scope, version, key string, playlist hls.Playlist, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if current, ok := c.versions[scope]; ok && current != version {
+		c.purgeLocked(scope)
+	}
+	c.versions[scope] = version
+
+	now := c.now()
+	if len(c.entries) >= c.cfg.MaxEntries {
+		c.evictLocked(now)
+	}
+	c.entries[scope+"\n"+key] = playlistItem{
+		scope:    scope,
+		playlist: playlist,
+		expires:  now.Add(ttl),
+	}
+}
+
+func (c *PlaylistCache) purgeLocked(scope string) {
+	for key, item := range c.entries {
+		if item.scope == scope {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evictLocked drops expired entries, then the entry closest to expiry if the
+// cache is still full.
+func (c *PlaylistCache) evictLocked(now time.Time) {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+	for key, item := range c.entries {
+		if !now.Before(item.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || item.expires.Before(oldest) {
+			oldestKey, oldest = key, item.expires
+		}
+	}
+	if len(c.entries) >= c.cfg.MaxEntries && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
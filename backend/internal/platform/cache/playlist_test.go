@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+)
+
+func TestPlaylistCacheTTLs(t *testing.T) {
+	c := NewPlaylistCache(PlaylistCacheConfig{VODTTL: time.Hour})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func(body string) func(context.Context) (hls.Playlist, error) {
+		return func(context.Context) (hls.Playlist, error) {
+			fetches++
+			return hls.Parse(body), nil
+		}
+	}
+	vod := fetch("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\na.ts\n#EXT-X-ENDLIST\n")
+	live := fetch("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\na.ts\n")
+
+	c.GetOrFetch(context.Background(), "movie", "v1", "vod", vod)
+	c.GetOrFetch(context.Background(), "movie", "v1", "live", live)
+
+	c.GetOrFetch(context.Background(), "movie", "v1", "vod", vod)
+	c.GetOrFetch(context.Background(), "movie", "v1", "live", live)
+	if fetches != 3 {
+		t.Fatalf("expected only the VOD playlist to be cached, got %d fetches", fetches)
+	}
+
+	now = now.Add(2 * time.Hour)
+	c.GetOrFetch(context.Background(), "movie", "v1", "vod", vod)
+	if fetches != 4 {
+		t.Fatalf("expected the VOD playlist to expire after its TTL, got %d fetches", fetches)
+	}
+}
+
+func TestPlaylistCacheInvalidatesOnVersionChange(t *testing.T) {
+	c := NewPlaylistCache(PlaylistCacheConfig{})
+
+	fetches := 0
+	fetch := func(context.Context) (hls.Playlist, error) {
+		fetches++
+		return hls.Parse("#EXTM3U\n#EXT-X-ENDLIST\n"), nil
+	}
+
+	c.GetOrFetch(context.Background(), "movie", "https://a.example.com/master.m3u8", "variant", fetch)
+	c.GetOrFetch(context.Background(), "movie", "https://a.example.com/master.m3u8", "variant", fetch)
+	c.GetOrFetch(context.Background(), "movie", "https://b.example.com/master.m3u8", "variant", fetch)
+	if fetches != 2 {
+		t.Fatalf("expected stream URL change to invalidate cache, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrSegmentTooLarge = errors.New("segment exceeds cache entry limit")
+
+type SegmentCacheConfig struct {
+	Enabled        bool
+	MaxMemoryBytes int64
+	MaxEntryBytes  int64
+	TTL            time.Duration
+	DiskDir        string
+	MaxDiskBytes   int64
+}
+
+type SegmentEntry struct {
+	Body         []byte
+	ContentType  string
+	ETag         string
+	LastModified string
+	StoredAt     time.Time
+}
+
+type SegmentCacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	DiskHits      uint64 `json:"diskHits"`
+	Coalesced     uint64 `json:"coalesced"`
+	Evictions     uint64 `json:"evictions"`
+	MemoryEntries int    `json:"memoryEntries"`
+	MemoryBytes   int64  `json:"memoryBytes"`
+	DiskEntries   int    `json:"diskEntries"`
+	DiskBytes     int64  `json:"diskBytes"`
+}
+
+// SegmentCache is a two-tier cache for upstream media segments: a bounded
+// in-memory LRU in front of an optional on-disk LRU. Concurrent misses for
+// the same key are coalesced into a single fetch.
+type SegmentCache struct {
+	cfg   SegmentCacheConfig
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+	disk  *segmentDiskTier
+	group singleflight.Group
+	now   func() time.Time
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	diskHits  atomic.Uint64
+	coalesced atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type memoryItem struct {
+	key   string
+	entry SegmentEntry
+}
+
+func NewSegmentCache(cfg SegmentCacheConfig) (*SegmentCache, error) {
+	if cfg.MaxMemoryBytes <= 0 {
+		cfg.MaxMemoryBytes = 256 << 20
+	}
+	if cfg.MaxEntryBytes <= 0 || cfg.MaxEntryBytes > cfg.MaxMemoryBytes {
+		cfg.MaxEntryBytes = min(16<<20, cfg.MaxMemoryBytes)
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+
+	c := &SegmentCache{
+		cfg:   cfg,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+
+	if cfg.DiskDir != "" {
+		disk, err := newSegmentDiskTier(cfg.DiskDir, cfg.MaxDiskBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+
+	return c, nil
+}
+
+func (c *SegmentCache) MaxEntryBytes() int64 {
+	return c.cfg.MaxEntryBytes
+}
+
+// Get returns a fresh entry from memory or disk without fetching.
+func (c *SegmentCache) Get(key string) (SegmentEntry, bool) {
+	if entry, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return entry, true
+	}
+	c.misses.Add(1)
+	return SegmentEntry{}, false
+}
+
+// GetOrFetch returns the cached entry for key, calling fetch on a miss. Only
+// one fetch per key runs at a time; concurrent callers share its result. The
+// fetch context is detached from ctx so one viewer disconnecting does not fail
+// the others waiting on the same segment.
+func (c *SegmentCache) GetOrFetch(ctx context.Context, key string, fetch func(context.Context) (SegmentEntry, error)) (SegmentEntry, bool, error) {
+	if entry, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return entry, true, nil
+	}
+	c.misses.Add(1)
+
+	result := c.group.DoChan(key, func() (any, error) {
+		if entry, ok := c.lookup(key); ok {
+			return entry, nil
+		}
+		entry, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return SegmentEntry{}, err
+		}
+		if int64(len(entry.Body)) > c.cfg.MaxEntryBytes {
+			return SegmentEntry{}, ErrSegmentTooLarge
+		}
+		entry.StoredAt = c.now()
+		c.store(key, entry)
+		return entry, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return SegmentEntry{}, false, ctx.Err()
+	case res := <-result:
+		if res.Shared {
+			c.coalesced.Add(1)
+		}
+		if res.Err != nil {
+			return SegmentEntry{}, false, res.Err
+		}
+		return res.Val.(SegmentEntry), false, nil
+	}
+}
+
+func (c *SegmentCache) Stats() SegmentCacheStats {
+	c.mu.Lock()
+	stats := SegmentCacheStats{
+		MemoryEntries: c.ll.Len(),
+		MemoryBytes:   c.bytes,
+	}
+	c.mu.Unlock()
+
+	if c.disk != nil {
+		stats.DiskEntries, stats.DiskBytes = c.disk.usage()
+	}
+	stats.Hits = c.hits.Load()
+	stats.Misses = c.misses.Load()
+	stats.DiskHits = c.diskHits.Load()
+	stats.Coalesced = c.coalesced.Load()
+	stats.Evictions = c.evictions.Load()
+	return stats
+}
+
+func (c *SegmentCache) lookup(key string) (SegmentEntry, bool) {
+	now := c.now()
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*memoryItem)
+		if now.Sub(item.entry.StoredAt) < c.cfg.TTL {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			return item.entry, true
+		}
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+
+	if c.disk == nil {
+		return SegmentEntry{}, false
+	}
+	entry, ok := c.disk.get(key)
+	if !ok {
+		return SegmentEntry{}, false
+	}
+	if now.Sub(entry.StoredAt) >= c.cfg.TTL {
+		c.disk.remove(key)
+		return SegmentEntry{}, false
+	}
+	c.diskHits.Add(1)
+	c.storeMemory(key, entry)
+	return entry, true
+}
+
+func (c *SegmentCache) store(key string, entry SegmentEntry) {
+	c.storeMemory(key, entry)
+	if c.disk != nil {
+		c.disk.put(key, entry)
+	}
+}
+
+func (c *SegmentCache) storeMemory(key string, entry SegmentEntry) {
+	size := int64(len(entry.Body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	c.items[key] = c.ll.PushFront(&memoryItem{key: key, entry: entry})
+	c.bytes += size
+
+	for c.bytes > c.cfg.MaxMemoryBytes && c.ll.Len() > 1 {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *SegmentCache) removeElement(el *list.Element) {
+	item := el.Value.(*memoryItem)
+	c.ll.Remove(el)
+	delete(c.items, item.key)
+	c.bytes -= int64(len(item.entry.Body))
+}
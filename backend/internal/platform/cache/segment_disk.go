@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// segmentDiskTier stores one gob-encoded entry per file, named by the SHA-256
+// of the cache key, and evicts the least recently used files past maxBytes.
+type segmentDiskTier struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+	bytes    int64
+}
+
+type diskItem struct {
+	name string
+	size int64
+}
+
+type diskRecord struct {
+	Key   string
+	Entry SegmentEntry
+}
+
+func newSegmentDiskTier(dir string, maxBytes int64) (*segmentDiskTier, error) {
+	if maxBytes <= 0 {
+		maxBytes = 2 << 30
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	tier := &segmentDiskTier{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		name string
+		info os.FileInfo
+	}
+	files := make([]existing, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, existing{name: entry.Name(), info: info})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().After(files[j].info.ModTime())
+	})
+	for _, file := range files {
+		tier.items[file.name] = tier.ll.PushBack(&diskItem{name: file.name, size: file.info.Size()})
+		tier.bytes += file.info.Size()
+	}
+	tier.evictLocked()
+
+	return tier, nil
+}
+
+func (t *segmentDiskTier) get(key string) (SegmentEntry, bool) {
+	name := diskName(key)
+
+	t.mu.Lock()
+	el, ok := t.items[name]
+	if ok {
+		t.ll.MoveToFront(el)
+	}
+	t.mu.Unlock()
+	if !ok {
+		return SegmentEntry{}, false
+	}
+
+	file, err := os.Open(filepath.Join(t.dir, name))
+	if err != nil {
+		t.remove(key)
+		return SegmentEntry{}, false
+	}
+	defer file.Close()
+
+	var record diskRecord
+	if err := gob.NewDecoder(file).Decode(&record); err != nil || record.Key != key {
+		t.remove(key)
+		return SegmentEntry{}, false
+	}
+	return record.Entry, true
+}
+
+func (t *segmentDiskTier) put(key string, entry SegmentEntry) {
+	name := diskName(key)
+	tmp, err := os.CreateTemp(t.dir, name+".*.tmp")
+	if err != nil {
+		return
+	}
+	if err := gob.NewEncoder(tmp).Encode(diskRecord{Key: key, Entry: entry}); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+	info, err := tmp.Stat()
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(t.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el, ok := t.items[name]; ok {
+		t.bytes -= el.Value.(*diskItem).size
+		t.ll.Remove(el)
+	}
+	t.items[name] = t.ll.PushFront(&diskItem{name: name, size: info.Size()})
+	t.bytes += info.Size()
+	t.evictLocked()
+}
+
+func (t *segmentDiskTier) remove(key string) {
+	name := diskName(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el, ok := t.items[name]; ok {
+		t.removeLocked(el)
+	}
+}
+
+func (t *segmentDiskTier) usage() (int, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ll.Len(), t.bytes
+}
+
+func (t *segmentDiskTier) evictLocked() {
+	for t.bytes > t.maxBytes && t.ll.Len() > 0 {
+		t.removeLocked(t.ll.Back())
+	}
+}
+
+func (t *segmentDiskTier) removeLocked(el *list.Element) {
+	item := el.Value.(*diskItem)
+	t.ll.Remove(el)
+	delete(t.items, item.name)
+	t.bytes -= item.size
+	os.Remove(filepath.Join(t.dir, item.name))
+}
+
+func diskName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
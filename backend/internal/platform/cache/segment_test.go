@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSegmentCacheCoalescesConcurrentMisses(t *testing.T) {
+	c, err := NewSegmentCache(SegmentCacheConfig{MaxMemoryBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("NewSegmentCache returned error: %v", err)
+	}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func(context.Context) (SegmentEntry, error) {
+		fetches.Add(1)
+		<-release
+		return SegmentEntry{Body: []byte("segment")}, nil
+	}
+
+	const viewers = 50
+	var wg sync.WaitGroup
+	wg.Add(viewers)
+	for i := 0; i < viewers; i++ {
+		go func() {
+			defer wg.Done()
+			entry, _, err := c.GetOrFetch(context.Background(), "https://cdn.example.com/seg1.ts", fetch)
+			if err != nil || string(entry.Body) != "segment" {
+				t.Errorf("unexpected result %q, %v", entry.Body, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("expected a single upstream fetch, got %d", got)
+	}
+	if _, hit, _ := c.GetOrFetch(context.Background(), "https://cdn.example.com/seg1.ts", fetch); !hit {
+		t.Fatalf("expected cached entry on subsequent request")
+	}
+	if stats := c.Stats(); stats.Hits+stats.Misses != viewers+1 || stats.Misses == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSegmentCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := NewSegmentCache(SegmentCacheConfig{MaxMemoryBytes: 10, MaxEntryBytes: 4})
+	if err != nil {
+		t.Fatalf("NewSegmentCache returned error: %v", err)
+	}
+	put := func(key string) {
+		c.GetOrFetch(context.Background(), key, func(context.Context) (SegmentEntry, error) {
+			return SegmentEntry{Body: []byte("1234")}, nil
+		})
+	}
+
+	put("a")
+	put("b")
+	c.Get("a")
+	put("c")
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected recently used entry to stay cached")
+	}
+
+	_, _, err = c.GetOrFetch(context.Background(), "big", func(context.Context) (SegmentEntry, error) {
+		return SegmentEntry{Body: []byte("12345")}, nil
+	})
+	if err != ErrSegmentTooLarge {
+		t.Fatalf("expected ErrSegmentTooLarge, got %v", err)
+	}
+}
+
+func TestSegmentCacheDiskTierAndTTL(t *testing.T) {
+	dir := t.TempDir()
+	cfg := SegmentCacheConfig{MaxMemoryBytes: 1 << 20, TTL: time.Minute, DiskDir: dir}
+	c, err := NewSegmentCache(cfg)
+	if err != nil {
+		t.Fatalf("NewSegmentCache returned error: %v", err)
+	}
+	c.GetOrFetch(context.Background(), "seg", func(context.Context) (SegmentEntry, error) {
+		return SegmentEntry{Body: []byte("from-disk"), ContentType: "video/mp2t"}, nil
+	})
+
+	reopened, err := NewSegmentCache(cfg)
+	if err != nil {
+		t.Fatalf("NewSegmentCache returned error: %v", err)
+	}
+	entry, ok := reopened.Get("seg")
+	if !ok || string(entry.Body) != "from-disk" || entry.ContentType != "video/mp2t" {
+		t.Fatalf("expected entry to be served from disk, got %+v %v", entry, ok)
+	}
+	if stats := reopened.Stats(); stats.DiskHits != 1 || stats.DiskEntries != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	reopened.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, ok := reopened.Get("seg"); ok {
+		t.Fatalf("expected expired entry to be dropped")
+	}
+}
@@ -0,0 +1,148 @@
+// Package captions converts caption files to WebVTT, the only format the
+// player's text tracks understand.
+package captions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed caption file")
+	ErrTooLarge  = errors.New("caption file exceeds size limit")
+)
+
+type Format string
+
+const (
+	FormatWebVTT Format = "webvtt"
+	FormatSRT    Format = "srt"
+	FormatTTML   Format = "ttml"
+)
+
+// Cue is one caption shown from Start to End. Text lines are separated by
+// newlines and may only use the <i>, <b> and <u> markup WebVTT shares with
+// SRT.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Detect tells the caption formats apart by their first bytes: WebVTT files
+// start with a WEBVTT line, TTML and DFXP files are XML and anything else is
+// taken to be SRT.
+func Detect(body []byte) Format {
+	body = bytes.TrimLeft(bytes.TrimPrefix(body, []byte("\ufeff")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(body, []byte("WEBVTT")):
+		return FormatWebVTT
+	case bytes.HasPrefix(body, []byte("<")):
+		return FormatTTML
+	default:
+		return FormatSRT
+	}
+}
+
+// Read reads a caption file of up to maxBytes from r. A maxBytes of zero
+// disables the size limit.
+func Read(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+	return body, nil
+}
+
+// ToWebVTT converts an SRT, TTML/DFXP or WebVTT caption file to WebVTT.
+// WebVTT input is only checked for its header and passed through. Files that
+// cannot be parsed, or have no cues at all, fail with ErrMalformed.
+func ToWebVTT(body []byte) ([]byte, error) {
+	var (
+		cues []Cue
+		err  error
+	)
+	switch Detect(body) {
+	case FormatWebVTT:
+		return normalizeWebVTT(body)
+	case FormatTTML:
+		cues, err = ParseTTML(body)
+	default:
+		cues, err = ParseSRT(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("%w: no cues", ErrMalformed)
+	}
+	return WriteWebVTT(cues), nil
+}
+
+// WriteWebVTT renders cues as a WebVTT file.
+func WriteWebVTT(cues []Cue) []byte {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&out, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start), formatTimestamp(cue.End), cue.Text)
+	}
+	return out.Bytes()
+}
+
+func normalizeWebVTT(body []byte) ([]byte, error) {
+	body = bytes.TrimLeft(bytes.TrimPrefix(body, []byte("\ufeff")), " \t\r\n")
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	header, _, _ := bytes.Cut(body, []byte("\n"))
+	if rest := bytes.TrimPrefix(header, []byte("WEBVTT")); len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return nil, fmt.Errorf("%w: invalid WebVTT header %q", ErrMalformed, header)
+	}
+	return body, nil
+}
+
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+var markupTag = regexp.MustCompile(`</?([a-zA-Z]+)[^<>]*>`)
+
+// cueText makes caption text safe for a WebVTT cue: <i>, <b> and <u> are kept
+// without attributes, other tags such as SRT's <font> are dropped and the
+// characters WebVTT reserves are escaped.
+func cueText(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, match := range markupTag.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(escapeCueText(text[last:match[0]]))
+		last = match[1]
+
+		name := strings.ToLower(text[match[2]:match[3]])
+		if name != "i" && name != "b" && name != "u" {
+			continue
+		}
+		if text[match[0]+1] == '/' {
+			out.WriteString("</" + name + ">")
+		} else {
+			out.WriteString("<" + name + ">")
+		}
+	}
+	out.WriteString(escapeCueText(text[last:]))
+	return out.String()
+}
+
+var cueTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeCueText(text string) string {
+	return cueTextEscaper.Replace(text)
+}
@@ -0,0 +1,79 @@
+package captions
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSRTToWebVTT(t *testing.T) {
+	srt := "\ufeff1\r\n00:00:01,000 --> 00:00:02,500 X1:10 X2:20\r\n<i>Hello</i> & <font color=\"red\">welcome</font>\r\nsecond line\r\n\r\n" +
+		"00:00:03.2 --> 00:00:04,000\r\nno cue number\r\n"
+
+	got, err := ToWebVTT([]byte(srt))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:01.000 --> 00:00:02.500\n<i>Hello</i> &amp; welcome\nsecond line\n\n" +
+		"00:00:03.200 --> 00:00:04.000\nno cue number\n"
+	if string(got) != want {
+		t.Fatalf("unexpected WebVTT:\n%s", got)
+	}
+}
+
+func TestTTMLToWebVTT(t *testing.T) {
+	ttml := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:tts="http://www.w3.org/ns/ttml#styling" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:frameRate="25" ttp:tickRate="10000000">
+  <head><metadata><p>not a cue</p></metadata></head>
+  <body>
+    <div begin="10s">
+      <p begin="00:00:01:05" end="00:00:03.000">First <span tts:fontStyle="italic">line</span><br/>
+        next   line</p>
+      <p begin="40000000t" dur="1.5s">R&amp;D</p>
+    </div>
+    <p begin="500ms" end="1s">Early</p>
+  </body>
+</tt>`
+
+	got, err := ToWebVTT([]byte(ttml))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:00.500 --> 00:00:01.000\nEarly\n\n" +
+		"00:00:11.200 --> 00:00:13.000\nFirst <i>line</i>\nnext line\n\n" +
+		"00:00:14.000 --> 00:00:15.500\nR&amp;D\n"
+	if string(got) != want {
+		t.Fatalf("unexpected WebVTT:\n%s", got)
+	}
+}
+
+func TestWebVTTPassesThrough(t *testing.T) {
+	vtt := "WEBVTT - English\r\n\r\n00:01.000 --> 00:02.000\r\nHi\r\n"
+	got, err := ToWebVTT([]byte(vtt))
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if string(got) != "WEBVTT - English\n\n00:01.000 --> 00:02.000\nHi\n" {
+		t.Fatalf("unexpected WebVTT:\n%s", got)
+	}
+}
+
+func TestMalformedCaptions(t *testing.T) {
+	cases := map[string]string{
+		"srt timing":       "1\n00:00:01,000 -> 00:00:02,000\nHello\n",
+		"srt reversed":     "1\n00:00:05,000 --> 00:00:02,000\nHello\n",
+		"srt empty":        "\n\n",
+		"ttml syntax":      `<tt><body><p begin="1s" end="2s">Hello</body></tt>`,
+		"ttml root":        `<html><body><p>Hello</p></body></html>`,
+		"ttml no end":      `<tt><body><p begin="1s">Hello</p></body></tt>`,
+		"ttml bad time":    `<tt><body><p begin="soon" end="2s">Hello</p></body></tt>`,
+		"webvtt header":    "WEBVTTX\n\n00:01.000 --> 00:02.000\nHi\n",
+		"ttml ends before": `<tt><body><p begin="3s" end="2s">Hello</p></body></tt>`,
+	}
+	for name, body := range cases {
+		if _, err := ToWebVTT([]byte(body)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: expected ErrMalformed, got %v", name, err)
+		}
+	}
+}
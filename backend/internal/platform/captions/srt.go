@@ -0,0 +1,101 @@
+package captions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSRT reads the cues of a SubRip file. Cue numbers are optional, since
+// many files in the wild miss or repeat them, but every cue needs a valid
+// "start --> end" timing line.
+func ParseSRT(body []byte) ([]Cue, error) {
+	text := strings.TrimPrefix(string(body), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var cues []Cue
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		start := i
+		if !strings.Contains(lines[i], "-->") && i+1 < len(lines) {
+			i++
+		}
+		cue, err := parseSRTTiming(lines[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, i+1, err)
+		}
+		i++
+
+		var text []string
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			text = append(text, cueText(strings.TrimSpace(lines[i])))
+		}
+		if len(text) == 0 {
+			return nil, fmt.Errorf("%w: line %d: cue without text", ErrMalformed, start+1)
+		}
+		cue.Text = strings.Join(text, "\n")
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+func parseSRTTiming(line string) (Cue, error) {
+	rawStart, rawEnd, ok := strings.Cut(line, "-->")
+	if !ok {
+		return Cue{}, fmt.Errorf("expected a timing line, got %q", line)
+	}
+	// Anything after the end time is SRT position information, which WebVTT
+	// has no equivalent for.
+	if fields := strings.Fields(rawEnd); len(fields) > 0 {
+		rawEnd = fields[0]
+	}
+
+	start, err := parseSRTTimestamp(strings.TrimSpace(rawStart))
+	if err != nil {
+		return Cue{}, err
+	}
+	end, err := parseSRTTimestamp(rawEnd)
+	if err != nil {
+		return Cue{}, err
+	}
+	if end < start {
+		return Cue{}, fmt.Errorf("cue ends at %s before it starts at %s", rawEnd, strings.TrimSpace(rawStart))
+	}
+	return Cue{Start: start, End: end}, nil
+}
+
+// parseSRTTimestamp reads HH:MM:SS,mmm. A dot is accepted in place of the
+// comma and the hours may have any number of digits.
+func parseSRTTimestamp(raw string) (time.Duration, error) {
+	clock, fraction, ok := strings.Cut(strings.Replace(raw, ".", ",", 1), ",")
+	parts := strings.Split(clock, ":")
+	if !ok || len(parts) != 3 || len(fraction) == 0 || len(fraction) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", raw)
+	}
+
+	var values [4]int
+	for i, part := range append(parts, fraction) {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", raw)
+		}
+		values[i] = value
+	}
+	if values[1] > 59 || values[2] > 59 {
+		return 0, fmt.Errorf("invalid timestamp %q", raw)
+	}
+	for n := len(fraction); n < 3; n++ {
+		values[3] *= 10
+	}
+
+	return time.Duration(values[0])*time.Hour +
+		time.Duration(values[1])*time.Minute +
+		time.Duration(values[2])*time.Second +
+		time.Duration(values[3])*time.Millisecond, nil
+}
@@ -0,0 +1,307 @@
+package captions
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const unbounded = time.Duration(math.MaxInt64)
+
+// ttmlTiming holds the rates of the tt element that frame and tick based time
+// expressions depend on.
+type ttmlTiming struct {
+	frameRate float64
+	tickRate  float64
+}
+
+// ttmlScope is the active interval of an element; times of its children are
+// relative to begin and clipped to end.
+type ttmlScope struct {
+	begin time.Duration
+	end   time.Duration
+	// closing is the markup to write when the element ends, for spans
+	// styled italic, bold or underlined.
+	closing string
+}
+
+// ParseTTML reads the cues of a TTML or DFXP document: every p element in
+// the body with text becomes a cue. Timing is inherited from and relative to
+// the enclosing body, div and span elements as in TTML's default parallel
+// time containment. Spans styled italic, bold or underlined keep that style.
+func ParseTTML(body []byte) ([]Cue, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	timing := ttmlTiming{frameRate: 30, tickRate: 1}
+
+	var (
+		cues   []Cue
+		stack  []ttmlScope
+		cue    *Cue
+		text   strings.Builder
+		inBody int
+		root   bool
+	)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !root {
+				if t.Name.Local != "tt" {
+					return nil, fmt.Errorf("%w: root element is %q, not tt", ErrMalformed, t.Name.Local)
+				}
+				timing = parseTTMLTimingAttrs(t.Attr, timing)
+				root = true
+			}
+
+			parent := ttmlScope{end: unbounded}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			scope, err := timing.scope(t, parent)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, lineOf(decoder, body), err)
+			}
+
+			if t.Name.Local == "body" || inBody > 0 {
+				inBody++
+			}
+			if inBody > 0 {
+				switch {
+				case t.Name.Local == "p" && cue == nil:
+					cue = &Cue{Start: scope.begin, End: scope.end}
+					text.Reset()
+				case t.Name.Local == "br" && cue != nil:
+					text.WriteByte('\n')
+				case t.Name.Local == "span" && cue != nil:
+					opening, closing := spanMarkup(t.Attr)
+					text.WriteString(opening)
+					scope.closing = closing
+				}
+			}
+			stack = append(stack, scope)
+
+		case xml.CharData:
+			if cue != nil {
+				text.WriteString(escapeCueText(collapseSpace(string(t))))
+			}
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			scope := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			text.WriteString(scope.closing)
+			if inBody > 0 {
+				inBody--
+			}
+
+			if t.Name.Local != "p" || cue == nil {
+				continue
+			}
+			cueText := ttmlCueText(text.String())
+			if cueText != "" && cue.End > cue.Start {
+				if cue.End == unbounded {
+					return nil, fmt.Errorf("%w: line %d: paragraph has no end time", ErrMalformed, lineOf(decoder, body))
+				}
+				cue.Text = cueText
+				cues = append(cues, *cue)
+			}
+			cue = nil
+		}
+	}
+	if !root {
+		return nil, fmt.Errorf("%w: no tt element", ErrMalformed)
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	return cues, nil
+}
+
+func parseTTMLTimingAttrs(attrs []xml.Attr, timing ttmlTiming) ttmlTiming {
+	for _, attr := range attrs {
+		value, err := strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		switch attr.Name.Local {
+		case "frameRate":
+			timing.frameRate = value
+		case "tickRate":
+			timing.tickRate = value
+		}
+	}
+	return timing
+}
+
+// scope resolves the begin, end and dur attributes of an element against its
+// parent's interval.
+func (timing ttmlTiming) scope(element xml.StartElement, parent ttmlScope) (ttmlScope, error) {
+	scope := ttmlScope{begin: parent.begin, end: parent.end}
+	var (
+		end, dur       time.Duration
+		hasEnd, hasDur bool
+	)
+	for _, attr := range element.Attr {
+		var (
+			value time.Duration
+			err   error
+		)
+		switch attr.Name.Local {
+		case "begin", "end", "dur":
+			value, err = timing.parse(attr.Value)
+			if err != nil {
+				return ttmlScope{}, fmt.Errorf("%s attribute of %s: %v", attr.Name.Local, element.Name.Local, err)
+			}
+		default:
+			continue
+		}
+		switch attr.Name.Local {
+		case "begin":
+			scope.begin = parent.begin + value
+		case "end":
+			end, hasEnd = value, true
+		case "dur":
+			dur, hasDur = value, true
+		}
+	}
+
+	switch {
+	case hasEnd:
+		scope.end = parent.begin + end
+	case hasDur:
+		scope.end = scope.begin + dur
+	}
+	if hasEnd && hasDur && scope.begin+dur < scope.end {
+		scope.end = scope.begin + dur
+	}
+	if scope.end < scope.begin {
+		return ttmlScope{}, fmt.Errorf("%s ends before it begins", element.Name.Local)
+	}
+	// Elements outside their parent's interval are never shown.
+	scope.end = min(scope.end, parent.end)
+	scope.begin = min(scope.begin, scope.end)
+	return scope, nil
+}
+
+// parse reads a TTML time expression: a clock time such as 00:01:02.500 or
+// 00:01:02:12 (frames), or an offset such as 62.5s, 500ms, 2m, 1h, 30f or
+// 10000t (ticks).
+func (timing ttmlTiming) parse(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, ":") {
+		parts := strings.Split(raw, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return 0, fmt.Errorf("invalid time expression %q", raw)
+		}
+		var values [4]float64
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(part, 64)
+			if err != nil || value < 0 || (i < 2 && strings.Contains(part, ".")) {
+				return 0, fmt.Errorf("invalid time expression %q", raw)
+			}
+			values[i] = value
+		}
+		seconds := values[0]*3600 + values[1]*60 + values[2] + values[3]/timing.frameRate
+		return secondsToDuration(seconds), nil
+	}
+
+	metric := strings.TrimLeft(raw, "0123456789.")
+	value, err := strconv.ParseFloat(strings.TrimSuffix(raw, metric), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid time expression %q", raw)
+	}
+	switch metric {
+	case "h":
+		value *= 3600
+	case "m":
+		value *= 60
+	case "s":
+	case "ms":
+		value /= 1000
+	case "f":
+		value /= timing.frameRate
+	case "t":
+		value /= timing.tickRate
+	default:
+		return 0, fmt.Errorf("invalid time expression %q", raw)
+	}
+	return secondsToDuration(value), nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds * float64(time.Second)))
+}
+
+// spanMarkup returns the WebVTT tags matching a span's inline style.
+func spanMarkup(attrs []xml.Attr) (string, string) {
+	var opening, closing string
+	for _, attr := range attrs {
+		var tag string
+		switch {
+		case attr.Name.Local == "fontStyle" && attr.Value == "italic":
+			tag = "i"
+		case attr.Name.Local == "fontWeight" && attr.Value == "bold":
+			tag = "b"
+		case attr.Name.Local == "textDecoration" && attr.Value == "underline":
+			tag = "u"
+		default:
+			continue
+		}
+		opening += "<" + tag + ">"
+		closing = "</" + tag + ">" + closing
+	}
+	return opening, closing
+}
+
+// collapseSpace turns every run of XML whitespace into a single space, as
+// TTML's default xml:space handling does.
+func collapseSpace(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if text != "" && isSpace(text[0]) {
+		collapsed = " " + collapsed
+	}
+	if len(text) > 1 && isSpace(text[len(text)-1]) && collapsed != " " {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+// ttmlCueText drops the whitespace around the lines of a cue, the spaces
+// left doubled by collapseSpace and empty lines.
+func ttmlCueText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// lineOf returns the line of body the decoder has read up to.
+func lineOf(decoder *xml.Decoder, body []byte) int {
+	offset := int(decoder.InputOffset())
+	if offset > len(body) {
+		offset = len(body)
+	}
+	return bytes.Count(body[:offset], []byte("\n")) + 1
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
@@ -17,6 +19,9 @@ type HTTPConfig struct {
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
 	ShutdownTimeout time.Duration
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// viewer headers are believed, see middleware.TrustedProxies.
+	TrustedProxies []netip.Prefix
 }
 
 func (h HTTPConfig) Address() string {
@@ -30,10 +35,61 @@ type Config struct {
 	Redis     cache.RedisConfig
 	Telemetry telemetry.Config
 	Stream    StreamConfig
+	Admin     AdminConfig
+}
+
+type AdminConfig struct {
+	// Tokens are the admin API credentials as name:token pairs, see
+	// middleware.ParseAdminTokens. The admin API refuses every request
+	// while empty.
+	Tokens string
 }
 
 type StreamConfig struct {
-	TokenTTL time.Duration
+	TokenTTL         time.Duration
+	ReferenceSecret  string
+	ReferenceTTL     time.Duration
+	SecretKey        string
+	ContentKeyMaster string
+	WatermarkSecret  string
+	MaxPlaylistBytes int64
+	SegmentCache     cache.SegmentCacheConfig
+	PlaylistCache    cache.PlaylistCacheConfig
+
+	// Upstream* configure the HTTP client fetching from stream origins, see
+	// upstream.Config.
+	UpstreamConnectTimeout      time.Duration
+	UpstreamTTFBTimeout         time.Duration
+	UpstreamTotalTimeout        time.Duration
+	UpstreamMaxConnsPerHost     int
+	UpstreamMaxIdleConnsPerHost int
+	UpstreamIdleConnTimeout     time.Duration
+	UpstreamMaxRetries          int
+	UpstreamRetryBaseDelay      time.Duration
+	UpstreamRetryMaxDelay       time.Duration
+	UpstreamBreakerThreshold    int
+	UpstreamBreakerCooldown     time.Duration
+	UpstreamBlockingTimeout     time.Duration
+	UpstreamAllowedNetworks     []netip.Prefix
+
+	// SourceCheckInterval is how often every stream source is health
+	// checked; zero disables the checker.
+	SourceCheckInterval time.Duration
+
+	// RenditionPolicies caps the renditions of each viewer tier, see
+	// movies.ParseRenditionPolicies for the format.
+	RenditionPolicies string
+
+	CaptionCache cache.CaptionCacheConfig
+
+	// TokenKeys is the playback token keyring, see
+	// movies.ParseTokenKeyring. When set, tokens are stateless and signed
+	// instead of stored in Redis.
+	TokenKeys string
+
+	// SessionMaxAge caps how long a playback session can be renewed by
+	// refreshing its token; zero for no limit.
+	SessionMaxAge time.Duration
 }
 
 type DatabaseConfig struct {
@@ -50,6 +106,15 @@ type DatabaseConfig struct {
 }
 
 func Load() (Config, error) {
+	trustedProxies, err := getEnvAsPrefixes("HTTP_TRUSTED_PROXIES")
+	if err != nil {
+		return Config{}, err
+	}
+	allowedNetworks, err := getEnvAsPrefixes("STREAM_UPSTREAM_ALLOWED_NETWORKS")
+	if err != nil {
+		return Config{}, err
+	}
+
 	http := HTTPConfig{
 		Host:            getEnv("HTTP_HOST", "0.0.0.0"),
 		Port:            getEnvAsInt("HTTP_PORT", 8080),
@@ -57,6 +122,7 @@ func Load() (Config, error) {
 		WriteTimeout:    getEnvAsDuration("HTTP_WRITE_TIMEOUT_MS", 10*time.Second),
 		IdleTimeout:     getEnvAsDuration("HTTP_IDLE_TIMEOUT_MS", 120*time.Second),
 		ShutdownTimeout: getEnvAsDuration("HTTP_SHUTDOWN_TIMEOUT_MS", 15*time.Second),
+		TrustedProxies:  trustedProxies,
 	}
 
 	return Config{
@@ -96,7 +162,51 @@ func Load() (Config, error) {
 			SampleRatio:  getEnvAsFloat("OTEL_SAMPLE_RATIO", 0.25),
 		},
 		Stream: StreamConfig{
-			TokenTTL: getEnvAsDurationSeconds("STREAM_TOKEN_TTL_SEC", 300),
+			TokenTTL:                    getEnvAsDurationSeconds("STREAM_TOKEN_TTL_SEC", 300),
+			ReferenceSecret:             getEnv("STREAM_REFERENCE_SECRET", ""),
+			ReferenceTTL:                getEnvAsDurationSeconds("STREAM_REFERENCE_TTL_SEC", 6*60*60),
+			SecretKey:                   getEnv("STREAM_SECRET_KEY", ""),
+			ContentKeyMaster:            getEnv("STREAM_CONTENT_KEY_MASTER", ""),
+			WatermarkSecret:             getEnv("STREAM_WATERMARK_SECRET", ""),
+			MaxPlaylistBytes:            int64(getEnvAsInt("STREAM_MAX_PLAYLIST_KB", 4096)) << 10,
+			UpstreamConnectTimeout:      getEnvAsDuration("STREAM_UPSTREAM_CONNECT_TIMEOUT_MS", 3*time.Second),
+			UpstreamTTFBTimeout:         getEnvAsDuration("STREAM_UPSTREAM_TTFB_TIMEOUT_MS", 5*time.Second),
+			UpstreamTotalTimeout:        getEnvAsDuration("STREAM_UPSTREAM_TOTAL_TIMEOUT_MS", 30*time.Second),
+			UpstreamMaxConnsPerHost:     getEnvAsInt("STREAM_UPSTREAM_MAX_CONNS_PER_HOST", 64),
+			UpstreamMaxIdleConnsPerHost: getEnvAsInt("STREAM_UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 32),
+			UpstreamIdleConnTimeout:     getEnvAsDuration("STREAM_UPSTREAM_IDLE_CONN_TIMEOUT_MS", 90*time.Second),
+			UpstreamMaxRetries:          getEnvAsInt("STREAM_UPSTREAM_MAX_RETRIES", 2),
+			UpstreamRetryBaseDelay:      getEnvAsDuration("STREAM_UPSTREAM_RETRY_BASE_DELAY_MS", 100*time.Millisecond),
+			UpstreamRetryMaxDelay:       getEnvAsDuration("STREAM_UPSTREAM_RETRY_MAX_DELAY_MS", time.Second),
+			UpstreamBreakerThreshold:    getEnvAsInt("STREAM_UPSTREAM_BREAKER_THRESHOLD", 5),
+			UpstreamBreakerCooldown:     getEnvAsDuration("STREAM_UPSTREAM_BREAKER_COOLDOWN_MS", 30*time.Second),
+			UpstreamBlockingTimeout:     getEnvAsDuration("STREAM_UPSTREAM_BLOCKING_TIMEOUT_MS", 20*time.Second),
+			UpstreamAllowedNetworks:     allowedNetworks,
+			SegmentCache: cache.SegmentCacheConfig{
+				Enabled:        getEnvAsBool("SEGMENT_CACHE_ENABLED", true),
+				MaxMemoryBytes: int64(getEnvAsInt("SEGMENT_CACHE_MAX_MEMORY_MB", 256)) << 20,
+				MaxEntryBytes:  int64(getEnvAsInt("SEGMENT_CACHE_MAX_ENTRY_MB", 16)) << 20,
+				TTL:            getEnvAsDurationSeconds("SEGMENT_CACHE_TTL_SEC", 600),
+				DiskDir:        getEnv("SEGMENT_CACHE_DISK_DIR", ""),
+				MaxDiskBytes:   int64(getEnvAsInt("SEGMENT_CACHE_MAX_DISK_MB", 2048)) << 20,
+			},
+			PlaylistCache: cache.PlaylistCacheConfig{
+				Enabled:    getEnvAsBool("PLAYLIST_CACHE_ENABLED", true),
+				VODTTL:     getEnvAsDurationSeconds("PLAYLIST_CACHE_VOD_TTL_SEC", 3600),
+				MaxEntries: getEnvAsInt("PLAYLIST_CACHE_MAX_ENTRIES", 1024),
+			},
+			SourceCheckInterval: getEnvAsDurationSeconds("STREAM_SOURCE_CHECK_INTERVAL_SEC", 300),
+			RenditionPolicies:   getEnv("STREAM_RENDITION_POLICIES", ""),
+			CaptionCache: cache.CaptionCacheConfig{
+				Enabled:    getEnvAsBool("CAPTION_CACHE_ENABLED", true),
+				TTL:        getEnvAsDurationSeconds("CAPTION_CACHE_TTL_SEC", 3600),
+				MaxEntries: getEnvAsInt("CAPTION_CACHE_MAX_ENTRIES", 256),
+			},
+			TokenKeys:     getEnv("STREAM_TOKEN_KEYS", ""),
+			SessionMaxAge: getEnvAsDurationSeconds("STREAM_SESSION_MAX_SEC", 6*60*60),
+		},
+		Admin: AdminConfig{
+			Tokens: getEnv("ADMIN_API_TOKENS", ""),
 		},
 	}, nil
 }
@@ -180,3 +290,24 @@ func getEnvAsDurationSeconds(key string, fallback int) time.Duration {
 
 	return time.Duration(value) * time.Second
 }
+
+// getEnvAsPrefixes reads a comma separated list of CIDRs or single IPs. An
+// entry that does not parse is an error rather than skipped: a typo in a
+// trusted proxy would otherwise silently change who viewers are taken for.
+func getEnvAsPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			return nil, fmt.Errorf("%s: %q is neither a CIDR nor an IP address", key, entry)
+		}
+	}
+	return prefixes, nil
+}
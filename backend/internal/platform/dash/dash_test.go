@@ -0,0 +1,127 @@
+package dash
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type recordingProxy struct{}
+
+func (recordingProxy) Resource(target *url.URL) string {
+	return "/proxy?u=" + target.String()
+}
+
+func (recordingProxy) Template(target string, identifiers []string) string {
+	return "/proxy-template?u=" + target + "&ids=" + strings.Join(identifiers, ",")
+}
+
+const sampleMPD = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" type="static">
+  <Location>https://origin.example.com/live/manifest.mpd</Location>
+  <BaseURL>https://cdn.example.com/vod/</BaseURL>
+  <Period id="p0">
+    <AdaptationSet mimeType="video/mp4">
+      <cenc:pssh>AAAA</cenc:pssh>
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%05d$.m4s" initialization="$RepresentationID$/init.mp4" startNumber="1"/>
+      <Representation id="v720" bandwidth="3000000"/>
+      <Representation id="v1080" bandwidth="6000000">
+        <BaseURL>hd/</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="128000">
+        <BaseURL>audio/en.mp4</BaseURL>
+        <SegmentBase indexRange="800-1200"><Initialization range="0-799"/></SegmentBase>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="text/vtt">
+      <Representation id="s1" bandwidth="1000">
+        <SegmentList duration="10">
+          <SegmentURL media="subs/1.vtt"/>
+          <SegmentURL media="https://subs.example.com/2.vtt"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestRewriteManifest(t *testing.T) {
+	doc, err := Decode(strings.NewReader(sampleMPD), 0)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	manifestURL, _ := url.Parse("https://origin.example.com/movie/manifest.mpd")
+	doc.Rewrite(manifestURL, recordingProxy{})
+
+	var out strings.Builder
+	if err := doc.Encode(&out); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	rewritten := out.String()
+
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" type="static">`,
+		`<cenc:pssh>AAAA</cenc:pssh>`,
+		`media="/proxy-template?u=https://cdn.example.com/vod/$RepresentationID$/seg-$Number%05d$.m4s&amp;ids=$RepresentationID$,$Number%05d$"`,
+		`media="/proxy-template?u=https://cdn.example.com/vod/hd/$RepresentationID$/seg-$Number%05d$.m4s&amp;ids=$RepresentationID$,$Number%05d$"`,
+		`<BaseURL>/proxy?u=https://cdn.example.com/vod/audio/en.mp4</BaseURL>`,
+		`<Initialization range="0-799" sourceURL="/proxy?u=https://cdn.example.com/vod/audio/en.mp4"/>`,
+		`<SegmentURL media="/proxy?u=https://cdn.example.com/vod/subs/1.vtt"/>`,
+		`<SegmentURL media="/proxy?u=https://subs.example.com/2.vtt"/>`,
+	} {
+		if !strings.Contains(rewritten, want) {
+			t.Fatalf("expected rewritten manifest to contain %s:\n%s", want, rewritten)
+		}
+	}
+	for _, leaked := range []string{"<Location>", "<BaseURL>https://", "<BaseURL>hd/"} {
+		if strings.Contains(rewritten, leaked) {
+			t.Fatalf("expected %s to be removed:\n%s", leaked, rewritten)
+		}
+	}
+}
+
+func TestDecodeRejectsNonManifests(t *testing.T) {
+	for _, body := range []string{
+		"",
+		"#EXTM3U\n#EXTINF:4,\nseg.ts\n",
+		"<html><body>Access denied</body></html>",
+		"<MPD><Period></MPD>",
+	} {
+		if _, err := Decode(strings.NewReader(body), 0); !errors.Is(err, ErrNotMPD) {
+			t.Fatalf("%q: expected ErrNotMPD, got %v", body, err)
+		}
+	}
+
+	if _, err := Decode(strings.NewReader(sampleMPD), 256); !errors.Is(err, ErrManifestTooLarge) {
+		t.Fatalf("expected ErrManifestTooLarge, got %v", err)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	template := "https://cdn.example.com/$RepresentationID$/seg-$Number%05d$-$$.m4s"
+	if got := TemplateIdentifiers(template); strings.Join(got, ",") != "$RepresentationID$,$Number%05d$" {
+		t.Fatalf("unexpected identifiers %v", got)
+	}
+
+	expanded, err := ExpandTemplate(template, []string{"v720", "00042"})
+	if err != nil {
+		t.Fatalf("ExpandTemplate returned error: %v", err)
+	}
+	if expanded != "https://cdn.example.com/v720/seg-00042-$.m4s" {
+		t.Fatalf("unexpected expansion %q", expanded)
+	}
+
+	for _, values := range [][]string{
+		{"../admin", "1"},
+		{"..", "1"},
+		{"v720", "1/../../x"},
+		{"v720"},
+	} {
+		if _, err := ExpandTemplate(template, values); !errors.Is(err, ErrInvalidTemplateValue) {
+			t.Fatalf("%q: expected ErrInvalidTemplateValue, got %v", values, err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package dash
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotMPD           = errors.New("body is not a DASH manifest")
+	ErrManifestTooLarge = errors.New("manifest exceeds size limit")
+)
+
+// Element is a node of an MPD document. Names and attributes keep their
+// original namespace prefixes so a rewritten manifest only differs from the
+// upstream one where URLs were changed.
+type Element struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []any // *Element, xml.CharData, xml.Comment, xml.ProcInst or xml.Directive
+}
+
+// Document is a parsed MPD. Prolog holds everything before the root element.
+type Document struct {
+	Prolog []any
+	Root   *Element
+}
+
+// Decode parses an MPD from r, failing with ErrManifestTooLarge once more
+// than maxBytes have been read. A maxBytes of zero disables the limit.
+func Decode(r io.Reader, maxBytes int64) (*Document, error) {
+	counter := &countingReader{r: r, limit: maxBytes}
+	decoder := xml.NewDecoder(counter)
+	decoder.Strict = true
+
+	doc := &Document{}
+	var stack []*Element
+	for {
+		token, err := decoder.RawToken()
+		if counter.exceeded {
+			return nil, ErrManifestTooLarge
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrNotMPD
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &Element{Name: t.Name, Attrs: append([]xml.Attr(nil), t.Attr...)}
+			if len(stack) == 0 {
+				if doc.Root != nil || t.Name.Local != "MPD" {
+					return nil, ErrNotMPD
+				}
+				doc.Root = el
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, el)
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, ErrNotMPD
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			node := copyToken(token)
+			if len(stack) == 0 {
+				if doc.Root == nil {
+					doc.Prolog = append(doc.Prolog, node)
+				}
+				continue
+			}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	if doc.Root == nil || len(stack) != 0 {
+		return nil, ErrNotMPD
+	}
+	return doc, nil
+}
+
+// Encode writes the document to w.
+func (d *Document) Encode(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for _, node := range d.Prolog {
+		writeNode(out, node)
+	}
+	writeNode(out, d.Root)
+	return out.Flush()
+}
+
+// Attr returns the value of the unprefixed attribute local.
+func (e *Element) Attr(local string) (string, bool) {
+	for _, attr := range e.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (e *Element) SetAttr(local, value string) {
+	for i, attr := range e.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			e.Attrs[i].Value = value
+			return
+		}
+	}
+	e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: local}, Value: value})
+}
+
+// Elements returns the child elements named local, ignoring prefixes.
+func (e *Element) Elements(local string) []*Element {
+	var found []*Element
+	for _, child := range e.Children {
+		if el, ok := child.(*Element); ok && el.Name.Local == local {
+			found = append(found, el)
+		}
+	}
+	return found
+}
+
+// Text returns the concatenated character data of the element.
+func (e *Element) Text() string {
+	var builder strings.Builder
+	for _, child := range e.Children {
+		if data, ok := child.(xml.CharData); ok {
+			builder.Write(data)
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+func (e *Element) SetText(text string) {
+	e.Children = []any{xml.CharData(text)}
+}
+
+// Clone returns a deep copy of the element.
+func (e *Element) Clone() *Element {
+	clone := &Element{Name: e.Name, Attrs: append([]xml.Attr(nil), e.Attrs...)}
+	for _, child := range e.Children {
+		if el, ok := child.(*Element); ok {
+			clone.Children = append(clone.Children, el.Clone())
+		} else {
+			clone.Children = append(clone.Children, child)
+		}
+	}
+	return clone
+}
+
+func (e *Element) removeChildren(local string) {
+	kept := e.Children[:0]
+	for _, child := range e.Children {
+		if el, ok := child.(*Element); ok && el.Name.Local == local {
+			continue
+		}
+		kept = append(kept, child)
+	}
+	e.Children = kept
+}
+
+func (e *Element) prepend(child *Element) {
+	e.Children = append([]any{child}, e.Children...)
+}
+
+func copyToken(token xml.Token) any {
+	switch t := token.(type) {
+	case xml.CharData:
+		return t.Copy()
+	case xml.Comment:
+		return t.Copy()
+	case xml.ProcInst:
+		return t.Copy()
+	case xml.Directive:
+		return t.Copy()
+	}
+	return nil
+}
+
+func writeNode(out *bufio.Writer, node any) {
+	switch n := node.(type) {
+	case *Element:
+		out.WriteByte('<')
+		out.WriteString(qualified(n.Name))
+		for _, attr := range n.Attrs {
+			out.WriteByte(' ')
+			out.WriteString(qualified(attr.Name))
+			out.WriteString(`="`)
+			out.WriteString(attrEscaper.Replace(attr.Value))
+			out.WriteByte('"')
+		}
+		if len(n.Children) == 0 {
+			out.WriteString("/>")
+			return
+		}
+		out.WriteByte('>')
+		for _, child := range n.Children {
+			writeNode(out, child)
+		}
+		out.WriteString("</")
+		out.WriteString(qualified(n.Name))
+		out.WriteByte('>')
+	case xml.CharData:
+		xml.EscapeText(out, n)
+	case xml.Comment:
+		out.WriteString("<!--")
+		out.Write(n)
+		out.WriteString("-->")
+	case xml.ProcInst:
+		out.WriteString("<?")
+		out.WriteString(n.Target)
+		if len(n.Inst) > 0 {
+			out.WriteByte(' ')
+			out.Write(n.Inst)
+		}
+		out.WriteString("?>")
+	case xml.Directive:
+		out.WriteString("<!")
+		out.Write(n)
+		out.WriteByte('>')
+	}
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var attrEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"\n", "&#xA;",
+	"\r", "&#xD;",
+	"\t", "&#x9;",
+)
+
+type countingReader struct {
+	r        io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	if c.limit > 0 && c.read > c.limit {
+		c.exceeded = true
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
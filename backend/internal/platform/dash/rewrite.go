@@ -0,0 +1,180 @@
+package dash
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Proxy maps the upstream URLs of a manifest to proxied ones.
+type Proxy interface {
+	// Resource returns the proxied URL of a single upstream resource.
+	Resource(target *url.URL) string
+	// Template returns the proxied form of an absolute SegmentTemplate URL.
+	// identifiers lists the template identifiers of target in order; the
+	// proxied form must carry them verbatim so the player can fill them in.
+	Template(target string, identifiers []string) string
+}
+
+// segmentInfoElements carry segment addressing and are inherited from
+// Period and AdaptationSet down to Representation.
+var segmentInfoElements = []string{"SegmentBase", "SegmentList", "SegmentTemplate"}
+
+var templateURLAttrs = []string{"media", "initialization", "index", "bitstreamSwitching"}
+
+// Rewrite points every URL of the manifest at proxy. BaseURL elements are
+// folded into the URLs they apply to and removed, except on Representations
+// addressed by BaseURL alone, where the BaseURL is the media itself. Location
+// and PatchLocation are dropped so the player keeps reloading through the
+// proxy.
+func (d *Document) Rewrite(manifestURL *url.URL, proxy Proxy) {
+	r := rewriter{proxy: proxy}
+	r.walk(d.Root, manifestURL, map[string]*Element{})
+}
+
+type rewriter struct {
+	proxy Proxy
+}
+
+func (r rewriter) walk(el *Element, base *url.URL, inherited map[string]*Element) {
+	baseURLs := el.Elements("BaseURL")
+	if len(baseURLs) > 0 {
+		if rel, err := url.Parse(baseURLs[0].Text()); err == nil {
+			base = base.ResolveReference(rel)
+		}
+	}
+	el.removeChildren("BaseURL")
+	el.removeChildren("Location")
+	el.removeChildren("PatchLocation")
+
+	// A segment info element resolves against the BaseURL in effect where it
+	// appears, so an inherited one is copied down to any level that changes
+	// the base.
+	scope := make(map[string]*Element, len(inherited))
+	for kind, original := range inherited {
+		scope[kind] = original
+	}
+	for _, kind := range segmentInfoElements {
+		own := el.Elements(kind)
+		var original *Element
+		switch {
+		case len(own) > 0:
+			original = merge(own[0], inherited[kind])
+		case inherited[kind] != nil && len(baseURLs) > 0:
+			original = inherited[kind].Clone()
+		default:
+			continue
+		}
+		scope[kind] = original
+
+		rewritten := original.Clone()
+		r.rewriteSegmentInfo(rewritten, base)
+		el.removeChildren(kind)
+		el.Children = append(el.Children, rewritten)
+	}
+
+	for _, child := range el.Children {
+		if next, ok := child.(*Element); ok {
+			switch next.Name.Local {
+			case "Period", "AdaptationSet", "Representation":
+				r.walk(next, base, scope)
+			}
+		}
+	}
+
+	if el.Name.Local == "Representation" && scope["SegmentTemplate"] == nil && scope["SegmentList"] == nil {
+		baseURL := &Element{Name: xml.Name{Space: el.Name.Space, Local: "BaseURL"}}
+		baseURL.SetText(r.proxy.Resource(base))
+		el.prepend(baseURL)
+	}
+}
+
+func (r rewriter) rewriteSegmentInfo(el *Element, base *url.URL) {
+	if el.Name.Local == "SegmentTemplate" {
+		for _, attr := range templateURLAttrs {
+			if value, ok := el.Attr(attr); ok {
+				el.SetAttr(attr, r.template(base, value))
+			}
+		}
+	}
+
+	for _, child := range el.Children {
+		node, ok := child.(*Element)
+		if !ok {
+			continue
+		}
+		switch node.Name.Local {
+		case "Initialization", "RepresentationIndex", "BitstreamSwitching":
+			node.SetAttr("sourceURL", r.resource(base, attrOrEmpty(node, "sourceURL")))
+		case "SegmentURL":
+			node.SetAttr("media", r.resource(base, attrOrEmpty(node, "media")))
+			if index, ok := node.Attr("index"); ok {
+				node.SetAttr("index", r.resource(base, index))
+			}
+		}
+	}
+}
+
+// resource resolves raw against base; an empty raw addresses base itself.
+func (r rewriter) resource(base *url.URL, raw string) string {
+	rel, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return r.proxy.Resource(base.ResolveReference(rel))
+}
+
+// template resolves a SegmentTemplate URL against base without touching its
+// identifiers, whose printf widths would otherwise be read as escapes.
+func (r rewriter) template(base *url.URL, raw string) string {
+	identifiers := TemplateIdentifiers(raw)
+	if len(identifiers) == 0 {
+		return r.resource(base, strings.ReplaceAll(raw, "$$", "$"))
+	}
+
+	masked := strings.ReplaceAll(raw, "$$", "\x00")
+	for i, identifier := range identifiers {
+		masked = strings.Replace(masked, identifier, placeholder(i), 1)
+	}
+	masked = strings.ReplaceAll(masked, "\x00", "$$")
+
+	rel, err := url.Parse(masked)
+	if err != nil {
+		return raw
+	}
+	resolved := base.ResolveReference(rel).String()
+	for i, identifier := range identifiers {
+		resolved = strings.Replace(resolved, placeholder(i), identifier, 1)
+	}
+	return r.proxy.Template(resolved, identifiers)
+}
+
+func placeholder(i int) string {
+	return fmt.Sprintf("__dash_identifier_%d__", i)
+}
+
+// merge completes own with the attributes and child elements it inherits
+// from parent.
+func merge(own, parent *Element) *Element {
+	merged := own.Clone()
+	if parent == nil {
+		return merged
+	}
+	for _, attr := range parent.Attrs {
+		if _, ok := merged.Attr(attr.Name.Local); !ok && attr.Name.Space == "" {
+			merged.Attrs = append(merged.Attrs, attr)
+		}
+	}
+	for _, child := range parent.Children {
+		if node, ok := child.(*Element); ok && len(merged.Elements(node.Name.Local)) == 0 {
+			merged.Children = append(merged.Children, node.Clone())
+		}
+	}
+	return merged
+}
+
+func attrOrEmpty(el *Element, local string) string {
+	value, _ := el.Attr(local)
+	return value
+}
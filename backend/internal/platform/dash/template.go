@@ -0,0 +1,63 @@
+package dash
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidTemplateValue = errors.New("invalid segment template value")
+
+// templateIdentifier matches the SegmentTemplate identifiers of ISO/IEC
+// 23009-1 5.3.9.4.4, including an optional printf width such as %05d.
+// $$ is an escaped dollar sign and not an identifier.
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time|SubNumber)(%0\d+d)?\$`)
+
+var (
+	numericValue    = regexp.MustCompile(`^\d{1,20}$`)
+	identifierValue = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,128}$`)
+)
+
+// TemplateIdentifiers returns the identifiers of template in order, for
+// example ["$RepresentationID$", "$Number%05d$"].
+func TemplateIdentifiers(template string) []string {
+	return templateIdentifier.FindAllString(unescapedDollars(template), -1)
+}
+
+// ExpandTemplate substitutes values, as filled in by the player, for the
+// identifiers of template in order. Numeric identifiers only accept digits
+// and $RepresentationID$ only URL-safe characters, so a value can never leave
+// the path segment it was placed in.
+func ExpandTemplate(template string, values []string) (string, error) {
+	masked := unescapedDollars(template)
+	matches := templateIdentifier.FindAllStringSubmatchIndex(masked, -1)
+	if len(matches) != len(values) {
+		return "", fmt.Errorf("%w: expected %d values, got %d", ErrInvalidTemplateValue, len(matches), len(values))
+	}
+
+	var builder strings.Builder
+	last := 0
+	for i, match := range matches {
+		name := masked[match[2]:match[3]]
+		value := values[i]
+		if name == "RepresentationID" {
+			if !identifierValue.MatchString(value) || strings.Trim(value, ".") == "" {
+				return "", fmt.Errorf("%w: %s %q", ErrInvalidTemplateValue, name, value)
+			}
+		} else if !numericValue.MatchString(value) {
+			return "", fmt.Errorf("%w: %s %q", ErrInvalidTemplateValue, name, value)
+		}
+		builder.WriteString(template[last:match[0]])
+		builder.WriteString(value)
+		last = match[1]
+	}
+	builder.WriteString(template[last:])
+	return strings.ReplaceAll(builder.String(), "$$", "$"), nil
+}
+
+// unescapedDollars hides $$ escapes so they are not mistaken for identifier
+// delimiters. The result has the same length as template.
+func unescapedDollars(template string) string {
+	return strings.ReplaceAll(template, "$$", "\x00\x00")
+}
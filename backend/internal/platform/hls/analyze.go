@@ -0,0 +1,208 @@
+package hls
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variant is a rendition of a master playlist, announced by
+// EXT-X-STREAM-INF.
+type Variant struct {
+	URI        string
+	Bandwidth  int64
+	Resolution string
+	Codecs     string
+	Audio      string
+	Subtitles  string
+}
+
+// Media is an alternative audio, subtitle or closed caption rendition of a
+// master playlist, announced by EXT-X-MEDIA.
+type Media struct {
+	Type     string
+	GroupID  string
+	Name     string
+	Language string
+	Default  bool
+}
+
+// Variants returns the EXT-X-STREAM-INF renditions of a master playlist in
+// playlist order. I-frame only renditions are left out.
+func (p Playlist) Variants() []Variant {
+	var (
+		variants []Variant
+		pending  *Variant
+	)
+	for _, line := range p.Lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			if pending != nil {
+				pending.URI = line
+				variants = append(variants, *pending)
+				pending = nil
+			}
+			continue
+		}
+
+		name, value := SplitTag(line)
+		if name != "#EXT-X-STREAM-INF" {
+			continue
+		}
+		attrs, err := ParseAttributeList(value)
+		if err != nil {
+			continue
+		}
+		variant := parseVariant(attrs)
+		pending = &variant
+	}
+	return variants
+}
+
+// IFrameVariants returns the EXT-X-I-FRAME-STREAM-INF renditions of a
+// master playlist in playlist order.
+func (p Playlist) IFrameVariants() []Variant {
+	var variants []Variant
+	for _, line := range p.Lines {
+		name, value := SplitTag(strings.TrimSpace(line))
+		if name != "#EXT-X-I-FRAME-STREAM-INF" {
+			continue
+		}
+		if attrs, err := ParseAttributeList(value); err == nil {
+			variants = append(variants, parseVariant(attrs))
+		}
+	}
+	return variants
+}
+
+// FilterVariants returns p without the EXT-X-STREAM-INF and
+// EXT-X-I-FRAME-STREAM-INF renditions keep rejects. When every
+// EXT-X-STREAM-INF rendition is rejected the one with the lowest bandwidth is
+// kept, so the result stays a playable master playlist.
+func (p Playlist) FilterVariants(keep func(Variant) bool) Playlist {
+	variants := p.Variants()
+	kept := make([]bool, len(variants))
+	keptAny := false
+	for i, variant := range variants {
+		kept[i] = keep(variant)
+		keptAny = keptAny || kept[i]
+	}
+	if !keptAny && len(variants) > 0 {
+		lowest := 0
+		for i, variant := range variants {
+			if variant.Bandwidth < variants[lowest].Bandwidth {
+				lowest = i
+			}
+		}
+		kept[lowest] = true
+	}
+
+	filtered := p
+	filtered.Lines = make([]string, 0, len(p.Lines))
+	index, dropURI := 0, false
+	for _, line := range p.Lines {
+		trimmed := strings.TrimSpace(line)
+		name, value := SplitTag(trimmed)
+		switch {
+		case name == "#EXT-X-STREAM-INF":
+			if _, err := ParseAttributeList(value); err == nil && index < len(kept) {
+				dropURI = !kept[index]
+				index++
+				if dropURI {
+					continue
+				}
+			}
+		case name == "#EXT-X-I-FRAME-STREAM-INF":
+			if attrs, err := ParseAttributeList(value); err == nil && !keep(parseVariant(attrs)) {
+				continue
+			}
+		case trimmed != "" && !strings.HasPrefix(trimmed, "#"):
+			drop := dropURI
+			dropURI = false
+			if drop {
+				continue
+			}
+		}
+		filtered.Lines = append(filtered.Lines, line)
+	}
+	return filtered
+}
+
+// parseVariant reads the attributes of an EXT-X-STREAM-INF or
+// EXT-X-I-FRAME-STREAM-INF tag; the URI attribute of the latter becomes the
+// variant URI.
+func parseVariant(attrs AttributeList) Variant {
+	variant := Variant{}
+	if raw, ok := attrs.Get("BANDWIDTH"); ok {
+		variant.Bandwidth, _ = strconv.ParseInt(raw, 10, 64)
+	}
+	variant.URI, _ = attrs.Get("URI")
+	variant.Resolution, _ = attrs.Get("RESOLUTION")
+	variant.Codecs, _ = attrs.Get("CODECS")
+	variant.Audio, _ = attrs.Get("AUDIO")
+	variant.Subtitles, _ = attrs.Get("SUBTITLES")
+	return variant
+}
+
+// Media returns the EXT-X-MEDIA renditions of a master playlist.
+func (p Playlist) Media() []Media {
+	var media []Media
+	for _, line := range p.Lines {
+		name, value := SplitTag(strings.TrimSpace(line))
+		if name != "#EXT-X-MEDIA" {
+			continue
+		}
+		attrs, err := ParseAttributeList(value)
+		if err != nil {
+			continue
+		}
+		rendition := Media{}
+		rendition.Type, _ = attrs.Get("TYPE")
+		rendition.GroupID, _ = attrs.Get("GROUP-ID")
+		rendition.Name, _ = attrs.Get("NAME")
+		rendition.Language, _ = attrs.Get("LANGUAGE")
+		if value, ok := attrs.Get("DEFAULT"); ok {
+			rendition.Default = value == "YES"
+		}
+		media = append(media, rendition)
+	}
+	return media
+}
+
+// Duration returns the sum of the EXTINF durations of a media playlist.
+func (p Playlist) Duration() time.Duration {
+	var total time.Duration
+	for _, line := range p.Lines {
+		name, value := SplitTag(strings.TrimSpace(line))
+		if name != "#EXTINF" {
+			continue
+		}
+		if comma := strings.IndexByte(value, ','); comma >= 0 {
+			value = value[:comma]
+		}
+		total += parseSeconds(value)
+	}
+	return total
+}
+
+// EncryptionMethod returns the METHOD of the first EXT-X-KEY or
+// EXT-X-SESSION-KEY tag, or NONE for a clear playlist.
+func (p Playlist) EncryptionMethod() string {
+	for _, line := range p.Lines {
+		name, value := SplitTag(strings.TrimSpace(line))
+		if name != "#EXT-X-KEY" && name != "#EXT-X-SESSION-KEY" {
+			continue
+		}
+		attrs, err := ParseAttributeList(value)
+		if err != nil {
+			continue
+		}
+		if method, ok := attrs.Get("METHOD"); ok {
+			return method
+		}
+	}
+	return "NONE"
+}
@@ -0,0 +1,78 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMasterPlaylistRenditions(t *testing.T) {
+	playlist := Parse("#EXTM3U\n" +
+		"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"Thai\",LANGUAGE=\"th\",DEFAULT=YES,URI=\"audio/th.m3u8\"\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"English\",LANGUAGE=\"en\",URI=\"subs/en.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\",AUDIO=\"aud\",SUBTITLES=\"subs\"\n" +
+		"low/index.m3u8\n" +
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=90000,URI=\"low/iframes.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720\n" +
+		"high/index.m3u8\n")
+
+	variants := playlist.Variants()
+	if len(variants) != 2 {
+		t.Fatalf("expected 2 variants, got %+v", variants)
+	}
+	want := Variant{URI: "low/index.m3u8", Bandwidth: 800000, Resolution: "640x360", Codecs: "avc1.4d401e,mp4a.40.2", Audio: "aud", Subtitles: "subs"}
+	if variants[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, variants[0])
+	}
+	if variants[1].URI != "high/index.m3u8" || variants[1].Bandwidth != 2400000 {
+		t.Fatalf("unexpected second variant %+v", variants[1])
+	}
+
+	media := playlist.Media()
+	if len(media) != 2 || media[0] != (Media{Type: "AUDIO", GroupID: "aud", Name: "Thai", Language: "th", Default: true}) || media[1].Type != "SUBTITLES" {
+		t.Fatalf("unexpected media renditions %+v", media)
+	}
+}
+
+func TestMediaPlaylistDurationAndEncryption(t *testing.T) {
+	playlist := Parse("#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n" +
+		"#EXTINF:6.006,\nseg0.ts\n#EXTINF:5.5,title\nseg1.ts\n#EXT-X-ENDLIST\n")
+
+	if got, want := playlist.Duration(), 11506*time.Millisecond; got != want {
+		t.Fatalf("expected duration %s, got %s", want, got)
+	}
+	if method := playlist.EncryptionMethod(); method != "AES-128" {
+		t.Fatalf("expected AES-128, got %q", method)
+	}
+	if method := Parse("#EXTM3U\n#EXTINF:4,\nseg.ts\n").EncryptionMethod(); method != "NONE" {
+		t.Fatalf("expected NONE for a clear playlist, got %q", method)
+	}
+}
+
+func TestFilterVariants(t *testing.T) {
+	playlist := Parse("#EXTM3U\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080\nhigh/index.m3u8\n" +
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=90000,RESOLUTION=640x360,URI=\"low/iframes.m3u8\"\n" +
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=400000,RESOLUTION=1920x1080,URI=\"high/iframes.m3u8\"\n")
+
+	filtered := playlist.FilterVariants(func(v Variant) bool { return v.Resolution != "1920x1080" })
+	want := "#EXTM3U\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n" +
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=90000,RESOLUTION=640x360,URI=\"low/iframes.m3u8\"\n"
+	if got := strings.Join(filtered.Lines, "\n") + "\n"; got != want {
+		t.Fatalf("unexpected filtered playlist:\n%s", got)
+	}
+	if !filtered.Master {
+		t.Fatalf("expected the filtered playlist to stay a master playlist")
+	}
+	if iframes := filtered.IFrameVariants(); len(iframes) != 1 || iframes[0].URI != "low/iframes.m3u8" {
+		t.Fatalf("unexpected I-frame variants %+v", iframes)
+	}
+
+	fallback := playlist.FilterVariants(func(Variant) bool { return false })
+	if variants := fallback.Variants(); len(variants) != 1 || variants[0].URI != "low/index.m3u8" {
+		t.Fatalf("expected the lowest variant to be kept, got %+v", variants)
+	}
+}
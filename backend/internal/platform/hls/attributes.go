@@ -0,0 +1,131 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidAttributeList = errors.New("invalid attribute list")
+
+// Attribute is a single AttributeName=AttributeValue pair from an HLS tag.
+// Quoted records whether the value was a quoted-string so it can be written
+// back in the same form.
+type Attribute struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+// AttributeList keeps attributes in their original order so a rewritten tag
+// only differs from the upstream one where values were changed.
+type AttributeList []Attribute
+
+func (l AttributeList) Get(key string) (string, bool) {
+	for _, attr := range l {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (l AttributeList) Set(key, value string, quoted bool) AttributeList {
+	for i := range l {
+		if l[i].Key == key {
+			l[i].Value = value
+			l[i].Quoted = quoted
+			return l
+		}
+	}
+	return append(l, Attribute{Key: key, Value: value, Quoted: quoted})
+}
+
+func (l AttributeList) String() string {
+	var builder strings.Builder
+	for i, attr := range l {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(attr.Key)
+		builder.WriteByte('=')
+		if attr.Quoted {
+			builder.WriteByte('"')
+			builder.WriteString(attr.Value)
+			builder.WriteByte('"')
+		} else {
+			builder.WriteString(attr.Value)
+		}
+	}
+	return builder.String()
+}
+
+// ParseAttributeList parses an attribute-list as defined in RFC 8216 section
+// 4.2. Commas inside quoted-string values do not split attributes.
+func ParseAttributeList(raw string) (AttributeList, error) {
+	list := make(AttributeList, 0, 4)
+	rest := strings.TrimSpace(raw)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, ErrInvalidAttributeList
+		}
+		key := strings.TrimSpace(rest[:eq])
+		if !isAttributeName(key) {
+			return nil, ErrInvalidAttributeList
+		}
+		rest = rest[eq+1:]
+
+		var attr Attribute
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, ErrInvalidAttributeList
+			}
+			attr = Attribute{Key: key, Value: rest[1 : end+1], Quoted: true}
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value := strings.TrimSpace(rest[:end])
+			if value == "" || strings.ContainsAny(value, "\" =\t") {
+				return nil, ErrInvalidAttributeList
+			}
+			attr = Attribute{Key: key, Value: value}
+			rest = rest[end:]
+		}
+		list = append(list, attr)
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, ErrInvalidAttributeList
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return list, nil
+}
+
+// SplitTag splits a tag line such as "#EXT-X-KEY:METHOD=NONE" into its name
+// and value. Tags without a value return an empty value.
+func SplitTag(line string) (string, string) {
+	if idx := strings.IndexByte(line, ':'); idx >= 0 {
+		return line[:idx], line[idx+1:]
+	}
+	return line, ""
+}
+
+func isAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
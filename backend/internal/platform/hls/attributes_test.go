@@ -0,0 +1,33 @@
+package hls
+
+import "testing"
+
+func TestParseAttributeListQuotedCommas(t *testing.T) {
+	list, err := ParseAttributeList(`TYPE=AUDIO,GROUP-ID="aud",NAME="English, Stereo",DEFAULT=YES,URI="audio/en.m3u8"`)
+	if err != nil {
+		t.Fatalf("ParseAttributeList returned error: %v", err)
+	}
+	if len(list) != 5 {
+		t.Fatalf("expected 5 attributes, got %d: %+v", len(list), list)
+	}
+	if name, _ := list.Get("NAME"); name != "English, Stereo" {
+		t.Fatalf("unexpected NAME %q", name)
+	}
+	if uri, _ := list.Get("URI"); uri != "audio/en.m3u8" {
+		t.Fatalf("unexpected URI %q", uri)
+	}
+
+	list = list.Set("URI", "/proxied?a=1&b=2", true)
+	want := `TYPE=AUDIO,GROUP-ID="aud",NAME="English, Stereo",DEFAULT=YES,URI="/proxied?a=1&b=2"`
+	if got := list.String(); got != want {
+		t.Fatalf("unexpected serialisation:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestParseAttributeListRejectsMalformed(t *testing.T) {
+	for _, raw := range []string{`URI="unterminated`, `=value`, `lower=1`, `A=1 B=2`} {
+		if _, err := ParseAttributeList(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package hls
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidKeySize = errors.New("AES-128 key must be 16 bytes")
+
+// SequenceIV returns the IV a player uses for an AES-128 segment whose
+// #EXT-X-KEY has no IV attribute: the media sequence number as a 128-bit
+// big-endian integer (RFC 8216 section 5.2).
+func SequenceIV(sequence uint64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// EncryptSegment encrypts a whole media segment with AES-128-CBC and PKCS#7
+// padding as required for METHOD=AES-128.
+func EncryptSegment(key, iv, segment []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, ErrInvalidKeySize
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := aes.BlockSize - len(segment)%aes.BlockSize
+	out := make([]byte, len(segment)+padding)
+	copy(out, segment)
+	for i := len(segment); i < len(out); i++ {
+		out[i] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out, nil
+}
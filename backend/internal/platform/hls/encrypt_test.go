@@ -0,0 +1,43 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestSequenceIV(t *testing.T) {
+	iv := SequenceIV(0x0102)
+	want := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}
+	if !bytes.Equal(iv, want) {
+		t.Fatalf("unexpected IV %x", iv)
+	}
+}
+
+func TestEncryptSegmentRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 16)
+	iv := SequenceIV(42)
+	for _, size := range []int{0, 15, 16, 188 * 7} {
+		segment := bytes.Repeat([]byte{0x47}, size)
+		encrypted, err := EncryptSegment(key, iv, segment)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		if len(encrypted)%aes.BlockSize != 0 || len(encrypted) <= size {
+			t.Fatalf("unexpected ciphertext length %d for %d bytes", len(encrypted), size)
+		}
+
+		block, _ := aes.NewCipher(key)
+		plain := make([]byte, len(encrypted))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encrypted)
+		padding := int(plain[len(plain)-1])
+		if !bytes.Equal(plain[:len(plain)-padding], segment) {
+			t.Fatalf("round trip mismatch for %d bytes", size)
+		}
+	}
+
+	if _, err := EncryptSegment([]byte("short"), iv, nil); err != ErrInvalidKeySize {
+		t.Fatalf("expected ErrInvalidKeySize, got %v", err)
+	}
+}
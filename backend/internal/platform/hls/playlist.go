@@ -0,0 +1,165 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Playlist is an upstream playlist split into lines together with the few
+// properties needed to decide how long it may be cached.
+type Playlist struct {
+	Lines                 []string
+	Master                bool
+	EndList               bool
+	TargetDuration        time.Duration
+	MediaSequence         int64
+	DiscontinuitySequence int64
+	// SkippedSegments is the number of segments an #EXT-X-SKIP of a delta
+	// update leaves out after MediaSequence.
+	SkippedSegments int64
+	PartTarget      time.Duration
+	CanBlockReload  bool
+}
+
+var (
+	ErrNotPlaylist      = errors.New("body is not an HLS playlist")
+	ErrPlaylistTooLarge = errors.New("playlist exceeds size limit")
+	ErrInvalidTag       = errors.New("invalid playlist tag")
+)
+
+func Parse(body string) Playlist {
+	body = strings.TrimPrefix(body, "\ufeff")
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+
+	playlist := Playlist{Lines: lines}
+	for _, line := range lines {
+		playlist.observe(line)
+	}
+	return playlist
+}
+
+// Decode reads a playlist line by line from r. It fails with ErrNotPlaylist
+// unless the body starts with #EXTM3U, with ErrInvalidTag on a malformed tag
+// and with ErrPlaylistTooLarge as soon as more than maxBytes have been read.
+// A maxBytes of zero disables the size limit.
+func Decode(r io.Reader, maxBytes int64) (Playlist, error) {
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+	reader := bufio.NewReader(r)
+
+	var (
+		playlist Playlist
+		read     int64
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		read += int64(len(line))
+		if maxBytes > 0 && read > maxBytes {
+			return Playlist{}, ErrPlaylistTooLarge
+		}
+		if err != nil && err != io.EOF {
+			return Playlist{}, err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if len(playlist.Lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if strings.TrimSpace(line) != "#EXTM3U" {
+				return Playlist{}, ErrNotPlaylist
+			}
+		} else if strings.IndexByte(line, 0) >= 0 {
+			return Playlist{}, ErrNotPlaylist
+		} else if err := ValidateTag(line); err != nil {
+			return Playlist{}, err
+		}
+
+		playlist.Lines = append(playlist.Lines, line)
+		playlist.observe(line)
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(playlist.Lines) == 0 {
+		return Playlist{}, ErrNotPlaylist
+	}
+	return playlist, nil
+}
+
+func (p *Playlist) observe(line string) {
+	name, value := SplitTag(strings.TrimSpace(line))
+	switch name {
+	case "#EXT-X-STREAM-INF", "#EXT-X-I-FRAME-STREAM-INF":
+		p.Master = true
+	case "#EXT-X-ENDLIST":
+		p.EndList = true
+	case "#EXT-X-TARGETDURATION":
+		p.TargetDuration = parseSeconds(value)
+	case "#EXT-X-MEDIA-SEQUENCE":
+		if seq, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			p.MediaSequence = seq
+		}
+	case "#EXT-X-DISCONTINUITY-SEQUENCE":
+		if seq, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			p.DiscontinuitySequence = seq
+		}
+	case "#EXT-X-SKIP":
+		if attrs, err := ParseAttributeList(value); err == nil {
+			if skipped, ok := attrs.Get("SKIPPED-SEGMENTS"); ok {
+				if n, err := strconv.ParseInt(skipped, 10, 64); err == nil && n > 0 {
+					p.SkippedSegments = n
+				}
+			}
+		}
+	case "#EXT-X-PART-INF":
+		if attrs, err := ParseAttributeList(value); err == nil {
+			if target, ok := attrs.Get("PART-TARGET"); ok {
+				p.PartTarget = parseSeconds(target)
+			}
+		}
+	case "#EXT-X-SERVER-CONTROL":
+		if attrs, err := ParseAttributeList(value); err == nil {
+			if block, ok := attrs.Get("CAN-BLOCK-RELOAD"); ok {
+				p.CanBlockReload = block == "YES"
+			}
+		}
+	}
+}
+
+// Older reports whether p is behind other in the live timeline, which
+// happens when a lagging CDN edge answers with a stale copy.
+func (p Playlist) Older(other Playlist) bool {
+	if p.DiscontinuitySequence != other.DiscontinuitySequence {
+		return p.DiscontinuitySequence < other.DiscontinuitySequence
+	}
+	return p.MediaSequence < other.MediaSequence
+}
+
+// FirstListedSequence returns the media sequence number of the first segment
+// listed in the playlist, which follows the skipped ones in a delta update.
+func (p Playlist) FirstListedSequence() int64 {
+	return p.MediaSequence + p.SkippedSegments
+}
+
+// Live reports whether the playlist is a media playlist that may still grow.
+func (p Playlist) Live() bool {
+	return !p.Master && !p.EndList
+}
+
+func parseSeconds(raw string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,76 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMediaPlaylist(t *testing.T) {
+	body := "\ufeff#EXTM3U\r\n#EXT-X-TARGETDURATION:6\r\n#EXT-X-MEDIA-SEQUENCE:42\r\n#EXTINF:6.0,\r\nseg42.ts\r\n#EXT-X-ENDLIST\r\n"
+
+	playlist, err := Decode(strings.NewReader(body), 0)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if len(playlist.Lines) != 6 || playlist.Lines[0] != "#EXTM3U" || playlist.Lines[4] != "seg42.ts" {
+		t.Fatalf("unexpected lines %q", playlist.Lines)
+	}
+	if playlist.MediaSequence != 42 || !playlist.EndList || playlist.Live() {
+		t.Fatalf("unexpected playlist properties %+v", playlist)
+	}
+}
+
+func TestDecodeDeltaPlaylist(t *testing.T) {
+	body := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:100\n#EXT-X-SKIP:SKIPPED-SEGMENTS=7\n#EXTINF:4,\nseg107.ts\n"
+
+	playlist, err := Decode(strings.NewReader(body), 0)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if playlist.SkippedSegments != 7 || playlist.FirstListedSequence() != 107 {
+		t.Fatalf("expected the first listed segment to be 107, got %+v", playlist)
+	}
+	if full := Parse("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:100\n#EXTINF:4,\nseg100.ts\n"); full.FirstListedSequence() != 100 {
+		t.Fatalf("expected a full playlist to start at its media sequence, got %d", full.FirstListedSequence())
+	}
+}
+
+func TestDecodeRejectsNonPlaylists(t *testing.T) {
+	for _, body := range []string{
+		"",
+		"<!DOCTYPE html><html><body>Access denied</body></html>",
+		"\n#EXTM3U\n",
+		"#EXTM3U\nseg\x00.ts\n",
+	} {
+		if _, err := Decode(strings.NewReader(body), 0); !errors.Is(err, ErrNotPlaylist) {
+			t.Fatalf("%q: expected ErrNotPlaylist, got %v", body, err)
+		}
+	}
+}
+
+func TestDecodeRejectsInvalidTags(t *testing.T) {
+	for _, line := range []string{
+		"#EXTINF:abc,",
+		"#EXT-X-TARGETDURATION:six",
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"unterminated",
+		"#EXT-X-BYTERANGE:100@x",
+		"#EXT-X-bad_name:1",
+	} {
+		body := "#EXTM3U\n" + line + "\nseg.ts\n"
+		if _, err := Decode(strings.NewReader(body), 0); !errors.Is(err, ErrInvalidTag) {
+			t.Fatalf("%q: expected ErrInvalidTag, got %v", line, err)
+		}
+	}
+}
+
+func TestDecodeEnforcesSizeLimit(t *testing.T) {
+	body := "#EXTM3U\n" + strings.Repeat("#EXTINF:4,\nsegment.ts\n", 100)
+
+	if _, err := Decode(strings.NewReader(body), int64(len(body))); err != nil {
+		t.Fatalf("expected body at the limit to decode, got %v", err)
+	}
+	if _, err := Decode(strings.NewReader(body), int64(len(body))-1); !errors.Is(err, ErrPlaylistTooLarge) {
+		t.Fatalf("expected ErrPlaylistTooLarge, got %v", err)
+	}
+}
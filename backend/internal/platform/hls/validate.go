@@ -0,0 +1,88 @@
+package hls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// attributeListTags carry an attribute list as their value.
+var attributeListTags = map[string]struct{}{
+	"#EXT-X-STREAM-INF":         {},
+	"#EXT-X-I-FRAME-STREAM-INF": {},
+	"#EXT-X-MEDIA":              {},
+	"#EXT-X-KEY":                {},
+	"#EXT-X-SESSION-KEY":        {},
+	"#EXT-X-SESSION-DATA":       {},
+	"#EXT-X-MAP":                {},
+	"#EXT-X-PART":               {},
+	"#EXT-X-PART-INF":           {},
+	"#EXT-X-PRELOAD-HINT":       {},
+	"#EXT-X-RENDITION-REPORT":   {},
+	"#EXT-X-SERVER-CONTROL":     {},
+	"#EXT-X-SKIP":               {},
+	"#EXT-X-DATERANGE":          {},
+	"#EXT-X-START":              {},
+	"#EXT-X-DEFINE":             {},
+	"#EXT-X-CONTENT-STEERING":   {},
+}
+
+// integerTags carry a single decimal integer.
+var integerTags = map[string]struct{}{
+	"#EXT-X-VERSION":                {},
+	"#EXT-X-TARGETDURATION":         {},
+	"#EXT-X-MEDIA-SEQUENCE":         {},
+	"#EXT-X-DISCONTINUITY-SEQUENCE": {},
+}
+
+// ValidateTag checks the syntax of a playlist line starting with #EXT.
+// Comments, URI lines and tags this package does not know about are only
+// checked for a well-formed tag name.
+func ValidateTag(line string) error {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#EXT") {
+		return nil
+	}
+
+	name, value := SplitTag(line)
+	for _, r := range name[1:] {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '-' {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, name)
+		}
+	}
+
+	switch {
+	case name == "#EXTINF":
+		duration, _, _ := strings.Cut(value, ",")
+		if seconds, err := strconv.ParseFloat(strings.TrimSpace(duration), 64); err != nil || seconds < 0 {
+			return fmt.Errorf("%w: %s duration %q", ErrInvalidTag, name, duration)
+		}
+	case name == "#EXT-X-BYTERANGE":
+		length, offset, hasOffset := strings.Cut(value, "@")
+		if _, err := strconv.ParseUint(length, 10, 64); err != nil {
+			return fmt.Errorf("%w: %s %q", ErrInvalidTag, name, value)
+		}
+		if _, err := strconv.ParseUint(offset, 10, 64); hasOffset && err != nil {
+			return fmt.Errorf("%w: %s %q", ErrInvalidTag, name, value)
+		}
+	case isIntegerTag(name):
+		if _, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64); err != nil {
+			return fmt.Errorf("%w: %s %q", ErrInvalidTag, name, value)
+		}
+	case isAttributeListTag(name):
+		if _, err := ParseAttributeList(value); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidTag, name, err)
+		}
+	}
+	return nil
+}
+
+func isIntegerTag(name string) bool {
+	_, ok := integerTags[name]
+	return ok
+}
+
+func isAttributeListTag(name string) bool {
+	_, ok := attributeListTags[name]
+	return ok
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const sealedPrefix = "v1:"
+
+var ErrUnsealFailed = errors.New("secret could not be decrypted")
+
+// Box encrypts small secrets, such as upstream credentials, before they are
+// written to the database. Sealed values are AES-256-GCM ciphertexts encoded
+// as "v1:" followed by base64url(nonce || ciphertext).
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox derives the box key from masterKey. An empty key gets a random one,
+// which only suits development: values sealed with it are lost on restart.
+func NewBox(masterKey []byte) *Box {
+	if len(masterKey) == 0 {
+		masterKey = make([]byte, 32)
+		_, _ = rand.Read(masterKey)
+	}
+	key := sha256.Sum256(masterKey)
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return &Box{aead: aead}
+}
+
+// Seal encrypts plaintext. additional binds the value to its context (for
+// example the header name) and must be passed again to Open.
+func (b *Box) Seal(plaintext, additional string) string {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	_, _ = rand.Read(nonce)
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additional))
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+func (b *Box) Open(sealed, additional string) (string, error) {
+	encoded, ok := strings.CutPrefix(sealed, sealedPrefix)
+	if !ok {
+		return "", ErrUnsealFailed
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", ErrUnsealFailed
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(additional))
+	if err != nil {
+		return "", ErrUnsealFailed
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,38 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBoxRoundTrip(t *testing.T) {
+	box := NewBox([]byte("master"))
+	sealed := box.Seal("session=abc123", "Cookie")
+	if strings.Contains(sealed, "abc123") {
+		t.Fatalf("sealed value leaks plaintext: %s", sealed)
+	}
+
+	opened, err := box.Open(sealed, "Cookie")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if opened != "session=abc123" {
+		t.Fatalf("unexpected plaintext %q", opened)
+	}
+}
+
+func TestBoxRejectsWrongContextAndKey(t *testing.T) {
+	box := NewBox([]byte("master"))
+	sealed := box.Seal("secret", "Cookie")
+
+	if _, err := box.Open(sealed, "Authorization"); !errors.Is(err, ErrUnsealFailed) {
+		t.Fatalf("expected ErrUnsealFailed for other context, got %v", err)
+	}
+	if _, err := NewBox([]byte("other")).Open(sealed, "Cookie"); !errors.Is(err, ErrUnsealFailed) {
+		t.Fatalf("expected ErrUnsealFailed for other key, got %v", err)
+	}
+	if _, err := box.Open("secret", "Cookie"); !errors.Is(err, ErrUnsealFailed) {
+		t.Fatalf("expected ErrUnsealFailed for plaintext, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"regexp"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+)
+
+// KeySize is the size of an AES-128 content key.
+const KeySize = 16
+
+var (
+	ErrKeyNotFound  = errors.New("content key not found")
+	ErrKeyConflict  = errors.New("key id is already used by a different key or movie")
+	ErrInvalidKey   = errors.New("content key must be 16 bytes")
+	ErrInvalidKeyID = errors.New("invalid key id")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Manager stores AES-128 content keys sealed with a master key and hands
+// them out by key ID to the movie they are assigned to. A key ID names the
+// key of a single movie: it cannot be taken over by another one without
+// knowing the key.
+type Manager struct {
+	repo *repository.ContentKeyRepository
+	box  *secrets.Box
+}
+
+func NewManager(repo *repository.ContentKeyRepository, box *secrets.Box) *Manager {
+	return &Manager{repo: repo, box: box}
+}
+
+// ValidKeyID reports whether id can be used as a key ID.
+func ValidKeyID(id string) bool {
+	return keyIDPattern.MatchString(id)
+}
+
+// Key returns the plaintext content key for id, as long as it is assigned to
+// movieID. Keys of other movies and unassigned ones are not found.
+func (m *Manager) Key(ctx context.Context, id, movieID string) ([]byte, error) {
+	stored, err := m.repo.GetSealedKey(ctx, id)
+	if errors.Is(err, repository.ErrContentKeyNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.MovieID == "" || stored.MovieID != movieID {
+		return nil, ErrKeyNotFound
+	}
+	return m.open(id, stored)
+}
+
+func (m *Manager) open(id string, stored repository.SealedContentKey) ([]byte, error) {
+	opened, err := m.box.Open(stored.Sealed, additionalData(id))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(opened), nil
+}
+
+// Import stores key under id and reports whether it was stored by this call.
+// Importing the key already stored under id is a no-op while it is not
+// assigned to a movie; a different key or an assigned one fails with
+// ErrKeyConflict.
+func (m *Manager) Import(ctx context.Context, id string, key []byte) (bool, error) {
+	if !ValidKeyID(id) {
+		return false, ErrInvalidKeyID
+	}
+	if len(key) != KeySize {
+		return false, ErrInvalidKey
+	}
+
+	err := m.repo.CreateSealedKey(ctx, id, m.box.Seal(string(key), additionalData(id)))
+	if !errors.Is(err, repository.ErrContentKeyExists) {
+		return err == nil, err
+	}
+	stored, err := m.repo.GetSealedKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if stored.MovieID != "" {
+		return false, ErrKeyConflict
+	}
+	existing, err := m.open(id, stored)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(existing, key) {
+		return false, ErrKeyConflict
+	}
+	return false, nil
+}
+
+// Generate stores a random key under id. An id that is already taken fails
+// with ErrKeyConflict: its key belongs to whoever stored it.
+func (m *Manager) Generate(ctx context.Context, id string) error {
+	if !ValidKeyID(id) {
+		return ErrInvalidKeyID
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	err := m.repo.CreateSealedKey(ctx, id, m.box.Seal(string(key), additionalData(id)))
+	if errors.Is(err, repository.ErrContentKeyExists) {
+		return ErrKeyConflict
+	}
+	return err
+}
+
+// Assign hands the key stored under id to movieID, the only movie it is
+// released for from then on. Assigning a key of another movie fails with
+// ErrKeyConflict.
+func (m *Manager) Assign(ctx context.Context, id, movieID string) error {
+	err := m.repo.AssignSealedKey(ctx, id, movieID)
+	switch {
+	case errors.Is(err, repository.ErrContentKeyNotFound):
+		return ErrKeyNotFound
+	case errors.Is(err, repository.ErrContentKeyAssigned):
+		return ErrKeyConflict
+	}
+	return err
+}
+
+// Discard deletes the key stored under id, unless it was assigned or a stream
+// uses it. It undoes an Import or Generate for a movie that could not be
+// created.
+func (m *Manager) Discard(ctx context.Context, id string) error {
+	return m.repo.DeleteUnusedSealedKey(ctx, id)
+}
+
+// additionalData binds a sealed key to its ID so rows cannot be swapped.
+func additionalData(id string) string {
+	return "content-key:" + id
+}
@@ -0,0 +1,124 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+)
+
+func newTestManager() *Manager {
+	return NewManager(repository.NewContentKeyRepository(nil), secrets.NewBox([]byte("master")))
+}
+
+func TestManagerImport(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+	key := bytes.Repeat([]byte{0x42}, KeySize)
+
+	if created, err := manager.Import(ctx, "movie-1", key); err != nil || !created {
+		t.Fatalf("import failed: %v (created %v)", err, created)
+	}
+	if created, err := manager.Import(ctx, "movie-1", key); err != nil || created {
+		t.Fatalf("re-importing the same key should succeed without storing it: %v (created %v)", err, created)
+	}
+	if _, err := manager.Import(ctx, "movie-1", bytes.Repeat([]byte{0x43}, KeySize)); !errors.Is(err, ErrKeyConflict) {
+		t.Fatalf("expected ErrKeyConflict, got %v", err)
+	}
+
+	if _, err := manager.Key(ctx, "movie-1", "1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected unassigned keys not to be released, got %v", err)
+	}
+	if err := manager.Assign(ctx, "movie-1", "1"); err != nil {
+		t.Fatalf("assign failed: %v", err)
+	}
+	got, err := manager.Key(ctx, "movie-1", "1")
+	if err != nil {
+		t.Fatalf("key lookup failed: %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Fatalf("unexpected key %x", got)
+	}
+	if _, err := manager.Import(ctx, "movie-1", key); !errors.Is(err, ErrKeyConflict) {
+		t.Fatalf("expected the key of a movie not to be imported again, got %v", err)
+	}
+
+	if _, err := manager.Import(ctx, "movie-2", []byte("short")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+	if _, err := manager.Import(ctx, "../etc", key); !errors.Is(err, ErrInvalidKeyID) {
+		t.Fatalf("expected ErrInvalidKeyID, got %v", err)
+	}
+}
+
+func TestManagerGenerate(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	if _, err := manager.Key(ctx, "generated", "1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if err := manager.Generate(ctx, "generated"); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if err := manager.Assign(ctx, "generated", "1"); err != nil {
+		t.Fatalf("assign failed: %v", err)
+	}
+	first, err := manager.Key(ctx, "generated", "1")
+	if err != nil || len(first) != KeySize {
+		t.Fatalf("expected a generated key, got %x, %v", first, err)
+	}
+	if err := manager.Generate(ctx, "generated"); !errors.Is(err, ErrKeyConflict) {
+		t.Fatalf("expected a taken key id to conflict, got %v", err)
+	}
+	second, _ := manager.Key(ctx, "generated", "1")
+	if !bytes.Equal(first, second) {
+		t.Fatal("generate must not replace an existing key")
+	}
+}
+
+func TestManagerReleasesKeysToTheirMovieOnly(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	if err := manager.Generate(ctx, "owned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Assign(ctx, "owned", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Assign(ctx, "owned", "1"); err != nil {
+		t.Fatalf("expected assigning a key to its movie again to succeed, got %v", err)
+	}
+	if err := manager.Assign(ctx, "owned", "2"); !errors.Is(err, ErrKeyConflict) {
+		t.Fatalf("expected ErrKeyConflict, got %v", err)
+	}
+	if _, err := manager.Key(ctx, "owned", "2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected the key not to be released to another movie, got %v", err)
+	}
+	if err := manager.Assign(ctx, "missing", "1"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestManagerRejectsSwappedRows(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewContentKeyRepository(nil)
+	manager := NewManager(repo, secrets.NewBox([]byte("master")))
+	if err := manager.Generate(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := repo.GetSealedKey(ctx, "a")
+	if err := repo.CreateSealedKey(ctx, "b", stored.Sealed); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Assign(ctx, "b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Key(ctx, "b", "1"); err == nil {
+		t.Fatal("expected a key sealed for another id to be rejected")
+	}
+}
@@ -0,0 +1,72 @@
+package movies
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"strings"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+// ErrTokenBindingMismatch is returned by ResolveStream for a valid token
+// presented by a viewer other than the one it was issued to.
+var ErrTokenBindingMismatch = errors.New("playback token is bound to another viewer")
+
+// bindClaims fills in the fingerprint of viewer that binding asks for.
+func bindClaims(claims TokenClaims, binding domain.TokenBinding, viewer Viewer) TokenClaims {
+	if addr, ok := viewerAddr(viewer.IP); ok {
+		bits := binding.IPv6Prefix
+		if addr.Is4() {
+			bits = binding.IPv4Prefix
+		}
+		if bits > 0 {
+			if prefix, err := addr.Prefix(bits); err == nil {
+				claims.IPPrefix = prefix.String()
+			}
+		}
+	}
+	if binding.UserAgent {
+		claims.UserAgentHash = userAgentHash(viewer.UserAgent)
+	}
+	return claims
+}
+
+// boundTo reports whether viewer matches the fingerprint in the claims. The
+// viewer ID is only compared when it names a viewer: IDs derived from the
+// client address are left to the IP prefix binding, so that it stays
+// optional.
+func (c TokenClaims) boundTo(viewer Viewer) bool {
+	if c.ViewerID != "" && !anonymousViewerID(c.ViewerID) && c.ViewerID != viewer.ID {
+		return false
+	}
+	if c.IPPrefix != "" {
+		prefix, err := netip.ParsePrefix(c.IPPrefix)
+		addr, ok := viewerAddr(viewer.IP)
+		if err != nil || !ok || !prefix.Contains(addr) {
+			return false
+		}
+	}
+	if c.UserAgentHash != "" && c.UserAgentHash != userAgentHash(viewer.UserAgent) {
+		return false
+	}
+	return true
+}
+
+func anonymousViewerID(id string) bool {
+	return id == "anonymous" || strings.HasPrefix(id, "ip:")
+}
+
+func viewerAddr(raw string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.Trim(strings.TrimSpace(raw), "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func userAgentHash(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:16])
+}
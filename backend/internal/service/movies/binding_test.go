@@ -0,0 +1,44 @@
+package movies
+
+import (
+	"testing"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestTokenBinding(t *testing.T) {
+	viewer := Viewer{ID: "viewer-1", IP: "2001:db8:1:2::10", UserAgent: "Player/1.0"}
+	claims := bindClaims(TokenClaims{MovieID: "movie-1", ViewerID: viewer.ID}, domain.TokenBinding{IPv4Prefix: 24, IPv6Prefix: 48, UserAgent: true}, viewer)
+	if claims.IPPrefix != "2001:db8:1::/48" || claims.UserAgentHash == "" {
+		t.Fatalf("unexpected binding claims %+v", claims)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		viewer Viewer
+		want   bool
+	}{
+		{"same viewer", viewer, true},
+		{"same network", Viewer{ID: "viewer-1", IP: "[2001:db8:1:ff::1]", UserAgent: "Player/1.0"}, true},
+		{"other network", Viewer{ID: "viewer-1", IP: "2001:db8:2::10", UserAgent: "Player/1.0"}, false},
+		{"no address", Viewer{ID: "viewer-1", UserAgent: "Player/1.0"}, false},
+		{"other browser", Viewer{ID: "viewer-1", IP: viewer.IP, UserAgent: "curl/8.0"}, false},
+		{"other viewer", Viewer{ID: "viewer-2", IP: viewer.IP, UserAgent: "Player/1.0"}, false},
+	} {
+		if got := claims.boundTo(tc.viewer); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	mapped := bindClaims(TokenClaims{ViewerID: "ip:192.0.2.7"}, domain.TokenBinding{IPv4Prefix: 24}, Viewer{IP: "::ffff:192.0.2.7"})
+	if mapped.IPPrefix != "192.0.2.0/24" {
+		t.Fatalf("expected a v4-mapped address to use the IPv4 prefix, got %q", mapped.IPPrefix)
+	}
+	if !mapped.boundTo(Viewer{ID: "ip:192.0.2.200", IP: "192.0.2.200"}) {
+		t.Fatalf("expected address-derived viewer IDs to be left to the IP binding")
+	}
+
+	if unbound := bindClaims(TokenClaims{ViewerID: "viewer-1"}, domain.TokenBinding{}, viewer); unbound.IPPrefix != "" || unbound.UserAgentHash != "" {
+		t.Fatalf("expected no binding without a policy, got %+v", unbound)
+	}
+}
@@ -0,0 +1,90 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/captions"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+const maxCaptionBytes = 8 << 20
+
+var ErrCaptionNotFound = errors.New("caption not found")
+
+// WithCaptionCache caches caption files after their conversion to WebVTT.
+func WithCaptionCache(captionCache *cache.CaptionCache) Option {
+	return func(s *Service) {
+		s.captions = captionCache
+	}
+}
+
+// CaptionProxied reports whether caption is served through the caption proxy.
+// Only captions on an http(s) origin are; relative URLs point at files the
+// frontend hosts itself.
+func CaptionProxied(caption domain.Caption) bool {
+	parsed, err := url.Parse(caption.CaptionURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// FetchCaption returns the stream's caption track for languageCode as
+// WebVTT, converting SRT and TTML/DFXP sources. Malformed sources fail with
+// captions.ErrMalformed.
+func (s *Service) FetchCaption(ctx context.Context, access StreamAccess, languageCode string) ([]byte, error) {
+	var source string
+	for _, caption := range access.Captions {
+		if strings.EqualFold(caption.LanguageCode, languageCode) && CaptionProxied(caption) {
+			source = caption.CaptionURL
+			break
+		}
+	}
+	if source == "" {
+		return nil, ErrCaptionNotFound
+	}
+
+	fetch := func(ctx context.Context) ([]byte, error) {
+		return s.fetchCaption(ctx, access, source)
+	}
+	if s.captions == nil {
+		return fetch(ctx)
+	}
+	return s.captions.GetOrFetch(ctx, access.MovieID+"\n"+source, fetch)
+}
+
+// fetchCaption downloads and converts the caption file at source. The
+// stream's upstream headers are only sent when the captions live on one of
+// the stream's own hosts.
+func (s *Service) fetchCaption(ctx context.Context, access StreamAccess, source string) ([]byte, error) {
+	parsed, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	if upstream.HostAllowed(parsed.Hostname(), access.AllowedHosts) {
+		header = access.upstreamHeader(nil)
+	}
+
+	ctx = upstream.WithAllowedHosts(ctx, append([]string{parsed.Hostname()}, access.AllowedHosts...))
+	resp, err := s.upstream.Fetch(ctx, http.MethodGet, source, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > maxCaptionBytes {
+		return nil, captions.ErrTooLarge
+	}
+	body, err := captions.Read(resp.Body, maxCaptionBytes)
+	if err != nil {
+		return nil, err
+	}
+	return captions.ToWebVTT(body)
+}
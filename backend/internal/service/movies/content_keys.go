@@ -0,0 +1,104 @@
+package movies
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
+)
+
+// ErrNoContentKey is returned for streams without a DRM key ID.
+var ErrNoContentKey = errors.New("stream has no content key")
+
+// WithKeyManager sets the store of AES-128 content keys served for streams
+// with a DRM key ID.
+func WithKeyManager(manager *keys.Manager) Option {
+	return func(s *Service) {
+		s.keys = manager
+	}
+}
+
+// HasContentKey reports whether the stream's AES-128 keys are served by the
+// built-in key server instead of the origin.
+func (a StreamAccess) HasContentKey() bool {
+	return a.KeyID != ""
+}
+
+// ContentKey returns the content key of a resolved stream, if it was assigned
+// to the stream's movie. Callers must have validated the playback token
+// through ResolveStream.
+func (s *Service) ContentKey(ctx context.Context, access StreamAccess) ([]byte, error) {
+	if !access.HasContentKey() {
+		return nil, ErrNoContentKey
+	}
+	key, err := s.keys.Key(ctx, access.KeyID, access.MovieID)
+	if errors.Is(err, keys.ErrKeyNotFound) {
+		return nil, ErrNoContentKey
+	}
+	return key, err
+}
+
+// validateContentKey checks the DRM key ID and the optional hex-encoded key
+// supplied with it.
+func validateContentKey(keyID, rawKey string, issues map[string]string) []byte {
+	if keyID != "" && !keys.ValidKeyID(keyID) {
+		issues["drmKeyId"] = "DRM Key ID ใช้ได้เฉพาะ A-Z a-z 0-9 . _ - และยาวไม่เกิน 128 ตัว"
+	}
+
+	rawKey = strings.TrimSpace(rawKey)
+	if rawKey == "" {
+		return nil
+	}
+	if keyID == "" {
+		issues["contentKey"] = "กรุณาระบุ DRM Key ID สำหรับคีย์นี้"
+		return nil
+	}
+	key, err := hex.DecodeString(rawKey)
+	if err != nil || len(key) != keys.KeySize {
+		issues["contentKey"] = "คีย์ต้องเป็นเลขฐานสิบหก 32 ตัว"
+		return nil
+	}
+	return key
+}
+
+// storeContentKey imports the supplied key under keyID, or generates one if
+// none was supplied. Key IDs already in use are refused, unless the same key
+// is imported before it was assigned to a movie. It reports whether a key was
+// stored, so that it can be discarded if the movie is not created after all.
+func (s *Service) storeContentKey(ctx context.Context, keyID string, key []byte) (bool, error) {
+	if keyID == "" {
+		return false, nil
+	}
+	var (
+		created bool
+		err     error
+	)
+	if key != nil {
+		created, err = s.keys.Import(ctx, keyID, key)
+	} else {
+		err = s.keys.Generate(ctx, keyID)
+		created = err == nil
+	}
+	if errors.Is(err, keys.ErrKeyConflict) {
+		return false, contentKeyInUse()
+	}
+	return created, err
+}
+
+// assignContentKey assigns the key stored for a created movie to it.
+func (s *Service) assignContentKey(ctx context.Context, keyID, movieID string) error {
+	if keyID == "" {
+		return nil
+	}
+	err := s.keys.Assign(ctx, keyID, movieID)
+	if errors.Is(err, keys.ErrKeyConflict) {
+		return contentKeyInUse()
+	}
+	return err
+}
+
+func contentKeyInUse() error {
+	return ValidationError{Fields: map[string]string{"contentKey": "DRM Key ID นี้ถูกใช้กับคีย์หรือภาพยนตร์อื่นแล้ว"}}
+}
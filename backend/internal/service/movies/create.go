@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"strings"
 	"time"
@@ -24,9 +25,25 @@ type CreateMovieInput struct {
 	AvailabilityEnd   string
 	IsVisible         bool
 	StreamURL         string
-	DRMKeyID          string
-	AllowedHosts      []string
-	Captions          []CaptionInput
+	// MirrorURLs are fallback sources for StreamURL in priority order. They
+	// must mirror its layout and format.
+	MirrorURLs []string
+	DRMKeyID   string
+	// ContentKey is an optional hex-encoded AES-128 key to store under
+	// DRMKeyID. Without it a key is generated for new key IDs.
+	ContentKey string
+	// EncryptSegments turns on on-the-fly AES-128 encryption of clear HLS
+	// segments with the DRMKeyID content key.
+	EncryptSegments bool
+	// WatermarkURL is the B variant of the stream for A/B forensic
+	// watermarking. It must mirror the layout of StreamURL.
+	WatermarkURL    string
+	AllowedHosts    []string
+	UpstreamHeaders map[string]string
+	Captions        []CaptionInput
+	// TokenBinding ties playback tokens to the viewer's network and browser
+	// on top of the viewer ID.
+	TokenBinding domain.TokenBinding
 }
 
 type CaptionInput struct {
@@ -75,9 +92,21 @@ func (s *Service) CreateMovie(ctx context.Context, input CreateMovieInput) (doma
 
 	streamURL := strings.TrimSpace(input.StreamURL)
 	if streamURL == "" {
-		issues["streamUrl"] = "กรุณาระบุลิงก์ .m3u8"
+		issues["streamUrl"] = "กรุณาระบุลิงก์ .m3u8 หรือ .mpd"
 	} else if !isValidStreamURL(streamURL) {
-		issues["streamUrl"] = "ต้องเป็น URL แบบ http(s) และลงท้ายด้วย .m3u8"
+		issues["streamUrl"] = "ต้องเป็น URL แบบ http(s) และลงท้ายด้วย .m3u8 หรือ .mpd"
+	}
+
+	mirrorURLs := normalizeMirrorURLs(streamURL, input.MirrorURLs, issues)
+
+	watermarkURL := strings.TrimSpace(input.WatermarkURL)
+	if watermarkURL != "" {
+		switch {
+		case !isValidStreamURL(watermarkURL) || isDASHURL(watermarkURL):
+			issues["watermarkUrl"] = "ต้องเป็น URL แบบ http(s) และลงท้ายด้วย .m3u8"
+		case isDASHURL(streamURL):
+			issues["watermarkUrl"] = "ลายน้ำ A/B รองรับเฉพาะสตรีม .m3u8"
+		}
 	}
 
 	availabilityStart := parseRequiredTime(input.AvailabilityStart, "availabilityStart", issues)
@@ -87,12 +116,33 @@ func (s *Service) CreateMovie(ctx context.Context, input CreateMovieInput) (doma
 	}
 
 	drmKeyID := strings.TrimSpace(input.DRMKeyID)
+	contentKey := validateContentKey(drmKeyID, input.ContentKey, issues)
+	if input.EncryptSegments {
+		switch {
+		case drmKeyID == "":
+			issues["drmKeyId"] = "กรุณาระบุ DRM Key ID เพื่อเข้ารหัสเซกเมนต์"
+		case isDASHURL(streamURL):
+			issues["encryptSegments"] = "การเข้ารหัสเซกเมนต์รองรับเฉพาะสตรีม .m3u8"
+		}
+	}
+
+	if input.TokenBinding.IPv4Prefix < 0 || input.TokenBinding.IPv4Prefix > 32 {
+		issues["tokenBinding.ipv4Prefix"] = "ความยาว prefix ของ IPv4 ต้องอยู่ระหว่าง 0 ถึง 32"
+	}
+	if input.TokenBinding.IPv6Prefix < 0 || input.TokenBinding.IPv6Prefix > 128 {
+		issues["tokenBinding.ipv6Prefix"] = "ความยาว prefix ของ IPv6 ต้องอยู่ระหว่าง 0 ถึง 128"
+	}
 
 	normalizedCaptions, captionIssues := normalizeCaptions(input.Captions)
 	for field, message := range captionIssues {
 		issues[field] = message
 	}
 
+	upstreamHeaders, headerIssues := s.normalizeUpstreamHeaders(input.UpstreamHeaders)
+	for field, message := range headerIssues {
+		issues[field] = message
+	}
+
 	// Validate allowed hosts
 	if len(input.AllowedHosts) == 0 && streamURL == "" {
 		issues["allowedHosts"] = "กรุณาระบุ allowed hosts อย่างน้อย 1 host"
@@ -111,6 +161,12 @@ func (s *Service) CreateMovie(ctx context.Context, input CreateMovieInput) (doma
 	}
 
 	allowedHosts := normalizeAllowedHosts(streamURL, input.AllowedHosts)
+	if watermarkURL != "" {
+		allowedHosts = normalizeAllowedHosts(streamURL, append(allowedHosts, watermarkURL))
+	}
+	if len(mirrorURLs) > 0 {
+		allowedHosts = normalizeAllowedHosts(streamURL, append(allowedHosts, mirrorURLs...))
+	}
 	params := repository.CreateMovieParams{
 		Title:             title,
 		Synopsis:          synopsis,
@@ -119,11 +175,46 @@ func (s *Service) CreateMovie(ctx context.Context, input CreateMovieInput) (doma
 		AvailabilityEnd:   availabilityEnd,
 		IsVisible:         input.IsVisible,
 		StreamURL:         streamURL,
+		MirrorURLs:        mirrorURLs,
 		DRMKeyID:          drmKeyID,
+		EncryptSegments:   input.EncryptSegments,
+		WatermarkURL:      watermarkURL,
 		AllowedHosts:      allowedHosts,
+		UpstreamHeaders:   upstreamHeaders,
 		Captions:          normalizedCaptions,
+		TokenBinding:      input.TokenBinding,
 	}
+	params.Metadata = s.probeNewStream(ctx, domain.Movie{
+		StreamURL:          streamURL,
+		MirrorURLs:         mirrorURLs,
+		AllowedStreamHosts: allowedHosts,
+		UpstreamHeaders:    upstreamHeaders,
+	})
+
+	keyCreated, err := s.storeContentKey(ctx, drmKeyID, contentKey)
+	if err != nil {
+		return domain.Movie{}, err
+	}
+	movie, err := s.insertMovie(ctx, slugBase, params)
+	if err != nil {
+		if keyCreated {
+			if discardErr := s.keys.Discard(context.WithoutCancel(ctx), drmKeyID); discardErr != nil {
+				slog.WarnContext(ctx, "failed to discard the content key of a movie that was not created", "drmKeyId", drmKeyID, "error", discardErr)
+			}
+		}
+		return domain.Movie{}, err
+	}
+	// Only fails when another movie imported the same key concurrently and
+	// was assigned it first; this movie then has no key to serve.
+	if err := s.assignContentKey(ctx, drmKeyID, movie.ID); err != nil {
+		return domain.Movie{}, err
+	}
+	return movie, nil
+}
 
+// insertMovie stores the movie under slugBase, or under a numbered variant of
+// it when the slug is taken.
+func (s *Service) insertMovie(ctx context.Context, slugBase string, params repository.CreateMovieParams) (domain.Movie, error) {
 	slug := slugBase
 	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
 		params.Slug = slug
@@ -241,6 +332,34 @@ func normalizeCaptions(inputs []CaptionInput) ([]domain.Caption, map[string]stri
 	return normalized, issues
 }
 
+func normalizeMirrorURLs(streamURL string, inputs []string, issues map[string]string) []string {
+	mirrors := make([]string, 0, len(inputs))
+	seen := map[string]struct{}{streamURL: {}}
+	for idx, raw := range inputs {
+		mirrorURL := strings.TrimSpace(raw)
+		if mirrorURL == "" {
+			continue
+		}
+
+		field := fmt.Sprintf("mirrorUrls.%d", idx)
+		if !isValidStreamURL(mirrorURL) {
+			issues[field] = "ต้องเป็น URL แบบ http(s) และลงท้ายด้วย .m3u8 หรือ .mpd"
+			continue
+		}
+		if isDASHURL(mirrorURL) != isDASHURL(streamURL) {
+			issues[field] = "สตรีมสำรองต้องเป็นชนิดเดียวกับลิงก์สตรีมหลัก"
+			continue
+		}
+		if _, exists := seen[mirrorURL]; exists {
+			issues[field] = "ลิงก์สตรีมสำรองซ้ำกัน"
+			continue
+		}
+		seen[mirrorURL] = struct{}{}
+		mirrors = append(mirrors, mirrorURL)
+	}
+	return mirrors
+}
+
 func normalizeAllowedHosts(streamURL string, provided []string) []string {
 	seen := make(map[string]struct{})
 	hosts := make([]string, 0, len(provided)+1)
@@ -309,7 +428,15 @@ func isValidStreamURL(raw string) bool {
 		return false
 	}
 	parsed, _ := url.Parse(raw)
-	return strings.HasSuffix(strings.ToLower(parsed.Path), ".m3u8")
+	return strings.HasSuffix(strings.ToLower(parsed.Path), ".m3u8") || isDASHURL(raw)
+}
+
+func isDASHURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(parsed.Path), ".mpd")
 }
 
 func isValidCaptionURL(raw string) bool {
@@ -3,10 +3,12 @@ package movies
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
 )
 
 func TestCreateMovieSuccess(t *testing.T) {
@@ -110,6 +112,161 @@ func TestCreateMovieValidationError(t *testing.T) {
 	}
 }
 
+func TestCreateMovieSealsSecretUpstreamHeaders(t *testing.T) {
+	repo := repository.NewMovieRepository(nil)
+	service := NewService(repo, NewInMemoryTokenSigner(), 5*time.Minute)
+
+	movie, err := service.CreateMovie(context.Background(), CreateMovieInput{
+		Title:             "Header Protected Stream",
+		Synopsis:          "Needs a referer and a session cookie upstream.",
+		PosterURL:         "https://example.com/poster.jpg",
+		AvailabilityStart: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		AvailabilityEnd:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		IsVisible:         true,
+		StreamURL:         "https://stream.example.com/protected/master.m3u8",
+		UpstreamHeaders: map[string]string{
+			"referer": "https://player.example.com/",
+			"Cookie":  "session=abc123",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMovie returned error: %v", err)
+	}
+
+	for _, header := range movie.UpstreamHeaders {
+		switch header.Name {
+		case "Referer":
+			if header.Secret || header.Value != "https://player.example.com/" {
+				t.Fatalf("expected plain referer, got %+v", header)
+			}
+		case "Cookie":
+			if !header.Secret || strings.Contains(header.Value, "abc123") {
+				t.Fatalf("expected sealed cookie, got %+v", header)
+			}
+		default:
+			t.Fatalf("unexpected header %q", header.Name)
+		}
+	}
+
+	issued, err := service.CreatePlaybackToken(context.Background(), movie, Viewer{})
+	if err != nil {
+		t.Fatalf("CreatePlaybackToken returned error: %v", err)
+	}
+	access, err := service.ResolveStream(context.Background(), movie.Slug, issued.Token, Viewer{})
+	if err != nil {
+		t.Fatalf("ResolveStream returned error: %v", err)
+	}
+	if got := access.Header.Get("Cookie"); got != "session=abc123" {
+		t.Fatalf("expected opened cookie, got %q", got)
+	}
+	if got := access.Header.Get("Referer"); got != "https://player.example.com/" {
+		t.Fatalf("expected referer, got %q", got)
+	}
+}
+
+func TestCreateMovieRejectsReservedUpstreamHeaders(t *testing.T) {
+	repo := repository.NewMovieRepository(nil)
+	service := NewService(repo, NewInMemoryTokenSigner(), 5*time.Minute)
+
+	_, err := service.CreateMovie(context.Background(), CreateMovieInput{
+		Title:             "Bad Headers",
+		Synopsis:          "Tries to override managed headers.",
+		PosterURL:         "https://example.com/poster.jpg",
+		AvailabilityStart: time.Now().UTC().Format(time.RFC3339),
+		AvailabilityEnd:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		StreamURL:         "https://stream.example.com/bad/master.m3u8",
+		UpstreamHeaders: map[string]string{
+			"Host":       "internal.example.com",
+			"X-Injected": "a\r\nb",
+			"Bad Name":   "value",
+		},
+	})
+	var valErr ValidationError
+	if !errors.As(err, &valErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, field := range []string{"upstreamHeaders.Host", "upstreamHeaders.X-Injected", "upstreamHeaders.Bad Name"} {
+		if valErr.Fields[field] == "" {
+			t.Fatalf("expected validation error on %s, got %v", field, valErr.Fields)
+		}
+	}
+}
+
+func TestCreateMovieStoresContentKey(t *testing.T) {
+	repo := repository.NewMovieRepository(nil)
+	service := NewService(repo, NewInMemoryTokenSigner(), 5*time.Minute)
+
+	input := CreateMovieInput{
+		Title:             "Encrypted Premiere",
+		Synopsis:          "Served with keys from the built-in key server.",
+		PosterURL:         "https://example.com/poster.jpg",
+		AvailabilityStart: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		AvailabilityEnd:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		IsVisible:         true,
+		StreamURL:         "https://stream.example.com/encrypted/master.m3u8",
+		DRMKeyID:          "encrypted-premiere",
+		ContentKey:        "000102030405060708090a0b0c0d0e0f",
+	}
+	movie, err := service.CreateMovie(context.Background(), input)
+	if err != nil {
+		t.Fatalf("CreateMovie returned error: %v", err)
+	}
+
+	issued, err := service.CreatePlaybackToken(context.Background(), movie, Viewer{})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	access, err := service.ResolveStream(context.Background(), movie.Slug, issued.Token, Viewer{})
+	if err != nil {
+		t.Fatalf("failed to resolve stream: %v", err)
+	}
+	key, err := service.ContentKey(context.Background(), access)
+	if err != nil {
+		t.Fatalf("failed to load content key: %v", err)
+	}
+	if string(key) != "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f" {
+		t.Fatalf("unexpected content key %x", key)
+	}
+
+	// A movie that is not created leaves no key behind, but keeps the keys
+	// other movies use.
+	duplicate := input
+	duplicate.DRMKeyID = "orphaned-key"
+	duplicate.ContentKey = ""
+	if _, err := service.CreateMovie(context.Background(), duplicate); !errors.Is(err, ErrDuplicateMovieTitle) {
+		t.Fatalf("expected ErrDuplicateMovieTitle, got %v", err)
+	}
+	if created, err := service.keys.Import(context.Background(), "orphaned-key", make([]byte, keys.KeySize)); err != nil || !created {
+		t.Fatalf("expected the key of the failed movie to be discarded, got %v (created %v)", err, created)
+	}
+
+	// The key ID of a movie cannot be reused by another one, even with the
+	// same key, and generating a key for it must not hand out this movie's.
+	var valErr ValidationError
+	for _, contentKey := range []string{input.ContentKey, "ffffffffffffffffffffffffffffffff", ""} {
+		sequel := input
+		sequel.Title = "Encrypted Sequel"
+		sequel.ContentKey = contentKey
+		_, err = service.CreateMovie(context.Background(), sequel)
+		if !errors.As(err, &valErr) || valErr.Fields["contentKey"] == "" {
+			t.Fatalf("expected a contentKey conflict for %q, got %v", contentKey, err)
+		}
+	}
+	if _, err := service.CreateMovie(context.Background(), input); !errors.As(err, &valErr) {
+		t.Fatalf("expected a contentKey conflict, got %v", err)
+	}
+	if _, err := service.ContentKey(context.Background(), access); err != nil {
+		t.Fatalf("expected the key to survive failed movies, got %v", err)
+	}
+
+	input.DRMKeyID = "../escape"
+	input.ContentKey = "not-hex"
+	_, err = service.CreateMovie(context.Background(), input)
+	if !errors.As(err, &valErr) || valErr.Fields["drmKeyId"] == "" || valErr.Fields["contentKey"] == "" {
+		t.Fatalf("expected drmKeyId and contentKey validation errors, got %v", err)
+	}
+}
+
 func containsHost(hosts []string, target string) bool {
 	for _, host := range hosts {
 		if host == target {
@@ -118,3 +275,58 @@ func containsHost(hosts []string, target string) bool {
 	}
 	return false
 }
+
+func TestCreateMovieWithMirrorSources(t *testing.T) {
+	repo := repository.NewMovieRepository(nil)
+	service := NewService(repo, NewInMemoryTokenSigner(), 5*time.Minute)
+
+	input := CreateMovieInput{
+		Title:             "Mirrored Premiere",
+		Synopsis:          "Served from a backup CDN when the primary fails.",
+		PosterURL:         "https://example.com/poster.jpg",
+		AvailabilityStart: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		AvailabilityEnd:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		IsVisible:         true,
+		StreamURL:         "https://primary.example.com/vod/master.m3u8",
+		MirrorURLs:        []string{" https://backup.example.com/vod/master.m3u8 ", ""},
+	}
+	movie, err := service.CreateMovie(context.Background(), input)
+	if err != nil {
+		t.Fatalf("CreateMovie returned error: %v", err)
+	}
+
+	issued, err := service.CreatePlaybackToken(context.Background(), movie, Viewer{})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	access, err := service.ResolveStream(context.Background(), movie.Slug, issued.Token, Viewer{})
+	if err != nil {
+		t.Fatalf("failed to resolve stream: %v", err)
+	}
+	want := []string{input.StreamURL, "https://backup.example.com/vod/master.m3u8"}
+	if strings.Join(access.Sources, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected sources %v, got %v", want, access.Sources)
+	}
+	if !strings.Contains(strings.Join(access.AllowedHosts, " "), "backup.example.com") {
+		t.Fatalf("expected mirror host to be allowed, got %v", access.AllowedHosts)
+	}
+
+	for i := 0; i < sourceFailureThreshold; i++ {
+		service.health.failure(input.StreamURL, service.now())
+	}
+	access, err = service.ResolveStream(context.Background(), movie.Slug, issued.Token, Viewer{})
+	if err != nil {
+		t.Fatalf("failed to resolve stream: %v", err)
+	}
+	if access.URL != want[1] {
+		t.Fatalf("expected the healthy mirror to be preferred, got %q", access.URL)
+	}
+
+	input.Title = "Mismatched Mirror"
+	input.MirrorURLs = []string{"https://backup.example.com/vod/manifest.mpd"}
+	_, err = service.CreateMovie(context.Background(), input)
+	var valErr ValidationError
+	if !errors.As(err, &valErr) || valErr.Fields["mirrorUrls.0"] == "" {
+		t.Fatalf("expected a mirrorUrls.0 validation error, got %v", err)
+	}
+}
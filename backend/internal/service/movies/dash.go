@@ -0,0 +1,33 @@
+package movies
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/dash"
+)
+
+// IsDASH reports whether the stream is an MPEG-DASH manifest rather than an
+// HLS playlist.
+func (a StreamAccess) IsDASH() bool {
+	return isDASHURL(a.URL)
+}
+
+// FetchDASHManifest returns the parsed upstream MPD at target. Manifests are
+// not cached; players only load them once per session for VOD and live MPDs
+// change on every refresh.
+func (s *Service) FetchDASHManifest(ctx context.Context, access StreamAccess, target string) (*dash.Document, error) {
+	resp, err := s.FetchUpstream(ctx, access, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	if s.maxPlaylistBytes > 0 && resp.ContentLength > s.maxPlaylistBytes {
+		return nil, dash.ErrManifestTooLarge
+	}
+	return dash.Decode(resp.Body, s.maxPlaylistBytes)
+}
@@ -0,0 +1,70 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+)
+
+// maxEncryptedSegmentBytes bounds segments that have to be read into memory
+// for encryption without going through the segment cache.
+const maxEncryptedSegmentBytes = 64 << 20
+
+// EncryptsSegments reports whether clear segments of the stream are encrypted
+// with its content key while proxied.
+func (a StreamAccess) EncryptsSegments() bool {
+	return a.EncryptSegments && a.HasContentKey()
+}
+
+// EncryptedSegment fetches the clear segment at target and encrypts it with
+// AES-128-CBC under the stream's content key, using the IV a player derives
+// from the segment's media sequence number.
+func (s *Service) EncryptedSegment(ctx context.Context, access StreamAccess, target string, sequence uint64) ([]byte, error) {
+	key, err := s.ContentKey(ctx, access)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, _, err := s.FetchSegment(ctx, access, target)
+	var oversized *OversizedSegmentError
+	switch {
+	case errors.As(err, &oversized) && oversized.Response != nil:
+		entry.Body, err = readSegmentBody(oversized.Response)
+	case errors.Is(err, ErrSegmentNotCacheable):
+		entry.Body, err = s.readSegment(ctx, access, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hls.EncryptSegment(key, hls.SequenceIV(sequence), entry.Body)
+}
+
+func (s *Service) readSegment(ctx context.Context, access StreamAccess, target string) ([]byte, error) {
+	resp, err := s.FetchUpstream(ctx, access, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	return readSegmentBody(resp)
+}
+
+// readSegmentBody reads and closes the body of a segment response.
+func readSegmentBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEncryptedSegmentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxEncryptedSegmentBytes {
+		return nil, cache.ErrSegmentTooLarge
+	}
+	return body, nil
+}
@@ -0,0 +1,165 @@
+package movies
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+)
+
+const (
+	maxUpstreamHeaders        = 16
+	maxUpstreamHeaderValueLen = 4096
+)
+
+// reservedUpstreamHeaders are managed by the proxy or the HTTP client and
+// cannot be overridden per stream.
+var reservedUpstreamHeaders = map[string]struct{}{
+	"Host":              {},
+	"Content-Length":    {},
+	"Transfer-Encoding": {},
+	"Connection":        {},
+	"Keep-Alive":        {},
+	"Upgrade":           {},
+	"Te":                {},
+	"Trailer":           {},
+	"Range":             {},
+	"If-Range":          {},
+	"Accept-Encoding":   {},
+}
+
+// secretUpstreamHeaders are always stored sealed. Headers whose names look
+// like credentials (token, key, secret, auth, signature) are sealed too.
+var secretUpstreamHeaders = map[string]struct{}{
+	"Cookie":        {},
+	"Authorization": {},
+}
+
+// WithSecretBox sets the box used to seal secret upstream header values.
+func WithSecretBox(box *secrets.Box) Option {
+	return func(s *Service) {
+		s.secrets = box
+	}
+}
+
+func isSecretUpstreamHeader(name string) bool {
+	if _, ok := secretUpstreamHeaders[name]; ok {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, marker := range []string{"token", "key", "secret", "auth", "signature"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeUpstreamHeaders validates the per-stream headers and seals the
+// secret ones. Headers are returned sorted by canonical name.
+func (s *Service) normalizeUpstreamHeaders(input map[string]string) ([]domain.UpstreamHeader, map[string]string) {
+	issues := make(map[string]string)
+	if len(input) > maxUpstreamHeaders {
+		issues["upstreamHeaders"] = fmt.Sprintf("ระบุ header ได้ไม่เกิน %d รายการ", maxUpstreamHeaders)
+		return nil, issues
+	}
+
+	seen := make(map[string]struct{}, len(input))
+	headers := make([]domain.UpstreamHeader, 0, len(input))
+	for rawName, rawValue := range input {
+		field := "upstreamHeaders." + rawName
+		name := http.CanonicalHeaderKey(strings.TrimSpace(rawName))
+		value := strings.TrimSpace(rawValue)
+
+		switch {
+		case !isValidHeaderName(name):
+			issues[field] = "ชื่อ header ไม่ถูกต้อง"
+			continue
+		case isReservedUpstreamHeader(name):
+			issues[field] = "ไม่สามารถกำหนด header นี้ได้"
+			continue
+		case value == "":
+			issues[field] = "กรุณาระบุค่าของ header"
+			continue
+		case len(value) > maxUpstreamHeaderValueLen || strings.ContainsAny(value, "\r\n\x00"):
+			issues[field] = "ค่าของ header ไม่ถูกต้อง"
+			continue
+		}
+		if _, exists := seen[name]; exists {
+			issues[field] = "header นี้ถูกเพิ่มแล้ว"
+			continue
+		}
+		seen[name] = struct{}{}
+
+		header := domain.UpstreamHeader{Name: name, Value: value}
+		if isSecretUpstreamHeader(name) {
+			header.Value = s.secrets.Seal(value, name)
+			header.Secret = true
+		}
+		headers = append(headers, header)
+	}
+
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers, issues
+}
+
+// upstreamHeader opens the stored headers of a stream into the header set
+// sent with every upstream request.
+func (s *Service) upstreamHeader(headers []domain.UpstreamHeader) (http.Header, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	header := make(http.Header, len(headers))
+	for _, stored := range headers {
+		value := stored.Value
+		if stored.Secret {
+			opened, err := s.secrets.Open(value, stored.Name)
+			if err != nil {
+				return nil, fmt.Errorf("upstream header %s: %w", stored.Name, err)
+			}
+			value = opened
+		}
+		header.Set(stored.Name, value)
+	}
+	return header, nil
+}
+
+// upstreamHeader merges the stream's upstream headers into a copy of
+// request-specific ones, which take precedence.
+func (a StreamAccess) upstreamHeader(header http.Header) http.Header {
+	if len(a.Header) == 0 {
+		return header
+	}
+	merged := a.Header.Clone()
+	for key, values := range header {
+		merged[key] = values
+	}
+	return merged
+}
+
+func isReservedUpstreamHeader(name string) bool {
+	if _, ok := reservedUpstreamHeaders[name]; ok {
+		return true
+	}
+	return strings.HasPrefix(name, "Sec-") || strings.HasPrefix(name, "Proxy-")
+}
+
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package movies
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	hmacTokenPrefix     = "v1."
+	minTokenSecretBytes = 16
+)
+
+// TokenKey is a playback token signing key, identified in tokens by ID.
+type TokenKey struct {
+	ID     string
+	Secret []byte
+}
+
+// TokenKeyring holds the key new playback tokens are signed with and the
+// retiring keys that still verify tokens signed before a rotation. A key can
+// be dropped once the token TTL has passed since it stopped being active.
+type TokenKeyring struct {
+	Active   TokenKey
+	Retiring []TokenKey
+}
+
+// ParseTokenKeyring reads a list of "kid:secret" pairs separated by commas.
+// The first pair is the active key, the others are retiring.
+func ParseTokenKeyring(raw string) (TokenKeyring, error) {
+	var (
+		keyring TokenKeyring
+		seen    = make(map[string]bool)
+	)
+	for i, entry := range strings.Split(raw, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		switch {
+		case !ok || id == "":
+			return TokenKeyring{}, fmt.Errorf("token key %d: expected kid:secret", i+1)
+		case strings.ContainsFunc(id, invalidKeyIDRune):
+			return TokenKeyring{}, fmt.Errorf("token key %q: kid may only contain A-Z a-z 0-9 _ -", id)
+		case len(secret) < minTokenSecretBytes:
+			return TokenKeyring{}, fmt.Errorf("token key %q: secret must be at least %d bytes", id, minTokenSecretBytes)
+		case seen[id]:
+			return TokenKeyring{}, fmt.Errorf("token key %q listed twice", id)
+		}
+		seen[id] = true
+
+		key := TokenKey{ID: id, Secret: []byte(secret)}
+		if i == 0 {
+			keyring.Active = key
+		} else {
+			keyring.Retiring = append(keyring.Retiring, key)
+		}
+	}
+	return keyring, nil
+}
+
+// invalidKeyIDRune reports whether r cannot appear in a key ID, which is
+// carried in tokens between dots.
+func invalidKeyIDRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+}
+
+// HMACTokenSigner issues self-contained playback tokens of the form
+// v1.<kid>.<claims>.<mac>: the claims and expiry are authenticated with
+// HMAC-SHA256 under the key kid names, so any replica holding the keyring can
+// validate a token without shared storage. Like the stored tokens, a token
+// signed without a TTL does not expire.
+type HMACTokenSigner struct {
+	active TokenKey
+	keys   map[string][]byte
+	now    func() time.Time
+}
+
+func NewHMACTokenSigner(keyring TokenKeyring) *HMACTokenSigner {
+	s := &HMACTokenSigner{
+		active: keyring.Active,
+		keys:   make(map[string][]byte),
+		now:    time.Now,
+	}
+	for _, key := range append([]TokenKey{keyring.Active}, keyring.Retiring...) {
+		s.keys[key.ID] = deriveKey(key.Secret, "playback-token")
+	}
+	return s
+}
+
+// hmacTokenPayload is the signed part of a token. The random ID keeps two
+// tokens issued to the same viewer in the same second distinct, since
+// watermark patterns and references are derived from the token.
+type hmacTokenPayload struct {
+	TokenID   string `json:"jti"`
+	MovieID   string `json:"mid"`
+	ViewerID  string `json:"vid,omitempty"`
+	Tier      string `json:"tier,omitempty"`
+	IPPrefix  string `json:"ip,omitempty"`
+	UserAgent string `json:"ua,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	// SessionStart is the unix time in milliseconds the session started.
+	SessionStart int64 `json:"sst,omitempty"`
+	// SessionEnd is the unix time the session ends, zero for no limit.
+	SessionEnd int64 `json:"sxp,omitempty"`
+	// IssuedAt is the unix time in milliseconds the token was issued.
+	IssuedAt int64 `json:"iat,omitempty"`
+	// ExpiresAt is the unix time the token expires, zero for no expiry.
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+func (s *HMACTokenSigner) SignToken(claims TokenClaims, ttl time.Duration) (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl).Unix()
+	}
+	payload, err := json.Marshal(hmacTokenPayload{
+		TokenID:      base64.RawURLEncoding.EncodeToString(id),
+		MovieID:      claims.MovieID,
+		ViewerID:     claims.ViewerID,
+		Tier:         claims.Tier,
+		IPPrefix:     claims.IPPrefix,
+		UserAgent:    claims.UserAgentHash,
+		SessionID:    claims.SessionID,
+		SessionStart: unixMilliOrZero(claims.SessionStartedAt),
+		SessionEnd:   unixOrZero(claims.SessionExpiresAt),
+		IssuedAt:     unixMilliOrZero(claims.IssuedAt),
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := hmacTokenPrefix + s.active.ID + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := tokenMAC(s.keys[s.active.ID], signed)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// ValidateToken checks the signature with the key the token names, which
+// must still be on the keyring, before reading the claims, and then the
+// expiry. Malformed and forged tokens
+// are reported as invalid rather than as errors.
+func (s *HMACTokenSigner) ValidateToken(token, movieID string) (TokenClaims, bool, error) {
+	payload, ok := s.open(token)
+	if !ok || payload.MovieID != movieID {
+		return TokenClaims{}, false, nil
+	}
+	claims := TokenClaims{
+		MovieID:       payload.MovieID,
+		ViewerID:      payload.ViewerID,
+		Tier:          payload.Tier,
+		IPPrefix:      payload.IPPrefix,
+		UserAgentHash: payload.UserAgent,
+		SessionID:     payload.SessionID,
+	}
+	if payload.ExpiresAt != 0 {
+		claims.ExpiresAt = time.Unix(payload.ExpiresAt, 0)
+	}
+	if payload.SessionStart != 0 {
+		claims.SessionStartedAt = time.UnixMilli(payload.SessionStart)
+	}
+	if payload.SessionEnd != 0 {
+		claims.SessionExpiresAt = time.Unix(payload.SessionEnd, 0)
+	}
+	if payload.IssuedAt != 0 {
+		claims.IssuedAt = time.UnixMilli(payload.IssuedAt)
+	}
+	if payload.ExpiresAt != 0 && s.now().Unix() >= payload.ExpiresAt {
+		return claims, false, ErrTokenExpired
+	}
+	return claims, true, nil
+}
+
+// open authenticates token and only then decodes its claims.
+func (s *HMACTokenSigner) open(token string) (hmacTokenPayload, bool) {
+	rest, ok := strings.CutPrefix(token, hmacTokenPrefix)
+	if !ok {
+		return hmacTokenPayload{}, false
+	}
+	keyID, rest, _ := strings.Cut(rest, ".")
+	encoded, rawMAC, ok := strings.Cut(rest, ".")
+	if !ok {
+		return hmacTokenPayload{}, false
+	}
+	key, ok := s.keys[keyID]
+	if !ok {
+		return hmacTokenPayload{}, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(rawMAC)
+	if err != nil || !hmac.Equal(mac, tokenMAC(key, token[:len(token)-len(rawMAC)-1])) {
+		return hmacTokenPayload{}, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return hmacTokenPayload{}, false
+	}
+	var payload hmacTokenPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return hmacTokenPayload{}, false
+	}
+	return payload, true
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func unixMilliOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// tokenMAC authenticates the signed part of a token: version, key ID and
+// claims.
+func tokenMAC(key []byte, signed string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
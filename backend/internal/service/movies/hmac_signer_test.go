@@ -0,0 +1,81 @@
+package movies
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACTokenRoundTrip(t *testing.T) {
+	keyring, err := ParseTokenKeyring("k1:0123456789abcdef")
+	if err != nil {
+		t.Fatalf("parse keyring: %v", err)
+	}
+	signer := NewHMACTokenSigner(keyring)
+
+	token, err := signer.SignToken(TokenClaims{MovieID: "movie-1", ViewerID: "viewer-1", Tier: "free"}, time.Minute)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	claims, ok, err := NewHMACTokenSigner(keyring).ValidateToken(token, "movie-1")
+	if err != nil || !ok {
+		t.Fatalf("expected token to validate on another replica, got %v %v", ok, err)
+	}
+	if claims.ViewerID != "viewer-1" || claims.Tier != "free" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if _, ok, _ := signer.ValidateToken(token, "movie-2"); ok {
+		t.Fatalf("expected token to be rejected for another movie")
+	}
+	other, _ := signer.SignToken(TokenClaims{MovieID: "movie-2"}, time.Minute)
+	forged := other[:strings.LastIndex(other, ".")] + token[strings.LastIndex(token, "."):]
+	if _, ok, _ := signer.ValidateToken(forged, "movie-2"); ok {
+		t.Fatalf("expected a token with a foreign signature to be rejected")
+	}
+	if _, ok, _ := signer.ValidateToken(strings.TrimPrefix(token, "v1."), "movie-1"); ok {
+		t.Fatalf("expected token without version to be rejected")
+	}
+	if _, ok, _ := signer.ValidateToken(strings.Replace(token, "v1.k1.", "v1.k2.", 1), "movie-1"); ok {
+		t.Fatalf("expected token naming an unknown key to be rejected")
+	}
+
+	// Like the stored tokens, tokens without a TTL do not expire.
+	lasting, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, 0)
+	signer.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	if claims, ok, err := signer.ValidateToken(lasting, "movie-1"); err != nil || !ok || !claims.ExpiresAt.IsZero() {
+		t.Fatalf("expected a token without TTL not to expire, got %+v %v %v", claims, ok, err)
+	}
+
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, ok, _ := signer.ValidateToken(token, "movie-1"); ok {
+		t.Fatalf("expected expired token to be rejected")
+	}
+}
+
+func TestHMACTokenKeyRotation(t *testing.T) {
+	before, _ := ParseTokenKeyring("k1:0123456789abcdef")
+	during, _ := ParseTokenKeyring("k2:fedcba9876543210, k1:0123456789abcdef")
+	after, _ := ParseTokenKeyring("k2:fedcba9876543210")
+
+	old, _ := NewHMACTokenSigner(before).SignToken(TokenClaims{MovieID: "movie-1"}, time.Minute)
+	if _, ok, _ := NewHMACTokenSigner(during).ValidateToken(old, "movie-1"); !ok {
+		t.Fatalf("expected a retiring key to still verify tokens")
+	}
+	if _, ok, _ := NewHMACTokenSigner(after).ValidateToken(old, "movie-1"); ok {
+		t.Fatalf("expected tokens of a dropped key to be rejected")
+	}
+
+	fresh, _ := NewHMACTokenSigner(during).SignToken(TokenClaims{MovieID: "movie-1"}, time.Minute)
+	if _, ok, _ := NewHMACTokenSigner(after).ValidateToken(fresh, "movie-1"); !ok {
+		t.Fatalf("expected new tokens to be signed with the active key")
+	}
+}
+
+func TestParseTokenKeyringErrors(t *testing.T) {
+	for _, raw := range []string{"", "k1", "k1:short", ":0123456789abcdef", "k1:0123456789abcdef,k1:fedcba9876543210", "k.1:0123456789abcdef"} {
+		if _, err := ParseTokenKeyring(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+// probeTimeout bounds the playlist fetches of a single stream probe.
+const probeTimeout = 15 * time.Second
+
+// ErrProbeUnsupported is returned when probing a stream that is not HLS.
+var ErrProbeUnsupported = errors.New("stream metadata can only be probed for HLS streams")
+
+// ProbeStream analyses the upstream playlists of a stream: the rendition
+// ladder and alternative tracks of the master playlist, and duration and
+// encryption method of its first variant.
+func (s *Service) ProbeStream(ctx context.Context, access StreamAccess) (domain.StreamMetadata, error) {
+	if access.IsDASH() {
+		return domain.StreamMetadata{}, ErrProbeUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	ctx = upstream.WithAllowedHosts(ctx, access.AllowedHosts)
+
+	target := access.URL
+	playlist, err := s.fetchPlaylist(ctx, access, target, false)
+	if err != nil {
+		return domain.StreamMetadata{}, err
+	}
+
+	metadata := domain.StreamMetadata{ProbedAt: s.now()}
+	master := playlist
+	if playlist.Master {
+		for _, variant := range playlist.Variants() {
+			metadata.Renditions = append(metadata.Renditions, domain.Rendition{
+				Bandwidth:  variant.Bandwidth,
+				Resolution: variant.Resolution,
+				Codecs:     variant.Codecs,
+				Audio:      variant.Audio,
+				Subtitles:  variant.Subtitles,
+			})
+		}
+		for _, media := range playlist.Media() {
+			metadata.Media = append(metadata.Media, domain.MediaRendition(media))
+		}
+
+		if target, err = firstPlaylistURI(target, playlist, errNoVariants); err != nil {
+			return domain.StreamMetadata{}, err
+		}
+		if playlist, err = s.fetchPlaylist(ctx, access, target, false); err != nil {
+			return domain.StreamMetadata{}, err
+		}
+	}
+
+	if playlist.EndList {
+		metadata.Duration = playlist.Duration()
+	}
+	metadata.Encryption = playlist.EncryptionMethod()
+	if metadata.Encryption == "NONE" {
+		metadata.Encryption = master.EncryptionMethod()
+	}
+	return metadata, nil
+}
+
+// ReprobeMovie probes the stream of a movie again and stores the result.
+func (s *Service) ReprobeMovie(ctx context.Context, slug string) (domain.StreamMetadata, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return domain.StreamMetadata{}, err
+	}
+	access, err := s.streamAccess(movie)
+	if err != nil {
+		return domain.StreamMetadata{}, err
+	}
+	metadata, err := s.ProbeStream(ctx, access)
+	if err != nil {
+		return domain.StreamMetadata{}, err
+	}
+	if err := s.repo.UpdateStreamMetadata(ctx, movie.ID, metadata); err != nil {
+		return domain.StreamMetadata{}, err
+	}
+	return metadata, nil
+}
+
+// probeNewStream probes the stream of a movie that is being created. A
+// failed probe leaves the metadata empty; it does not block the creation
+// since it can be repeated later.
+func (s *Service) probeNewStream(ctx context.Context, movie domain.Movie) *domain.StreamMetadata {
+	access, err := s.streamAccess(movie)
+	if err != nil {
+		return nil
+	}
+	metadata, err := s.ProbeStream(ctx, access)
+	if err != nil {
+		return nil
+	}
+	return &metadata
+}
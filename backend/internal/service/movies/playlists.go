@@ -0,0 +1,146 @@
+package movies
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+const maxTrackedLivePlaylists = 4096
+
+// BlockingReloadParams are the LL-HLS delivery directives a player appends
+// to a playlist URL; they are forwarded to the origin as-is.
+var BlockingReloadParams = []string{"_HLS_msn", "_HLS_part", "_HLS_skip"}
+
+// WithPlaylistCache caches parsed upstream playlists between requests.
+func WithPlaylistCache(playlistCache *cache.PlaylistCache) Option {
+	return func(s *Service) {
+		s.playlists = playlistCache
+	}
+}
+
+const defaultMaxPlaylistBytes = 4 << 20
+
+// WithMaxPlaylistBytes caps the size of upstream playlists. Larger bodies are
+// rejected with hls.ErrPlaylistTooLarge while they are being read.
+func WithMaxPlaylistBytes(limit int64) Option {
+	return func(s *Service) {
+		if limit > 0 {
+			s.maxPlaylistBytes = limit
+		}
+	}
+}
+
+// FetchPlaylist returns the parsed upstream playlist at target, falling back
+// to the stream's other sources when its own fails. Cached entries are scoped
+// to the movie and dropped when its stream sources change.
+// A non-empty reload carries LL-HLS blocking reload directives; such requests
+// always go to the origin and may be held there until the part exists.
+func (s *Service) FetchPlaylist(ctx context.Context, access StreamAccess, target string, reload url.Values) (hls.Playlist, error) {
+	ctx = upstream.WithAllowedHosts(ctx, access.AllowedHosts)
+	if len(reload) > 0 {
+		reloadURL, err := url.Parse(target)
+		if err != nil {
+			return hls.Playlist{}, err
+		}
+		query := reloadURL.Query()
+		for key, values := range reload {
+			query[key] = values
+		}
+		reloadURL.RawQuery = query.Encode()
+
+		playlist, err := s.fetchPlaylist(ctx, access, reloadURL.String(), true)
+		if err != nil {
+			return hls.Playlist{}, err
+		}
+		if reload.Get("_HLS_skip") != "" {
+			return playlist, nil
+		}
+		return s.live.observe(access.MovieID+"\n"+target, playlist, s.now()), nil
+	}
+
+	fetch := func(ctx context.Context) (hls.Playlist, error) {
+		return s.fetchPlaylist(ctx, access, target, false)
+	}
+
+	var (
+		playlist hls.Playlist
+		err      error
+	)
+	if s.playlists == nil {
+		playlist, err = fetch(ctx)
+	} else {
+		playlist, err = s.playlists.GetOrFetch(ctx, access.MovieID, access.version(), target, fetch)
+	}
+	if err != nil || !playlist.Live() {
+		return playlist, err
+	}
+	return s.live.observe(access.MovieID+"\n"+target, playlist, s.now()), nil
+}
+
+func (s *Service) fetchPlaylist(ctx context.Context, access StreamAccess, target string, blocking bool) (hls.Playlist, error) {
+	resp, err := s.fetchWithFailover(ctx, access, target, func(ctx context.Context, target string) (*http.Response, error) {
+		if blocking {
+			return s.upstream.FetchBlocking(ctx, target, access.Header)
+		}
+		return s.upstream.Fetch(ctx, http.MethodGet, target, access.Header)
+	})
+	if err != nil {
+		return hls.Playlist{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return hls.Playlist{}, UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if s.maxPlaylistBytes > 0 && resp.ContentLength > s.maxPlaylistBytes {
+		return hls.Playlist{}, hls.ErrPlaylistTooLarge
+	}
+	return hls.Decode(resp.Body, s.maxPlaylistBytes)
+}
+
+// liveTracker remembers the newest copy of each live playlist handed out so
+// that a lagging CDN edge cannot move a viewer backwards in the media
+// sequence. A regression is only masked for a few target durations; beyond
+// that the origin is assumed to have restarted the stream.
+type liveTracker struct {
+	mu   sync.Mutex
+	last map[string]liveSnapshot
+}
+
+type liveSnapshot struct {
+	playlist hls.Playlist
+	seenAt   time.Time
+}
+
+func newLiveTracker() *liveTracker {
+	return &liveTracker{last: make(map[string]liveSnapshot)}
+}
+
+func (t *liveTracker) observe(key string, playlist hls.Playlist, now time.Time) hls.Playlist {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if prev, ok := t.last[key]; ok && playlist.Older(prev.playlist) {
+		window := 3 * prev.playlist.TargetDuration
+		if window <= 0 {
+			window = 30 * time.Second
+		}
+		if now.Sub(prev.seenAt) < window {
+			return prev.playlist
+		}
+	}
+
+	if len(t.last) >= maxTrackedLivePlaylists {
+		clear(t.last)
+	}
+	t.last[key] = liveSnapshot{playlist: playlist, seenAt: now}
+	return playlist
+}
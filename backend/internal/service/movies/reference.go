@@ -0,0 +1,120 @@
+package movies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	referenceNonceSize = aes.BlockSize
+	referenceMACSize   = 16
+	referenceVersion   = 1
+)
+
+var (
+	ErrInvalidReference = errors.New("invalid stream reference")
+	ErrReferenceExpired = errors.New("stream reference expired")
+)
+
+// ReferenceSigner turns upstream URLs into opaque references for rewritten
+// playlists. The URL is encrypted with AES-CTR and the result authenticated
+// with HMAC-SHA256 over the movie ID, playback session, resource kind and
+// expiry, so a reference only works for the session it was issued to.
+type ReferenceSigner struct {
+	encKey []byte
+	macKey []byte
+	ttl    time.Duration
+}
+
+func NewReferenceSigner(secret []byte, ttl time.Duration) *ReferenceSigner {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	return &ReferenceSigner{
+		encKey: deriveKey(secret, "stream-reference-enc"),
+		macKey: deriveKey(secret, "stream-reference-mac"),
+		ttl:    ttl,
+	}
+}
+
+// WithReferenceSigner sets the signer used for playlist references.
+func WithReferenceSigner(signer *ReferenceSigner) Option {
+	return func(s *Service) {
+		s.references = signer
+	}
+}
+
+// SignReference returns an opaque reference to target for the given kind of
+// proxied resource.
+func (s *Service) SignReference(access StreamAccess, kind, target string) string {
+	return s.references.sign(access.MovieID, access.SessionID, kind, target, s.now())
+}
+
+// OpenReference verifies ref and returns the upstream URL it stands for.
+func (s *Service) OpenReference(access StreamAccess, kind, ref string) (string, error) {
+	return s.references.open(access.MovieID, access.SessionID, kind, ref, s.now())
+}
+
+func (r *ReferenceSigner) sign(movieID, session, kind, target string, now time.Time) string {
+	payload := make([]byte, 1+8+referenceNonceSize+len(target))
+	payload[0] = referenceVersion
+	binary.BigEndian.PutUint64(payload[1:9], uint64(now.Add(r.ttl).Unix()))
+	nonce := payload[9 : 9+referenceNonceSize]
+	_, _ = rand.Read(nonce)
+	r.stream(nonce).XORKeyStream(payload[9+referenceNonceSize:], []byte(target))
+
+	out := append(payload, r.mac(movieID, session, kind, payload)...)
+	return base64.RawURLEncoding.EncodeToString(out)
+}
+
+func (r *ReferenceSigner) open(movieID, session, kind, ref string, now time.Time) (string, error) {
+	raw, err := base64.RawURLEncoding.Strict().DecodeString(ref)
+	if err != nil || len(raw) < 1+8+referenceNonceSize+referenceMACSize || raw[0] != referenceVersion {
+		return "", ErrInvalidReference
+	}
+
+	payload, sum := raw[:len(raw)-referenceMACSize], raw[len(raw)-referenceMACSize:]
+	if !hmac.Equal(sum, r.mac(movieID, session, kind, payload)) {
+		return "", ErrInvalidReference
+	}
+	if expires := time.Unix(int64(binary.BigEndian.Uint64(payload[1:9])), 0); now.After(expires) {
+		return "", ErrReferenceExpired
+	}
+
+	nonce := payload[9 : 9+referenceNonceSize]
+	target := make([]byte, len(payload)-9-referenceNonceSize)
+	r.stream(nonce).XORKeyStream(target, payload[9+referenceNonceSize:])
+	return string(target), nil
+}
+
+func (r *ReferenceSigner) mac(movieID, session, kind string, payload []byte) []byte {
+	h := hmac.New(sha256.New, r.macKey)
+	for _, part := range []string{movieID, session, kind} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(payload)
+	return h.Sum(nil)[:referenceMACSize]
+}
+
+func (r *ReferenceSigner) stream(nonce []byte) cipher.Stream {
+	block, _ := aes.NewCipher(r.encKey)
+	return cipher.NewCTR(block, nonce)
+}
+
+func deriveKey(secret []byte, label string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
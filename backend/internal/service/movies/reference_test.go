@@ -0,0 +1,82 @@
+package movies
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReferenceRoundTrip(t *testing.T) {
+	signer := NewReferenceSigner([]byte("secret"), time.Minute)
+	now := time.Now()
+	target := "https://cdn.example.com/hls/seg-001.ts?sig=abc"
+
+	ref := signer.sign("movie-1", "token-1", "segment", target, now)
+	if strings.Contains(ref, "cdn.example.com") {
+		t.Fatalf("reference leaks upstream host: %s", ref)
+	}
+
+	got, err := signer.open("movie-1", "token-1", "segment", ref, now)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got != target {
+		t.Fatalf("expected %q, got %q", target, got)
+	}
+}
+
+func TestReferenceRejectsForeignSessions(t *testing.T) {
+	signer := NewReferenceSigner([]byte("secret"), time.Minute)
+	now := time.Now()
+	ref := signer.sign("movie-1", "token-1", "segment", "https://cdn.example.com/a.ts", now)
+
+	cases := []struct {
+		name, movieID, token, kind string
+	}{
+		{"other movie", "movie-2", "token-1", "segment"},
+		{"other token", "movie-1", "token-2", "segment"},
+		{"other kind", "movie-1", "token-1", "key"},
+	}
+	for _, tc := range cases {
+		if _, err := signer.open(tc.movieID, tc.token, tc.kind, ref, now); !errors.Is(err, ErrInvalidReference) {
+			t.Fatalf("%s: expected ErrInvalidReference, got %v", tc.name, err)
+		}
+	}
+
+	other := NewReferenceSigner([]byte("other-secret"), time.Minute)
+	if _, err := other.open("movie-1", "token-1", "segment", ref, now); !errors.Is(err, ErrInvalidReference) {
+		t.Fatalf("expected ErrInvalidReference for another secret, got %v", err)
+	}
+}
+
+func TestReferenceRejectsTampering(t *testing.T) {
+	signer := NewReferenceSigner([]byte("secret"), time.Minute)
+	now := time.Now()
+	ref := signer.sign("movie-1", "token-1", "segment", "https://cdn.example.com/a.ts", now)
+
+	for i := range ref {
+		tampered := []byte(ref)
+		if tampered[i] == 'A' {
+			tampered[i] = 'B'
+		} else {
+			tampered[i] = 'A'
+		}
+		if _, err := signer.open("movie-1", "token-1", "segment", string(tampered), now); err == nil {
+			t.Fatalf("expected tampered reference at %d to be rejected", i)
+		}
+	}
+	if _, err := signer.open("movie-1", "token-1", "segment", "https://evil.example.com/a.ts", now); err == nil {
+		t.Fatal("expected raw URL to be rejected")
+	}
+}
+
+func TestReferenceExpires(t *testing.T) {
+	signer := NewReferenceSigner([]byte("secret"), time.Minute)
+	now := time.Now()
+	ref := signer.sign("movie-1", "token-1", "segment", "https://cdn.example.com/a.ts", now)
+
+	if _, err := signer.open("movie-1", "token-1", "segment", ref, now.Add(2*time.Minute)); !errors.Is(err, ErrReferenceExpired) {
+		t.Fatalf("expected ErrReferenceExpired, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package movies
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+)
+
+// DefaultRenditionTier is the policy applied to sessions without a tier or
+// with a tier that has no policy of its own.
+const DefaultRenditionTier = "default"
+
+// RenditionPolicy caps the renditions of a master playlist a session may
+// play. Zero values leave the respective limit off.
+type RenditionPolicy struct {
+	MaxHeight    int
+	MaxBandwidth int64
+	// Codecs lists allowed codec prefixes such as "avc1" or "mp4a"; every
+	// codec a variant declares must match one of them.
+	Codecs []string
+}
+
+// Unrestricted reports whether the policy allows every rendition.
+func (p RenditionPolicy) Unrestricted() bool {
+	return p.MaxHeight <= 0 && p.MaxBandwidth <= 0 && len(p.Codecs) == 0
+}
+
+// Allows reports whether the policy permits variant. Attributes the variant
+// does not declare are not held against it.
+func (p RenditionPolicy) Allows(variant hls.Variant) bool {
+	if p.MaxBandwidth > 0 && variant.Bandwidth > p.MaxBandwidth {
+		return false
+	}
+	if p.MaxHeight > 0 {
+		if _, raw, ok := strings.Cut(variant.Resolution, "x"); ok {
+			if height, err := strconv.Atoi(raw); err == nil && height > p.MaxHeight {
+				return false
+			}
+		}
+	}
+	if len(p.Codecs) > 0 && variant.Codecs != "" {
+		for _, codec := range strings.Split(variant.Codecs, ",") {
+			if !p.allowsCodec(strings.TrimSpace(codec)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p RenditionPolicy) allowsCodec(codec string) bool {
+	for _, prefix := range p.Codecs {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRenditionPolicies reads policies by tier from a list such as
+// "free:height=720,bandwidth=3000000,codecs=avc1+mp4a;mobile:height=480".
+// Malformed entries are skipped and reported in the error, so the
+// well-formed ones can still be used.
+func ParseRenditionPolicies(raw string) (map[string]RenditionPolicy, error) {
+	policies := make(map[string]RenditionPolicy)
+	var invalid []string
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tier, rules, _ := strings.Cut(entry, ":")
+		policy, err := parseRenditionPolicy(rules)
+		tier = strings.TrimSpace(tier)
+		if tier == "" || err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		policies[tier] = policy
+	}
+	if len(invalid) > 0 {
+		return policies, fmt.Errorf("invalid rendition policies: %s", strings.Join(invalid, "; "))
+	}
+	return policies, nil
+}
+
+func parseRenditionPolicy(raw string) (RenditionPolicy, error) {
+	var policy RenditionPolicy
+	for _, rule := range strings.Split(raw, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(rule, "=")
+		value = strings.TrimSpace(value)
+		var err error
+		switch strings.TrimSpace(key) {
+		case "height":
+			policy.MaxHeight, err = strconv.Atoi(value)
+		case "bandwidth":
+			policy.MaxBandwidth, err = strconv.ParseInt(value, 10, 64)
+		case "codecs":
+			for _, codec := range strings.Split(value, "+") {
+				if codec = strings.TrimSpace(codec); codec != "" {
+					policy.Codecs = append(policy.Codecs, codec)
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown rule %q", key)
+		}
+		if err != nil {
+			return RenditionPolicy{}, err
+		}
+	}
+	return policy, nil
+}
+
+// WithRenditionPolicies sets the rendition policy of each viewer tier. The
+// DefaultRenditionTier entry, if any, applies to every other session.
+func WithRenditionPolicies(policies map[string]RenditionPolicy) Option {
+	return func(s *Service) {
+		s.renditions = policies
+	}
+}
+
+func (s *Service) renditionPolicy(tier string) RenditionPolicy {
+	if policy, ok := s.renditions[tier]; ok && tier != "" {
+		return policy
+	}
+	return s.renditions[DefaultRenditionTier]
+}
+
+// FilterRenditions drops the variants of a master playlist the session may
+// not play. Media playlists are returned unchanged.
+//
+// This is what enforces the policy: the variant playlists of a stream are
+// only reachable through the session bound references issued for the
+// variants of the filtered master, so the renditions dropped here never get
+// one. Rendition reports, which point at sibling renditions from a media
+// playlist, are left out for restricted sessions for the same reason, see
+// RenditionReports.
+func (s *Service) FilterRenditions(access StreamAccess, playlist hls.Playlist) hls.Playlist {
+	if !playlist.Master || access.Renditions.Unrestricted() {
+		return playlist
+	}
+	return playlist.FilterVariants(access.Renditions.Allows)
+}
+
+// RenditionReports reports whether the #EXT-X-RENDITION-REPORT tags of media
+// playlists may be passed on to the session. They name other renditions
+// without their attributes, so whether the policy allows them is unknown;
+// players only use them to speed up switching and do without.
+func (a StreamAccess) RenditionReports() bool {
+	return a.Renditions.Unrestricted()
+}
@@ -0,0 +1,45 @@
+package movies
+
+import (
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+)
+
+func TestParseRenditionPolicies(t *testing.T) {
+	policies, err := ParseRenditionPolicies("free:height=720,bandwidth=3000000,codecs=avc1+mp4a; mobile:height=480;broken:depth=3")
+	if err == nil {
+		t.Fatalf("expected the broken entry to be reported")
+	}
+	if _, ok := policies["broken"]; ok {
+		t.Fatalf("expected the broken entry to be skipped")
+	}
+
+	free := policies["free"]
+	if free.MaxHeight != 720 || free.MaxBandwidth != 3000000 || len(free.Codecs) != 2 {
+		t.Fatalf("unexpected free policy %+v", free)
+	}
+	if policies["mobile"].MaxHeight != 480 {
+		t.Fatalf("unexpected mobile policy %+v", policies["mobile"])
+	}
+}
+
+func TestRenditionPolicyAllows(t *testing.T) {
+	policy := RenditionPolicy{MaxHeight: 720, MaxBandwidth: 3000000, Codecs: []string{"avc1", "mp4a"}}
+
+	cases := []struct {
+		variant hls.Variant
+		want    bool
+	}{
+		{hls.Variant{Bandwidth: 2400000, Resolution: "1280x720", Codecs: "avc1.4d401f,mp4a.40.2"}, true},
+		{hls.Variant{Bandwidth: 2400000, Resolution: "1920x1080"}, false},
+		{hls.Variant{Bandwidth: 4000000, Resolution: "1280x720"}, false},
+		{hls.Variant{Bandwidth: 2400000, Codecs: "hvc1.1.6.L93.90,mp4a.40.2"}, false},
+		{hls.Variant{Bandwidth: 800000}, true},
+	}
+	for _, tc := range cases {
+		if got := policy.Allows(tc.variant); got != tc.want {
+			t.Errorf("Allows(%+v) = %v, want %v", tc.variant, got, tc.want)
+		}
+	}
+}
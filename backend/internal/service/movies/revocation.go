@@ -0,0 +1,134 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+)
+
+// RevocationScope is what a revocation applies to.
+type RevocationScope string
+
+const (
+	RevokeToken  RevocationScope = "token"
+	RevokeViewer RevocationScope = "viewer"
+	RevokeMovie  RevocationScope = "movie"
+)
+
+const maxRevocationsListed = 500
+
+var (
+	ErrTokenRevoked = errors.New("playback token revoked")
+	// ErrMissingViewerID is returned when revoking the tokens of a viewer
+	// without naming one.
+	ErrMissingViewerID = errors.New("viewer ID required")
+	// ErrRevocationNotAudited is returned when a revocation took effect but
+	// its audit record could not be written.
+	ErrRevocationNotAudited = errors.New("revocation applied but not audited")
+	// ErrRevocationsUnavailable is returned when the revocation list could
+	// not be read or written. It says nothing about the token, which may be
+	// retried.
+	ErrRevocationsUnavailable = errors.New("revocation list unavailable")
+)
+
+// WithRevocations sets the list revoked sessions are checked against and the
+// repository auditing revocations.
+func WithRevocations(list RevocationList, audit *repository.RevocationRepository) Option {
+	return func(s *Service) {
+		s.revocations = list
+		s.revocationAudit = audit
+	}
+}
+
+// RevokePlaybackToken revokes token, which must have been issued for the
+// movie, together with the tokens its session was or will be refreshed to.
+// Expired tokens can be revoked too, as they may still be refreshed.
+func (s *Service) RevokePlaybackToken(ctx context.Context, slug, token, reason, actor string) (repository.TokenRevocation, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return repository.TokenRevocation{}, err
+	}
+	claims, ok, err := s.signer.ValidateToken(token, movie.ID)
+	if err != nil && !errors.Is(err, ErrTokenExpired) {
+		return repository.TokenRevocation{}, err
+	}
+	if !ok && err == nil {
+		return repository.TokenRevocation{}, ErrInvalidToken
+	}
+
+	session := claims.session(token)
+	until := claims.SessionExpiresAt
+	if claims.SessionID == "" && !claims.ExpiresAt.IsZero() {
+		// A token issued before sessions can be refreshed into one until
+		// the refresh grace has passed.
+		until = claims.ExpiresAt.Add(tokenRefreshGrace)
+	}
+	if err := s.revocations.RevokeSession(ctx, session, until); err != nil {
+		return repository.TokenRevocation{}, err
+	}
+	return s.auditRevocation(ctx, repository.TokenRevocation{
+		Scope:     string(RevokeToken),
+		MovieID:   movie.ID,
+		ViewerID:  claims.ViewerID,
+		SessionID: session,
+		Reason:    reason,
+		Actor:     actor,
+	})
+}
+
+// RevokeViewerTokens revokes the sessions of viewerID on every movie started
+// until now.
+func (s *Service) RevokeViewerTokens(ctx context.Context, viewerID, reason, actor string) (repository.TokenRevocation, error) {
+	viewerID = strings.TrimSpace(viewerID)
+	if viewerID == "" {
+		return repository.TokenRevocation{}, ErrMissingViewerID
+	}
+	if err := s.revocations.RevokeBefore(ctx, RevokeViewer, viewerID, s.now(), s.sessionMaxAge); err != nil {
+		return repository.TokenRevocation{}, err
+	}
+	return s.auditRevocation(ctx, repository.TokenRevocation{
+		Scope:    string(RevokeViewer),
+		ViewerID: viewerID,
+		Reason:   reason,
+		Actor:    actor,
+	})
+}
+
+// RevokeMovieTokens revokes every session of the movie started until now.
+// Viewers can request new tokens right away.
+func (s *Service) RevokeMovieTokens(ctx context.Context, slug, reason, actor string) (repository.TokenRevocation, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return repository.TokenRevocation{}, err
+	}
+	if err := s.revocations.RevokeBefore(ctx, RevokeMovie, movie.ID, s.now(), s.sessionMaxAge); err != nil {
+		return repository.TokenRevocation{}, err
+	}
+	return s.auditRevocation(ctx, repository.TokenRevocation{
+		Scope:   string(RevokeMovie),
+		MovieID: movie.ID,
+		Reason:  reason,
+		Actor:   actor,
+	})
+}
+
+// Revocations returns up to limit audited revocations, newest first.
+func (s *Service) Revocations(ctx context.Context, limit int) ([]repository.TokenRevocation, error) {
+	if limit <= 0 || limit > maxRevocationsListed {
+		limit = maxRevocationsListed
+	}
+	return s.revocationAudit.ListRevocations(ctx, limit)
+}
+
+// auditRevocation records a revocation that has already taken effect.
+func (s *Service) auditRevocation(ctx context.Context, revocation repository.TokenRevocation) (repository.TokenRevocation, error) {
+	revocation.RevokedAt = s.now().UTC()
+	recorded, err := s.revocationAudit.RecordRevocation(ctx, revocation)
+	if err != nil {
+		return revocation, fmt.Errorf("%w: %v", ErrRevocationNotAudited, err)
+	}
+	return recorded, nil
+}
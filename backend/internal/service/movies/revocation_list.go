@@ -0,0 +1,236 @@
+package movies
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevocationList holds revoked playback sessions and, per viewer and movie,
+// the time before which started sessions are revoked. It also holds, per
+// session, the time before which issued tokens were superseded by a refresh.
+// It is checked on every stream request, so it has to be shared by all
+// replicas.
+type RevocationList interface {
+	// RevokeSession revokes a session until it would have ended anyway;
+	// a zero until keeps the revocation forever.
+	RevokeSession(ctx context.Context, sessionID string, until time.Time) error
+	// RevokeBefore revokes the sessions of a viewer or movie started before
+	// cutoff. The revocation is kept for ttl, or forever when it is zero.
+	RevokeBefore(ctx context.Context, scope RevocationScope, id string, cutoff time.Time, ttl time.Duration) error
+	// SupersedeSession revokes the tokens of a session issued before cutoff,
+	// the issue time of the token that replaced them, once
+	// supersededTokenOverlap has passed. It is kept until until.
+	SupersedeSession(ctx context.Context, sessionID string, cutoff, until time.Time) error
+	// Revoked reports whether the session of claims has been revoked. A
+	// superseded token is reported with ErrTokenSuperseded.
+	Revoked(ctx context.Context, sessionID string, claims TokenClaims) (bool, error)
+}
+
+// supersededTokenOverlap is how long a refreshed token keeps working, for the
+// requests the player started before switching to the new one.
+const supersededTokenOverlap = 30 * time.Second
+
+// superseded reports whether a refresh at cutoff replaced the token of claims
+// long enough ago for it to be refused.
+func superseded(claims TokenClaims, cutoff, now time.Time) bool {
+	return claims.IssuedAt.UnixMilli() < cutoff.UnixMilli() && !now.Before(cutoff.Add(supersededTokenOverlap))
+}
+
+// RedisRevocationList keeps revocations in Redis next to the playback
+// tokens. Revoked fails closed: without Redis, stream requests are refused.
+type RedisRevocationList struct {
+	client *redis.Client
+}
+
+func NewRedisRevocationList(client *redis.Client) *RedisRevocationList {
+	return &RedisRevocationList{client: client}
+}
+
+func (l *RedisRevocationList) RevokeSession(ctx context.Context, sessionID string, until time.Time) error {
+	var ttl time.Duration
+	if !until.IsZero() {
+		ttl = time.Until(until)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	return l.client.Set(ctx, redisRevokedSessionKey(sessionID), "1", ttl).Err()
+}
+
+func (l *RedisRevocationList) RevokeBefore(ctx context.Context, scope RevocationScope, id string, cutoff time.Time, ttl time.Duration) error {
+	return l.client.Set(ctx, redisRevokedBeforeKey(scope, id), cutoff.UnixMilli(), ttl).Err()
+}
+
+func (l *RedisRevocationList) SupersedeSession(ctx context.Context, sessionID string, cutoff, until time.Time) error {
+	var ttl time.Duration
+	if !until.IsZero() {
+		ttl = time.Until(until)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	return l.client.Set(ctx, redisSupersededKey(sessionID), cutoff.UnixMilli(), ttl).Err()
+}
+
+func (l *RedisRevocationList) Revoked(ctx context.Context, sessionID string, claims TokenClaims) (bool, error) {
+	values, err := l.client.MGet(ctx,
+		redisRevokedSessionKey(sessionID),
+		redisRevokedBeforeKey(RevokeViewer, claims.ViewerID),
+		redisRevokedBeforeKey(RevokeMovie, claims.MovieID),
+		redisSupersededKey(sessionID),
+	).Result()
+	if err != nil {
+		return false, err
+	}
+	if values[0] != nil {
+		return true, nil
+	}
+	for i, id := range []string{claims.ViewerID, claims.MovieID} {
+		raw, ok := values[i+1].(string)
+		if !ok || id == "" {
+			continue
+		}
+		cutoff, err := strconv.ParseInt(raw, 10, 64)
+		if err == nil && startedBefore(claims, time.UnixMilli(cutoff)) {
+			return true, nil
+		}
+	}
+	if raw, ok := values[3].(string); ok {
+		cutoff, err := strconv.ParseInt(raw, 10, 64)
+		if err == nil && superseded(claims, time.UnixMilli(cutoff), time.Now()) {
+			return false, ErrTokenSuperseded
+		}
+	}
+	return false, nil
+}
+
+func redisRevokedSessionKey(sessionID string) string {
+	return "playback:revoked:session:" + sessionID
+}
+
+func redisRevokedBeforeKey(scope RevocationScope, id string) string {
+	return "playback:revoked:" + string(scope) + ":" + id
+}
+
+func redisSupersededKey(sessionID string) string {
+	return "playback:superseded:" + sessionID
+}
+
+// InMemoryRevocationList keeps revocations in process memory, for
+// development, tests and running without Redis. Revocations then only apply
+// to the replica they were made on.
+type InMemoryRevocationList struct {
+	mu       sync.Mutex
+	sessions map[string]time.Time
+	cutoffs  map[string]memoryCutoff
+	// superseded holds the refresh cutoffs by session. One is added on
+	// every refresh, so lapsed ones are only purged once the map doubled.
+	superseded      map[string]memoryCutoff
+	supersededPurge int
+	now             func() time.Time
+}
+
+type memoryCutoff struct {
+	at time.Time
+	// expires is zero for revocations kept forever.
+	expires time.Time
+}
+
+func NewInMemoryRevocationList() *InMemoryRevocationList {
+	return &InMemoryRevocationList{
+		sessions:        make(map[string]time.Time),
+		cutoffs:         make(map[string]memoryCutoff),
+		superseded:      make(map[string]memoryCutoff),
+		supersededPurge: minSupersededPurge,
+		now:             time.Now,
+	}
+}
+
+func (l *InMemoryRevocationList) RevokeSession(_ context.Context, sessionID string, until time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.purgeLocked(l.now())
+	l.sessions[sessionID] = until
+	return nil
+}
+
+func (l *InMemoryRevocationList) RevokeBefore(_ context.Context, scope RevocationScope, id string, cutoff time.Time, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.purgeLocked(now)
+	entry := memoryCutoff{at: cutoff}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	l.cutoffs[string(scope)+":"+id] = entry
+	return nil
+}
+
+// minSupersededPurge is the least number of refresh cutoffs the in-memory
+// list holds before purging lapsed ones.
+const minSupersededPurge = 1024
+
+func (l *InMemoryRevocationList) SupersedeSession(_ context.Context, sessionID string, cutoff, until time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.superseded) >= l.supersededPurge {
+		now := l.now()
+		for id, entry := range l.superseded {
+			if !entry.expires.IsZero() && !now.Before(entry.expires) {
+				delete(l.superseded, id)
+			}
+		}
+		l.supersededPurge = max(2*len(l.superseded), minSupersededPurge)
+	}
+	l.superseded[sessionID] = memoryCutoff{at: cutoff, expires: until}
+	return nil
+}
+
+func (l *InMemoryRevocationList) Revoked(_ context.Context, sessionID string, claims TokenClaims) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if until, ok := l.sessions[sessionID]; ok && (until.IsZero() || now.Before(until)) {
+		return true, nil
+	}
+	for _, key := range []string{string(RevokeViewer) + ":" + claims.ViewerID, string(RevokeMovie) + ":" + claims.MovieID} {
+		entry, ok := l.cutoffs[key]
+		if ok && (entry.expires.IsZero() || now.Before(entry.expires)) && startedBefore(claims, entry.at) {
+			return true, nil
+		}
+	}
+	if entry, ok := l.superseded[sessionID]; ok && (entry.expires.IsZero() || now.Before(entry.expires)) && superseded(claims, entry.at, now) {
+		return false, ErrTokenSuperseded
+	}
+	return false, nil
+}
+
+// purgeLocked drops lapsed revocations; they are only added by admins, so
+// this is done when adding rather than in the background.
+func (l *InMemoryRevocationList) purgeLocked(now time.Time) {
+	for id, until := range l.sessions {
+		if !until.IsZero() && !now.Before(until) {
+			delete(l.sessions, id)
+		}
+	}
+	for key, entry := range l.cutoffs {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			delete(l.cutoffs, key)
+		}
+	}
+}
+
+// startedBefore reports whether the session of claims started before cutoff.
+// Tokens issued before sessions carry no start and count as started before.
+func startedBefore(claims TokenClaims, cutoff time.Time) bool {
+	return claims.SessionStartedAt.IsZero() || claims.SessionStartedAt.UnixMilli() < cutoff.UnixMilli()
+}
@@ -0,0 +1,144 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+)
+
+func TestTokenRevocation(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	repo := repository.NewMovieRepository(nil)
+	movie := domain.Movie{
+		ID:                "movie-revoked",
+		Slug:              "revoked-movie",
+		Title:             "Revoked Movie",
+		IsVisible:         true,
+		AvailabilityStart: now.Add(-24 * time.Hour),
+		AvailabilityEnd:   now.Add(24 * time.Hour),
+		StreamURL:         "https://cdn.example.com/master.m3u8",
+	}
+	repo.UpsertSampleMovie(movie)
+
+	keyring, _ := ParseTokenKeyring("k1:0123456789abcdef")
+	signer := NewHMACTokenSigner(keyring)
+	signer.now = clock
+	revocations := NewInMemoryRevocationList()
+	revocations.now = clock
+	svc := NewService(repo, signer, time.Minute,
+		WithSessionMaxAge(time.Hour),
+		WithRevocations(revocations, repository.NewRevocationRepository(nil)),
+	)
+	svc.now = clock
+	ctx := context.Background()
+
+	issue := func(viewerID string) string {
+		t.Helper()
+		issued, err := svc.CreatePlaybackToken(ctx, movie, Viewer{ID: viewerID})
+		if err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+		return issued.Token
+	}
+	assertRevoked := func(name, token, viewerID string, want bool) {
+		t.Helper()
+		_, err := svc.ResolveStream(ctx, movie.Slug, token, Viewer{ID: viewerID})
+		if got := errors.Is(err, ErrTokenRevoked); got != want {
+			t.Fatalf("%s: expected revoked=%v, got %v", name, want, err)
+		}
+		if !want && err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+
+	// A revoked token takes the tokens of its session with it.
+	leaked := issue("viewer-1")
+	refreshed, err := svc.RefreshPlaybackToken(ctx, movie.Slug, leaked, Viewer{ID: "viewer-1"})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	other := issue("viewer-1")
+	revocation, err := svc.RevokePlaybackToken(ctx, movie.Slug, leaked, "shared on a forum", "alice")
+	if err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if revocation.Scope != "token" || revocation.SessionID == "" || revocation.ViewerID != "viewer-1" || revocation.Actor != "alice" {
+		t.Fatalf("unexpected audit record %+v", revocation)
+	}
+	assertRevoked("leaked token", leaked, "viewer-1", true)
+	assertRevoked("refreshed token", refreshed.Token, "viewer-1", true)
+	assertRevoked("other session", other, "viewer-1", false)
+	if _, err := svc.RefreshPlaybackToken(ctx, movie.Slug, refreshed.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected a revoked session not to be refreshed, got %v", err)
+	}
+	if _, err := svc.RevokePlaybackToken(ctx, movie.Slug, "forged", "", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a forged token to be refused, got %v", err)
+	}
+
+	// Viewer and movie revocations apply to sessions started before them.
+	bystander := issue("viewer-2")
+	now = now.Add(time.Second)
+	if _, err := svc.RevokeViewerTokens(ctx, "viewer-1", "", ""); err != nil {
+		t.Fatalf("revoke viewer: %v", err)
+	}
+	assertRevoked("viewer session", other, "viewer-1", true)
+	assertRevoked("other viewer", bystander, "viewer-2", false)
+	assertRevoked("new viewer session", issue("viewer-1"), "viewer-1", false)
+
+	now = now.Add(time.Second)
+	if _, err := svc.RevokeMovieTokens(ctx, movie.Slug, "", ""); err != nil {
+		t.Fatalf("revoke movie: %v", err)
+	}
+	assertRevoked("movie session", bystander, "viewer-2", true)
+	assertRevoked("new movie session", issue("viewer-2"), "viewer-2", false)
+
+	// Revocations lapse once the sessions they cover have ended.
+	now = now.Add(time.Hour)
+	if revoked, _ := revocations.Revoked(ctx, "session", TokenClaims{MovieID: movie.ID, ViewerID: "viewer-2", SessionStartedAt: now.Add(-2 * time.Hour)}); revoked {
+		t.Fatalf("expected the movie revocation to lapse after the maximum session length")
+	}
+
+	audit, err := svc.Revocations(ctx, 10)
+	if err != nil || len(audit) != 3 || audit[0].Scope != "movie" || audit[2].Reason != "shared on a forum" {
+		t.Fatalf("unexpected audit trail %+v (%v)", audit, err)
+	}
+}
+
+func TestUnreachableRevocationListIsNotARevocation(t *testing.T) {
+	repo := repository.NewMovieRepository(nil)
+	movie := domain.Movie{
+		ID:                "movie-revocations-down",
+		Slug:              "revocations-down-movie",
+		Title:             "Revocations Down Movie",
+		IsVisible:         true,
+		AvailabilityStart: time.Now().Add(-time.Hour),
+		AvailabilityEnd:   time.Now().Add(time.Hour),
+		StreamURL:         "https://cdn.example.com/master.m3u8",
+	}
+	repo.UpsertSampleMovie(movie)
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	keyring, _ := ParseTokenKeyring("k1:0123456789abcdef")
+	svc := NewService(repo, NewHMACTokenSigner(keyring), time.Minute, WithRevocations(NewRedisRevocationList(client), nil))
+	ctx := context.Background()
+
+	issued, err := svc.CreatePlaybackToken(ctx, movie, Viewer{ID: "viewer-1"})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if _, err := svc.ResolveStream(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrRevocationsUnavailable) {
+		t.Fatalf("expected ErrRevocationsUnavailable, got %v", err)
+	}
+	if _, err := svc.RefreshPlaybackToken(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrRevocationsUnavailable) {
+		t.Fatalf("expected ErrRevocationsUnavailable on refresh, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package movies
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+// ErrSegmentNotCacheable tells callers to stream the segment straight from
+// the upstream instead, either because caching is disabled or the segment is
+// larger than a cache entry may be.
+var ErrSegmentNotCacheable = errors.New("segment not cacheable")
+
+// OversizedSegmentError is returned by FetchSegment for a segment larger than
+// a cache entry may be. Response is the upstream response that found out,
+// its body still at the first byte, so the segment is streamed without being
+// fetched twice. It is nil when the fetch was made for a concurrent request.
+type OversizedSegmentError struct {
+	Response *http.Response
+}
+
+func (e *OversizedSegmentError) Error() string {
+	return ErrSegmentNotCacheable.Error()
+}
+
+func (e *OversizedSegmentError) Unwrap() error {
+	return ErrSegmentNotCacheable
+}
+
+type UpstreamStatusError struct {
+	StatusCode int
+}
+
+func (e UpstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream responded with status %d", e.StatusCode)
+}
+
+// WithSegmentCache puts segmentCache in front of upstream segment fetches.
+func WithSegmentCache(segmentCache *cache.SegmentCache) Option {
+	return func(s *Service) {
+		s.segments = segmentCache
+	}
+}
+
+// CachedSegment returns a cached copy of target without contacting the
+// upstream.
+func (s *Service) CachedSegment(target string) (cache.SegmentEntry, bool) {
+	if s.segments == nil {
+		return cache.SegmentEntry{}, false
+	}
+	return s.segments.Get(target)
+}
+
+// FetchSegment returns the full segment at target, serving it from the cache
+// when possible. The bool result reports a cache hit.
+func (s *Service) FetchSegment(ctx context.Context, access StreamAccess, target string) (cache.SegmentEntry, bool, error) {
+	if s.segments == nil {
+		return cache.SegmentEntry{}, false, ErrSegmentNotCacheable
+	}
+	ctx = upstream.WithAllowedHosts(ctx, access.AllowedHosts)
+
+	limit := s.segments.MaxEntryBytes()
+	var handoff oversizedHandoff
+	entry, hit, err := s.segments.GetOrFetch(ctx, target, func(ctx context.Context) (cache.SegmentEntry, error) {
+		resp, err := s.FetchUpstream(ctx, access, http.MethodGet, target, nil)
+		if err != nil {
+			return cache.SegmentEntry{}, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return cache.SegmentEntry{}, UpstreamStatusError{StatusCode: resp.StatusCode}
+		}
+		if resp.ContentLength > limit {
+			handoff.offer(resp, nil)
+			return cache.SegmentEntry{}, cache.ErrSegmentTooLarge
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		if err != nil {
+			resp.Body.Close()
+			return cache.SegmentEntry{}, err
+		}
+		if int64(len(body)) > limit {
+			handoff.offer(resp, body)
+			return cache.SegmentEntry{}, cache.ErrSegmentTooLarge
+		}
+		resp.Body.Close()
+
+		return cache.SegmentEntry{
+			Body:         body,
+			ContentType:  resp.Header.Get("Content-Type"),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}, nil
+	})
+	resp := handoff.take()
+	if errors.Is(err, cache.ErrSegmentTooLarge) {
+		return cache.SegmentEntry{}, false, &OversizedSegmentError{Response: resp}
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return entry, hit, err
+}
+
+// oversizedHandoff passes the response of an oversized segment from the cache
+// fetch to the request that made it. A response offered after the request
+// gave up waiting is closed.
+type oversizedHandoff struct {
+	mu    sync.Mutex
+	taken bool
+	resp  *http.Response
+}
+
+// offer hands over resp, of which read has already been consumed.
+func (h *oversizedHandoff) offer(resp *http.Response, read []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.taken {
+		resp.Body.Close()
+		return
+	}
+	if len(read) > 0 {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(read), resp.Body), resp.Body}
+	}
+	h.resp = resp
+}
+
+func (h *oversizedHandoff) take() *http.Response {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.taken = true
+	return h.resp
+}
+
+// SegmentCacheStats reports hit/miss counters, or false when caching is off.
+func (s *Service) SegmentCacheStats() (cache.SegmentCacheStats, bool) {
+	if s.segments == nil {
+		return cache.SegmentCacheStats{}, false
+	}
+	return s.segments.Stats(), true
+}
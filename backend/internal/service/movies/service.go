@@ -4,11 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
 	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/watermark"
 )
 
 var (
@@ -16,30 +23,157 @@ var (
 	ErrMovieNotFound    = sql.ErrNoRows
 )
 
+// TokenClaims is what a playback token was issued for.
+type TokenClaims struct {
+	MovieID  string
+	ViewerID string
+	// Tier selects the rendition policy of the session.
+	Tier string
+	// IPPrefix and UserAgentHash bind the token to the viewer's network and
+	// browser when the movie's TokenBinding asks for it.
+	IPPrefix      string
+	UserAgentHash string
+	// SessionID identifies the playback session, which outlives the token
+	// through refreshes; empty for tokens issued before sessions, whose
+	// session is the token itself.
+	SessionID string
+	// SessionStartedAt is when the first token of the session was issued;
+	// viewer and movie revocations apply to sessions started before them.
+	SessionStartedAt time.Time
+	// SessionExpiresAt ends the session regardless of refreshes.
+	SessionExpiresAt time.Time
+	// IssuedAt orders the tokens of a session, so that a refresh can
+	// supersede the ones before it; zero for tokens issued before that.
+	IssuedAt time.Time
+	// ExpiresAt is set by ValidateToken to the expiry of the token.
+	ExpiresAt time.Time
+}
+
 type TokenSigner interface {
-	SignToken(movieID, viewerID string, ttl time.Duration) (string, error)
-	ValidateToken(token, movieID string) (bool, error)
+	SignToken(claims TokenClaims, ttl time.Duration) (string, error)
+	// ValidateToken returns the claims of token and whether it is valid for
+	// movieID. An authentic token past its expiry is reported with its
+	// claims and ErrTokenExpired, for as long as the signer recognises it.
+	ValidateToken(token, movieID string) (TokenClaims, bool, error)
+}
+
+// Viewer identifies who a playback token is issued to.
+type Viewer struct {
+	ID string
+	// Tier is the viewer's subscription tier or device class, as asserted by
+	// the gateway in front of the API.
+	Tier string
+	// IP and UserAgent describe the client, for movies that bind tokens to
+	// them.
+	IP        string
+	UserAgent string
 }
 
 type Service struct {
-	repo     *repository.MovieRepository
-	signer   TokenSigner
-	tokenTTL time.Duration
-	now      func() time.Time
+	repo             *repository.MovieRepository
+	signer           TokenSigner
+	tokenTTL         time.Duration
+	sessionMaxAge    time.Duration
+	upstream         *upstream.Fetcher
+	segments         *cache.SegmentCache
+	playlists        *cache.PlaylistCache
+	maxPlaylistBytes int64
+	live             *liveTracker
+	references       *ReferenceSigner
+	secrets          *secrets.Box
+	keys             *keys.Manager
+	marker           *watermark.Marker
+	watermarks       *repository.WatermarkRepository
+	health           *sourceHealth
+	checks           *repository.StreamCheckRepository
+	renditions       map[string]RenditionPolicy
+	captions         *cache.CaptionCache
+	revocations      RevocationList
+	revocationAudit  *repository.RevocationRepository
+	now              func() time.Time
+}
+
+type Option func(*Service)
+
+// WithUpstream sets the fetcher used for requests to stream origins.
+func WithUpstream(fetcher *upstream.Fetcher) Option {
+	return func(s *Service) {
+		s.upstream = fetcher
+	}
 }
 
 type StreamAccess struct {
-	URL          string
+	MovieID string
+	// SessionID is the playback session references and the watermark
+	// pattern are derived from.
+	SessionID string
+	// URL is the preferred stream source, Sources[0].
+	URL string
+	// Sources lists the primary stream URL and its mirrors, healthy sources
+	// first and otherwise in priority order.
+	Sources      []string
 	AllowedHosts []string
+	// Header holds the stream's upstream request headers, secrets opened.
+	Header http.Header
+	// KeyID is the DRM key ID whose content key the built-in key server
+	// releases for this stream.
+	KeyID string
+	// EncryptSegments is set when clear segments are to be encrypted with
+	// the KeyID content key while proxied.
+	EncryptSegments bool
+	// WatermarkURL is the B variant of a watermarked stream.
+	WatermarkURL string
+	// WatermarkPattern selects the A or B variant of each segment.
+	WatermarkPattern uint64
+	// Renditions is the rendition policy of the session's viewer tier.
+	Renditions RenditionPolicy
+	// Captions are the movie's caption tracks.
+	Captions []movies.Caption
+
+	sourcesVersion string
 }
 
-func NewService(repo *repository.MovieRepository, signer TokenSigner, tokenTTL time.Duration) *Service {
-	return &Service{
-		repo:     repo,
-		signer:   signer,
-		tokenTTL: tokenTTL,
-		now:      time.Now,
+func NewService(repo *repository.MovieRepository, signer TokenSigner, tokenTTL time.Duration, opts ...Option) *Service {
+	s := &Service{
+		repo:             repo,
+		signer:           signer,
+		tokenTTL:         tokenTTL,
+		live:             newLiveTracker(),
+		health:           newSourceHealth(),
+		now:              time.Now,
+		maxPlaylistBytes: defaultMaxPlaylistBytes,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.upstream == nil {
+		s.upstream = upstream.New(upstream.DefaultConfig())
+	}
+	if s.references == nil {
+		s.references = NewReferenceSigner(nil, 0)
+	}
+	if s.secrets == nil {
+		s.secrets = secrets.NewBox(nil)
+	}
+	if s.marker == nil {
+		s.marker = watermark.NewMarker(nil)
+	}
+	if s.watermarks == nil {
+		s.watermarks = repository.NewWatermarkRepository(nil)
+	}
+	if s.checks == nil {
+		s.checks = repository.NewStreamCheckRepository(nil)
+	}
+	if s.keys == nil {
+		s.keys = keys.NewManager(repository.NewContentKeyRepository(nil), s.secrets)
+	}
+	if s.revocations == nil {
+		s.revocations = NewInMemoryRevocationList()
+	}
+	if s.revocationAudit == nil {
+		s.revocationAudit = repository.NewRevocationRepository(nil)
+	}
+	return s
 }
 
 func (s *Service) GetMovie(ctx context.Context, slug string) (movies.Movie, error) {
@@ -50,17 +184,42 @@ func (s *Service) ListMovies(ctx context.Context) ([]movies.Movie, error) {
 	return s.repo.ListMovies(ctx)
 }
 
-func (s *Service) CreatePlaybackToken(ctx context.Context, movie movies.Movie, viewerID string) (string, error) {
-	if !movie.IsAvailable(s.now()) {
-		return "", ErrMovieUnavailable
+// StreamSlugs returns the slugs of every movie with a stream source, visible
+// or not.
+func (s *Service) StreamSlugs(ctx context.Context) ([]string, error) {
+	return s.repo.ListStreamSlugs(ctx)
+}
+
+// CreatePlaybackToken starts a playback session of movie for viewer.
+func (s *Service) CreatePlaybackToken(ctx context.Context, movie movies.Movie, viewer Viewer) (PlaybackToken, error) {
+	now := s.now()
+	if !movie.IsAvailable(now) {
+		return PlaybackToken{}, ErrMovieUnavailable
 	}
 	if s.signer == nil {
-		return "", errors.New("token signer not configured")
+		return PlaybackToken{}, errors.New("token signer not configured")
+	}
+	claims := bindClaims(TokenClaims{
+		MovieID:          movie.ID,
+		ViewerID:         viewer.ID,
+		Tier:             viewer.Tier,
+		SessionID:        newSessionID(),
+		SessionStartedAt: now,
+		SessionExpiresAt: s.sessionExpiry(now),
+	}, movie.TokenBinding, viewer)
+	issued, err := s.issueToken(claims, now)
+	if err != nil {
+		return PlaybackToken{}, err
+	}
+	if err := s.recordWatermarkSession(ctx, movie, claims.SessionID, viewer.ID); err != nil {
+		return PlaybackToken{}, err
 	}
-	return s.signer.SignToken(movie.ID, viewerID, s.tokenTTL)
+	return issued, nil
 }
 
-func (s *Service) ResolveStream(ctx context.Context, slug, token string) (StreamAccess, error) {
+// ResolveStream checks token, and that viewer is the viewer it was issued to,
+// and returns how to reach the movie's stream.
+func (s *Service) ResolveStream(ctx context.Context, slug, token string, viewer Viewer) (StreamAccess, error) {
 	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
 	if err != nil {
 		return StreamAccess{}, err
@@ -70,24 +229,69 @@ func (s *Service) ResolveStream(ctx context.Context, slug, token string) (Stream
 		return StreamAccess{}, ErrMovieUnavailable
 	}
 
-	ok, err := s.signer.ValidateToken(token, movie.ID)
+	claims, err := s.validateToken(ctx, movie, token, viewer)
 	if err != nil {
 		return StreamAccess{}, err
 	}
-	if !ok {
-		return StreamAccess{}, errors.New("invalid token")
+
+	access, err := s.streamAccess(movie)
+	if err != nil {
+		return StreamAccess{}, err
 	}
+	access.SessionID = claims.session(token)
+	if access.Watermarked() {
+		access.WatermarkPattern = s.marker.Pattern(movie.ID, access.SessionID)
+	}
+	access.Renditions = s.renditionPolicy(claims.Tier)
+	return access, nil
+}
 
+// streamAccess builds the upstream side of a StreamAccess for movie; the
+// per-session fields are filled in by ResolveStream.
+func (s *Service) streamAccess(movie movies.Movie) (StreamAccess, error) {
 	allowed := append([]string{}, movie.AllowedStreamHosts...)
-	if parsed, err := url.Parse(movie.StreamURL); err == nil {
-		host := parsed.Hostname()
-		if host != "" {
-			allowed = append(allowed, host)
+	for _, streamURL := range append(movie.StreamSources(), movie.WatermarkURL) {
+		if parsed, err := url.Parse(streamURL); err == nil {
+			host := parsed.Hostname()
+			if host != "" {
+				allowed = append(allowed, host)
+			}
 		}
 	}
 
-	return StreamAccess{
-		URL:          movie.StreamURL,
-		AllowedHosts: allowed,
-	}, nil
+	header, err := s.upstreamHeader(movie.UpstreamHeaders)
+	if err != nil {
+		return StreamAccess{}, err
+	}
+
+	access := StreamAccess{
+		MovieID:         movie.ID,
+		Sources:         s.orderSources(movie.StreamSources()),
+		AllowedHosts:    allowed,
+		Header:          header,
+		KeyID:           movie.DRMKeyID,
+		EncryptSegments: movie.EncryptSegments,
+		WatermarkURL:    movie.WatermarkURL,
+		Captions:        movie.Captions,
+		sourcesVersion:  strings.Join(movie.StreamSources(), " "),
+	}
+	if len(access.Sources) > 0 {
+		access.URL = access.Sources[0]
+	}
+	return access, nil
+}
+
+// FetchUpstream requests target from the stream origin through the shared
+// upstream fetcher with the stream's upstream headers added, following
+// redirects only within the movie's allowed hosts. When the source serving
+// target fails, the same resource is requested from the stream's other
+// sources. The caller must close the response body.
+func (s *Service) FetchUpstream(ctx context.Context, access StreamAccess, method, target string, header http.Header) (*http.Response, error) {
+	return s.fetchWithFailover(ctx, access, target, func(ctx context.Context, target string) (*http.Response, error) {
+		return s.fetchUpstream(ctx, access, method, target, header)
+	})
+}
+
+func (s *Service) fetchUpstream(ctx context.Context, access StreamAccess, method, target string, header http.Header) (*http.Response, error) {
+	return s.upstream.Fetch(upstream.WithAllowedHosts(ctx, access.AllowedHosts), method, target, access.upstreamHeader(header))
 }
@@ -0,0 +1,180 @@
+package movies
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+// tokenRefreshGrace is how long after its expiry a token can still be
+// refreshed, so that a player that was suspended past the expiry can resume
+// the session. Stateful signers keep tokens around for as long.
+const tokenRefreshGrace = 2 * time.Minute
+
+var (
+	ErrInvalidToken = errors.New("invalid playback token")
+	// ErrTokenExpired is returned for an authentic token past its expiry.
+	// The session can be resumed with RefreshPlaybackToken.
+	ErrTokenExpired = errors.New("playback token expired")
+	// ErrSessionExpired is returned once a session reached its maximum
+	// length; a new token has to be requested.
+	ErrSessionExpired = errors.New("playback session expired")
+	// ErrTokenSuperseded is returned for a token that was refreshed, once
+	// the player had time to switch to the new one.
+	ErrTokenSuperseded = errors.New("playback token superseded")
+)
+
+// PlaybackToken is a token issued for a playback session.
+type PlaybackToken struct {
+	Token     string
+	ExpiresAt time.Time
+	// SessionExpiresAt is when the session ends regardless of refreshes;
+	// zero without a maximum session length.
+	SessionExpiresAt time.Time
+}
+
+// WithSessionMaxAge limits how long a playback session can be kept alive by
+// refreshing its token. Zero leaves sessions unlimited.
+func WithSessionMaxAge(maxAge time.Duration) Option {
+	return func(s *Service) {
+		s.sessionMaxAge = maxAge
+	}
+}
+
+// RefreshPlaybackToken issues a new token for the session of token, valid for
+// another token TTL but not past the end of the session. Players call it as a
+// heartbeat well before the token expires; the new token keeps the session,
+// so references and the watermark pattern of the old one stay valid. Tokens
+// that expired less than tokenRefreshGrace ago can be refreshed too.
+//
+// The new token supersedes token and every earlier one of the session: they
+// are refused supersededTokenOverlap after the refresh, so a copied token
+// stops working once the player holding it renews.
+func (s *Service) RefreshPlaybackToken(ctx context.Context, slug, token string, viewer Viewer) (PlaybackToken, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return PlaybackToken{}, err
+	}
+	now := s.now()
+	if !movie.IsAvailable(now) {
+		return PlaybackToken{}, ErrMovieUnavailable
+	}
+
+	claims, err := s.validateToken(ctx, movie, token, viewer)
+	if errors.Is(err, ErrTokenExpired) && now.Before(claims.ExpiresAt.Add(tokenRefreshGrace)) {
+		err = nil
+	}
+	if err != nil {
+		return PlaybackToken{}, err
+	}
+
+	if claims.SessionID == "" {
+		// Issued before sessions: the token was the session.
+		claims.SessionID = token
+		claims.SessionStartedAt = now
+		claims.SessionExpiresAt = s.sessionExpiry(now)
+	}
+	issued, err := s.issueToken(claims, now)
+	if err != nil {
+		return PlaybackToken{}, err
+	}
+
+	// The superseded tokens lapse by themselves within a TTL and the
+	// refresh grace of the refresh.
+	until := claims.SessionExpiresAt
+	if s.tokenTTL > 0 {
+		until = now.Add(s.tokenTTL + tokenRefreshGrace + supersededTokenOverlap)
+	}
+	if err := s.revocations.SupersedeSession(ctx, claims.SessionID, now, until); err != nil {
+		return PlaybackToken{}, fmt.Errorf("%w: %v", ErrRevocationsUnavailable, err)
+	}
+	return issued, nil
+}
+
+// validateToken checks token for movie and viewer, and that its session was
+// not revoked. With ErrTokenExpired the claims of the expired token are
+// returned too.
+func (s *Service) validateToken(ctx context.Context, movie movies.Movie, token string, viewer Viewer) (TokenClaims, error) {
+	claims, ok, err := s.signer.ValidateToken(token, movie.ID)
+	expired := errors.Is(err, ErrTokenExpired)
+	switch {
+	case expired:
+	case err != nil:
+		return TokenClaims{}, err
+	case !ok:
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.Revoked(ctx, claims.session(token), claims)
+	switch {
+	case errors.Is(err, ErrTokenSuperseded):
+		return TokenClaims{}, err
+	case err != nil:
+		return TokenClaims{}, fmt.Errorf("%w: %v", ErrRevocationsUnavailable, err)
+	case revoked:
+		return TokenClaims{}, ErrTokenRevoked
+	case expired && claims.sessionEnded(s.now()):
+		return TokenClaims{}, ErrSessionExpired
+	case !claims.boundTo(viewer):
+		return TokenClaims{}, ErrTokenBindingMismatch
+	case expired:
+		return claims, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// issueToken signs claims for the token TTL, cut short by the end of the
+// session.
+func (s *Service) issueToken(claims TokenClaims, now time.Time) (PlaybackToken, error) {
+	if claims.sessionEnded(now) {
+		return PlaybackToken{}, ErrSessionExpired
+	}
+	ttl := s.tokenTTL
+	if !claims.SessionExpiresAt.IsZero() && (ttl <= 0 || claims.SessionExpiresAt.Sub(now) < ttl) {
+		ttl = claims.SessionExpiresAt.Sub(now)
+	}
+	claims.ExpiresAt = time.Time{}
+	claims.IssuedAt = now
+
+	token, err := s.signer.SignToken(claims, ttl)
+	if err != nil {
+		return PlaybackToken{}, err
+	}
+	issued := PlaybackToken{Token: token, SessionExpiresAt: claims.SessionExpiresAt}
+	if ttl > 0 {
+		issued.ExpiresAt = now.Add(ttl)
+	}
+	return issued, nil
+}
+
+// sessionExpiry returns the end of a session starting now, in whole seconds
+// as signed tokens carry it.
+func (s *Service) sessionExpiry(now time.Time) time.Time {
+	if s.sessionMaxAge <= 0 {
+		return time.Time{}
+	}
+	return now.Add(s.sessionMaxAge).Truncate(time.Second)
+}
+
+// session returns the ID of the session token belongs to.
+func (c TokenClaims) session(token string) string {
+	if c.SessionID == "" {
+		return token
+	}
+	return c.SessionID
+}
+
+func (c TokenClaims) sessionEnded(now time.Time) bool {
+	return !c.SessionExpiresAt.IsZero() && !now.Before(c.SessionExpiresAt)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
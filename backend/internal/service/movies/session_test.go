@@ -0,0 +1,99 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+)
+
+func TestPlaybackSessionRefresh(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	repo := repository.NewMovieRepository(nil)
+	movie := domain.Movie{
+		ID:                "movie-session",
+		Slug:              "session-movie",
+		Title:             "Session Movie",
+		IsVisible:         true,
+		AvailabilityStart: now.Add(-24 * time.Hour),
+		AvailabilityEnd:   now.Add(24 * time.Hour),
+		StreamURL:         "https://cdn.example.com/master.m3u8",
+	}
+	repo.UpsertSampleMovie(movie)
+
+	keyring, _ := ParseTokenKeyring("k1:0123456789abcdef")
+	hmacSigner := NewHMACTokenSigner(keyring)
+	hmacSigner.now = clock
+	memorySigner := NewInMemoryTokenSigner()
+	memorySigner.now = clock
+
+	for name, signer := range map[string]TokenSigner{"hmac": hmacSigner, "memory": memorySigner} {
+		now = time.Now()
+		revocations := NewInMemoryRevocationList()
+		revocations.now = clock
+		svc := NewService(repo, signer, time.Minute, WithSessionMaxAge(150*time.Second), WithRevocations(revocations, nil))
+		svc.now = clock
+		ctx := context.Background()
+
+		issued, err := svc.CreatePlaybackToken(ctx, movie, Viewer{ID: "viewer-1"})
+		if err != nil {
+			t.Fatalf("%s: create token: %v", name, err)
+		}
+		if !issued.ExpiresAt.Equal(now.Add(time.Minute)) || !issued.SessionExpiresAt.Equal(now.Add(150*time.Second).Truncate(time.Second)) {
+			t.Fatalf("%s: unexpected expiry %+v", name, issued)
+		}
+		first, _ := svc.ResolveStream(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"})
+		ref := svc.SignReference(first, "segment", "https://cdn.example.com/seg0.ts")
+
+		// A heartbeat slides the expiry; the new token keeps the session.
+		now = now.Add(20 * time.Second)
+		refreshed, err := svc.RefreshPlaybackToken(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"})
+		if err != nil || refreshed.Token == issued.Token || !refreshed.ExpiresAt.Equal(now.Add(time.Minute)) {
+			t.Fatalf("%s: unexpected refresh %+v (%v)", name, refreshed, err)
+		}
+		// The old token keeps working while the player switches over, and
+		// is refused afterwards even before it expires.
+		now = now.Add(supersededTokenOverlap - time.Second)
+		if _, err := svc.ResolveStream(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"}); err != nil {
+			t.Fatalf("%s: expected the old token to work during the overlap, got %v", name, err)
+		}
+		now = now.Add(time.Second)
+		if _, err := svc.ResolveStream(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrTokenSuperseded) {
+			t.Fatalf("%s: expected the old token to be superseded, got %v", name, err)
+		}
+		if _, err := svc.RefreshPlaybackToken(ctx, movie.Slug, issued.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrTokenSuperseded) {
+			t.Fatalf("%s: expected the old token not to be refreshed again, got %v", name, err)
+		}
+		access, err := svc.ResolveStream(ctx, movie.Slug, refreshed.Token, Viewer{ID: "viewer-1"})
+		if err != nil {
+			t.Fatalf("%s: resolve refreshed token: %v", name, err)
+		}
+		if _, err := svc.OpenReference(access, "segment", ref); err != nil {
+			t.Fatalf("%s: expected references to survive the refresh, got %v", name, err)
+		}
+
+		if _, err := svc.RefreshPlaybackToken(ctx, movie.Slug, refreshed.Token, Viewer{ID: "viewer-2"}); !errors.Is(err, ErrTokenBindingMismatch) {
+			t.Fatalf("%s: expected another viewer to be refused, got %v", name, err)
+		}
+
+		// Expired tokens can still be refreshed within the grace, but not
+		// past the end of the session.
+		now = now.Add(40 * time.Second)
+		last, err := svc.RefreshPlaybackToken(ctx, movie.Slug, refreshed.Token, Viewer{ID: "viewer-1"})
+		if err != nil || !last.ExpiresAt.Equal(issued.SessionExpiresAt) {
+			t.Fatalf("%s: expected a refresh capped at the session end, got %+v (%v)", name, last, err)
+		}
+		now = issued.SessionExpiresAt
+		if _, err := svc.ResolveStream(ctx, movie.Slug, last.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrSessionExpired) {
+			t.Fatalf("%s: expected the session to expire, got %v", name, err)
+		}
+		if _, err := svc.RefreshPlaybackToken(ctx, movie.Slug, last.Token, Viewer{ID: "viewer-1"}); !errors.Is(err, ErrSessionExpired) {
+			t.Fatalf("%s: expected no refresh after the session end, got %v", name, err)
+		}
+	}
+}
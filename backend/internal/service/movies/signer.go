@@ -1,11 +1,13 @@
 package movies
 
 import (
+	"container/heap"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"fmt"
+	"encoding/json"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -19,7 +21,7 @@ func NewRedisTokenSigner(client *redis.Client) *RedisTokenSigner {
 	return &RedisTokenSigner{client: client}
 }
 
-func (s *RedisTokenSigner) SignToken(movieID, viewerID string, ttl time.Duration) (string, error) {
+func (s *RedisTokenSigner) SignToken(claims TokenClaims, ttl time.Duration) (string, error) {
 	if s == nil || s.client == nil {
 		return generateRandomToken(), nil
 	}
@@ -28,21 +30,28 @@ func (s *RedisTokenSigner) SignToken(movieID, viewerID string, ttl time.Duration
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	value := movieID
-	if viewerID != "" {
-		value = fmt.Sprintf("%s|%s", movieID, viewerID)
+	// The key outlives the token by the refresh grace, so that the token
+	// is reported as expired rather than unknown until then.
+	keyTTL := ttl
+	if ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(ttl)
+		keyTTL += tokenRefreshGrace
+	}
+	value, err := json.Marshal(tokenRecord(claims))
+	if err != nil {
+		return "", err
 	}
 
-	if err := s.client.Set(ctx, redisPlaybackKey(token), value, ttl).Err(); err != nil {
+	if err := s.client.Set(ctx, redisPlaybackKey(token), value, keyTTL).Err(); err != nil {
 		return "", err
 	}
 
 	return token, nil
 }
 
-func (s *RedisTokenSigner) ValidateToken(token, movieID string) (bool, error) {
+func (s *RedisTokenSigner) ValidateToken(token, movieID string) (TokenClaims, bool, error) {
 	if s == nil || s.client == nil {
-		return true, nil
+		return TokenClaims{MovieID: movieID}, true, nil
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -50,35 +59,204 @@ func (s *RedisTokenSigner) ValidateToken(token, movieID string) (bool, error) {
 	value, err := s.client.Get(ctx, redisPlaybackKey(token)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return false, nil
+			return TokenClaims{}, false, nil
 		}
-		return false, err
+		return TokenClaims{}, false, err
 	}
 
-	parts := strings.Split(value, "|")
-	return parts[0] == movieID, nil
+	claims := parseTokenRecord(value)
+	if claims.MovieID != movieID {
+		return TokenClaims{}, false, nil
+	}
+	if !claims.ExpiresAt.IsZero() && !time.Now().Before(claims.ExpiresAt) {
+		return claims, false, ErrTokenExpired
+	}
+	return claims, true, nil
+}
+
+// tokenRecord is how token claims are stored in Redis.
+type tokenRecord struct {
+	MovieID  string `json:"movieId"`
+	ViewerID string `json:"viewerId,omitempty"`
+	Tier     string `json:"tier,omitempty"`
+	// IPPrefix and UserAgentHash are the viewer binding.
+	IPPrefix         string    `json:"ipPrefix,omitempty"`
+	UserAgentHash    string    `json:"uaHash,omitempty"`
+	SessionID        string    `json:"sessionId,omitempty"`
+	SessionStartedAt time.Time `json:"sessionStartedAt,omitzero"`
+	SessionExpiresAt time.Time `json:"sessionExpiresAt,omitzero"`
+	IssuedAt         time.Time `json:"issuedAt,omitzero"`
+	// ExpiresAt is missing from records stored before tokens could be
+	// refreshed; those are only limited by the key TTL.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
 }
 
+// parseTokenRecord also reads the "movieID|viewerID" values stored before
+// tokens carried a tier.
+func parseTokenRecord(value string) TokenClaims {
+	var record tokenRecord
+	if strings.HasPrefix(value, "{") && json.Unmarshal([]byte(value), &record) == nil {
+		return TokenClaims(record)
+	}
+	movieID, viewerID, _ := strings.Cut(value, "|")
+	return TokenClaims{MovieID: movieID, ViewerID: viewerID}
+}
+
+// maxInMemoryTokens caps the tokens an InMemoryTokenSigner holds; beyond it
+// the tokens closest to expiry are dropped first.
+const maxInMemoryTokens = 100_000
+
+// InMemoryTokenSigner keeps tokens in process memory, for development, tests
+// and running without Redis. Tokens expire after their TTL and are kept for
+// the refresh grace like Redis keys are; RunJanitor removes them in the
+// background.
 type InMemoryTokenSigner struct {
-	store map[string]string
+	mu    sync.Mutex
+	store map[string]memoryToken
+	// expiries orders the tokens with a TTL by expiry for eviction. Entries
+	// can outlive the token they name and are skipped when popped.
+	expiries  tokenExpiries
+	maxTokens int
+	now       func() time.Time
+}
+
+type memoryToken struct {
+	claims TokenClaims
+	// expires is zero for tokens issued without a TTL.
+	expires time.Time
 }
 
 func NewInMemoryTokenSigner() *InMemoryTokenSigner {
-	return &InMemoryTokenSigner{store: make(map[string]string)}
+	return &InMemoryTokenSigner{
+		store:     make(map[string]memoryToken),
+		maxTokens: maxInMemoryTokens,
+		now:       time.Now,
+	}
 }
 
-func (s *InMemoryTokenSigner) SignToken(movieID, viewerID string, ttl time.Duration) (string, error) {
+func (s *InMemoryTokenSigner) SignToken(claims TokenClaims, ttl time.Duration) (string, error) {
 	token := generateRandomToken()
-	s.store[token] = movieID
+	entry := memoryToken{claims: claims}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+		entry.claims.ExpiresAt = entry.expires
+	}
+	if len(s.store) >= s.maxTokens {
+		s.evictLocked()
+	}
+	if len(s.expiries) >= 2*s.maxTokens {
+		s.purgeLocked(now)
+	}
+	s.store[token] = entry
+	if !entry.expires.IsZero() {
+		heap.Push(&s.expiries, tokenExpiry{token: token, expires: entry.expires})
+	}
 	return token, nil
 }
 
-func (s *InMemoryTokenSigner) ValidateToken(token, movieID string) (bool, error) {
-	stored, ok := s.store[token]
-	if !ok {
-		return false, nil
+func (s *InMemoryTokenSigner) ValidateToken(token, movieID string) (TokenClaims, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.store[token]
+	if !ok || entry.claims.MovieID != movieID {
+		return TokenClaims{}, false, nil
+	}
+	now := s.now()
+	if entry.stale(now) {
+		delete(s.store, token)
+		return TokenClaims{}, false, nil
 	}
-	return stored == movieID, nil
+	if entry.expired(now) {
+		return entry.claims, false, ErrTokenExpired
+	}
+	return entry.claims, true, nil
+}
+
+// RunJanitor removes stale tokens every interval until ctx is done.
+func (s *InMemoryTokenSigner) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.purgeExpired()
+		}
+	}
+}
+
+func (s *InMemoryTokenSigner) purgeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeLocked(s.now())
+}
+
+// purgeLocked removes stale tokens and rebuilds the expiry heap without the
+// entries of removed tokens.
+func (s *InMemoryTokenSigner) purgeLocked(now time.Time) {
+	s.expiries = s.expiries[:0]
+	for token, entry := range s.store {
+		switch {
+		case entry.stale(now):
+			delete(s.store, token)
+		case !entry.expires.IsZero():
+			s.expiries = append(s.expiries, tokenExpiry{token: token, expires: entry.expires})
+		}
+	}
+	heap.Init(&s.expiries)
+}
+
+// evictLocked drops the token closest to expiry, stale tokens being the
+// first. Tokens without a TTL are only dropped once none with a TTL is left.
+func (s *InMemoryTokenSigner) evictLocked() {
+	for s.expiries.Len() > 0 {
+		next := heap.Pop(&s.expiries).(tokenExpiry)
+		if entry, ok := s.store[next.token]; ok && entry.expires.Equal(next.expires) {
+			delete(s.store, next.token)
+			return
+		}
+	}
+	for token := range s.store {
+		delete(s.store, token)
+		return
+	}
+}
+
+type tokenExpiry struct {
+	token   string
+	expires time.Time
+}
+
+// tokenExpiries is a min-heap of token expiries for container/heap.
+type tokenExpiries []tokenExpiry
+
+func (h tokenExpiries) Len() int           { return len(h) }
+func (h tokenExpiries) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h tokenExpiries) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *tokenExpiries) Push(x any) { *h = append(*h, x.(tokenExpiry)) }
+
+func (h *tokenExpiries) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func (t memoryToken) expired(now time.Time) bool {
+	return !t.expires.IsZero() && !now.Before(t.expires)
+}
+
+// stale reports whether the token expired longer than the refresh grace ago.
+func (t memoryToken) stale(now time.Time) bool {
+	return !t.expires.IsZero() && !now.Before(t.expires.Add(tokenRefreshGrace))
 }
 
 func redisPlaybackKey(token string) string {
@@ -0,0 +1,97 @@
+package movies
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInMemoryTokenSignerExpiry(t *testing.T) {
+	signer := NewInMemoryTokenSigner()
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+
+	token, _ := signer.SignToken(TokenClaims{MovieID: "movie-1", ViewerID: "viewer-1", Tier: "free"}, time.Minute)
+	claims, ok, err := signer.ValidateToken(token, "movie-1")
+	if err != nil || !ok || claims.ViewerID != "viewer-1" || claims.Tier != "free" {
+		t.Fatalf("expected token to validate with its claims, got %+v %v %v", claims, ok, err)
+	}
+
+	stale, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Second)
+	now = now.Add(2 * time.Second)
+	signer.purgeExpired()
+	if _, ok, err := signer.ValidateToken(stale, "movie-1"); ok || !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected the token to be reported as expired during the refresh grace, got %v %v", ok, err)
+	}
+
+	now = now.Add(tokenRefreshGrace)
+	signer.purgeExpired()
+	if _, ok := signer.store[stale]; ok {
+		t.Fatalf("expected the janitor to remove the token after the refresh grace")
+	}
+	if _, ok, _ := signer.ValidateToken(token, "movie-1"); ok {
+		t.Fatalf("expected token to expire after its TTL")
+	}
+}
+
+func TestInMemoryTokenSignerCap(t *testing.T) {
+	signer := NewInMemoryTokenSigner()
+	signer.maxTokens = 3
+
+	first, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Minute)
+	for i := 0; i < 3; i++ {
+		signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Hour)
+	}
+	if len(signer.store) != 3 {
+		t.Fatalf("expected the store to be capped at 3 tokens, got %d", len(signer.store))
+	}
+	if _, ok, _ := signer.ValidateToken(first, "movie-1"); ok {
+		t.Fatalf("expected the token closest to expiry to be evicted")
+	}
+}
+
+func TestInMemoryTokenSignerEvictionSkipsRemovedTokens(t *testing.T) {
+	signer := NewInMemoryTokenSigner()
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+	signer.maxTokens = 2
+
+	removed, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Second)
+	soonest, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, 10*time.Minute)
+	now = now.Add(time.Second + tokenRefreshGrace)
+	if _, ok, _ := signer.ValidateToken(removed, "movie-1"); ok {
+		t.Fatalf("expected the stale token to be removed")
+	}
+
+	kept, _ := signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Hour)
+	signer.SignToken(TokenClaims{MovieID: "movie-1"}, time.Hour)
+	if _, ok, _ := signer.ValidateToken(soonest, "movie-1"); ok {
+		t.Fatalf("expected the token closest to expiry to be evicted")
+	}
+	if _, ok, _ := signer.ValidateToken(kept, "movie-1"); !ok {
+		t.Fatalf("expected the later token to be kept")
+	}
+}
+
+func TestInMemoryTokenSignerConcurrentUse(t *testing.T) {
+	signer := NewInMemoryTokenSigner()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			movieID := fmt.Sprintf("movie-%d", i)
+			for j := 0; j < 100; j++ {
+				token, _ := signer.SignToken(TokenClaims{MovieID: movieID}, time.Minute)
+				if _, ok, _ := signer.ValidateToken(token, movieID); !ok {
+					t.Errorf("expected token to validate")
+				}
+				signer.purgeExpired()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
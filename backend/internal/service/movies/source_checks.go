@@ -0,0 +1,175 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	domain "github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+// sourceCheckTimeout bounds the check of a single stream source.
+const sourceCheckTimeout = 30 * time.Second
+
+// sampleSegmentBytes is how much of the sample segment a check downloads.
+const sampleSegmentBytes = 64 << 10
+
+var (
+	errNoVariants = errors.New("master playlist has no variants")
+	errNoSegments = errors.New("media playlist has no segments")
+)
+
+// WithStreamChecks stores the results of stream source health checks in repo.
+func WithStreamChecks(repo *repository.StreamCheckRepository) Option {
+	return func(s *Service) {
+		s.checks = repo
+	}
+}
+
+// CheckStreamSources checks the stream sources of every movie, hidden and
+// scheduled ones included, and records the outcome. A failing source is also
+// reported to the failover health tracker so new sessions start on a working
+// mirror.
+func (s *Service) CheckStreamSources(ctx context.Context) ([]repository.StreamCheck, error) {
+	slugs, err := s.repo.ListStreamSlugs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]repository.StreamCheck, 0, len(slugs))
+	for _, slug := range slugs {
+		movieChecks, err := s.CheckMovieSources(ctx, slug)
+		checks = append(checks, movieChecks...)
+		if ctx.Err() != nil {
+			return checks, ctx.Err()
+		}
+		if err != nil && !errors.Is(err, ErrMovieNotFound) {
+			return checks, err
+		}
+	}
+	return checks, nil
+}
+
+// CheckMovieSources fetches the playlist and a sample segment of every stream
+// source of a movie and records the outcome.
+func (s *Service) CheckMovieSources(ctx context.Context, slug string) ([]repository.StreamCheck, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	access, accessErr := s.streamAccess(movie)
+
+	checks := make([]repository.StreamCheck, 0, len(movie.StreamSources()))
+	for priority, source := range movie.StreamSources() {
+		check := repository.StreamCheck{
+			MovieID:   movie.ID,
+			Slug:      movie.Slug,
+			Title:     movie.Title,
+			StreamURL: source,
+			Priority:  priority,
+		}
+		if accessErr != nil {
+			check.Status, check.Reason, check.CheckedAt = domain.ValidationInvalid, accessErr.Error(), s.now()
+		} else {
+			s.checkSource(ctx, access, &check)
+		}
+		if ctx.Err() != nil {
+			return checks, ctx.Err()
+		}
+		if err := s.checks.RecordCheck(ctx, check); err != nil {
+			return checks, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// StreamChecks returns the latest recorded check of every stream source,
+// optionally only those with the given status.
+func (s *Service) StreamChecks(ctx context.Context, status domain.ValidationStatus) ([]repository.StreamCheck, error) {
+	return s.checks.ListChecks(ctx, status)
+}
+
+func (s *Service) checkSource(ctx context.Context, access StreamAccess, check *repository.StreamCheck) {
+	ctx, cancel := context.WithTimeout(ctx, sourceCheckTimeout)
+	defer cancel()
+
+	// Only the source under test may answer, so no failover to the mirrors.
+	access.URL, access.Sources = check.StreamURL, []string{check.StreamURL}
+
+	started := time.Now()
+	err := s.probeSource(ctx, access)
+	check.Latency = time.Since(started)
+	check.CheckedAt = s.now()
+	if err != nil {
+		check.Status, check.Reason = domain.ValidationInvalid, err.Error()
+		return
+	}
+	check.Status = domain.ValidationValid
+}
+
+func (s *Service) probeSource(ctx context.Context, access StreamAccess) error {
+	if access.IsDASH() {
+		_, err := s.FetchDASHManifest(ctx, access, access.URL)
+		return err
+	}
+
+	target := access.URL
+	playlistCtx := upstream.WithAllowedHosts(ctx, access.AllowedHosts)
+	playlist, err := s.fetchPlaylist(playlistCtx, access, target, false)
+	if err != nil {
+		return err
+	}
+	if playlist.Master {
+		if target, err = firstPlaylistURI(target, playlist, errNoVariants); err != nil {
+			return err
+		}
+		if playlist, err = s.fetchPlaylist(playlistCtx, access, target, false); err != nil {
+			return err
+		}
+	}
+	segment, err := firstPlaylistURI(target, playlist, errNoSegments)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.FetchUpstream(ctx, access, http.MethodGet, segment, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return UpstreamStatusError{StatusCode: resp.StatusCode}
+	}
+	_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, sampleSegmentBytes))
+	return err
+}
+
+// firstPlaylistURI returns the first URI line of playlist resolved against
+// base, or missing when there is none.
+func firstPlaylistURI(base string, playlist hls.Playlist, missing error) (string, error) {
+	for _, line := range playlist.Lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(line)
+		if err != nil {
+			return "", err
+		}
+		return baseURL.ResolveReference(ref).String(), nil
+	}
+	return "", missing
+}
@@ -0,0 +1,199 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+const (
+	// sourceFailureThreshold consecutive failures mark a source unhealthy.
+	sourceFailureThreshold = 3
+	// sourceRetryAfter is how long an unhealthy source is passed over before
+	// viewers are sent to it again.
+	sourceRetryAfter = 30 * time.Second
+)
+
+// sourceHealth tracks recent upstream failures per stream source so that
+// ResolveStream can steer new sessions away from a failing CDN.
+type sourceHealth struct {
+	mu      sync.Mutex
+	sources map[string]*sourceState
+}
+
+type sourceState struct {
+	failures    int
+	lastFailure time.Time
+}
+
+func newSourceHealth() *sourceHealth {
+	return &sourceHealth{sources: make(map[string]*sourceState)}
+}
+
+func (h *sourceHealth) failure(source string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.sources[source]
+	if !ok {
+		state = &sourceState{}
+		h.sources[source] = state
+	}
+	state.failures++
+	state.lastFailure = now
+}
+
+func (h *sourceHealth) success(source string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sources, source)
+}
+
+func (h *sourceHealth) healthy(source string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.sources[source]
+	if !ok || state.failures < sourceFailureThreshold {
+		return true
+	}
+	return now.Sub(state.lastFailure) >= sourceRetryAfter
+}
+
+// orderSources returns sources with the healthy ones first, keeping the
+// priority order within each group.
+func (s *Service) orderSources(sources []string) []string {
+	now := s.now()
+	ordered := append([]string(nil), sources...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return s.health.healthy(ordered[i], now) && !s.health.healthy(ordered[j], now)
+	})
+	return ordered
+}
+
+// sourceOf returns the source whose directory contains target.
+func (a StreamAccess) sourceOf(target string) (string, bool) {
+	for _, source := range a.Sources {
+		if target == source || strings.HasPrefix(target, sourceDir(source)) {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// candidates returns target followed by the same resource on every other
+// source of the stream.
+func (a StreamAccess) candidates(target string) []candidate {
+	source, ok := a.sourceOf(target)
+	if !ok {
+		return []candidate{{target: target}}
+	}
+	candidates := []candidate{{source: source, target: target}}
+	for _, other := range a.Sources {
+		if other == source {
+			continue
+		}
+		if mirrored, err := mirrorURL(source, other, target); err == nil {
+			candidates = append(candidates, candidate{source: other, target: mirrored.String()})
+		}
+	}
+	return candidates
+}
+
+// candidates returns the sources to try for target: unhealthy sources are
+// only tried after the healthy ones.
+func (s *Service) candidates(access StreamAccess, target string) []candidate {
+	now := s.now()
+	candidates := access.candidates(target)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return s.health.healthy(candidates[i].source, now) && !s.health.healthy(candidates[j].source, now)
+	})
+	return candidates
+}
+
+type candidate struct {
+	source string
+	target string
+}
+
+// version identifies the configured source list. Cached playlists are
+// dropped when it changes.
+func (a StreamAccess) version() string {
+	return a.sourcesVersion
+}
+
+// failoverStatus reports upstream statuses worth retrying on another source:
+// server errors and content missing from a lagging mirror.
+func failoverStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusNotFound
+}
+
+// fetchWithFailover requests target and, when that source fails, the same
+// resource on the other sources of the stream. The response of the last
+// attempt is returned when every source fails with an HTTP status.
+func (s *Service) fetchWithFailover(ctx context.Context, access StreamAccess, target string, fetch func(ctx context.Context, target string) (*http.Response, error)) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+	for _, candidate := range s.candidates(access, target) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		resp, err = fetch(ctx, candidate.target)
+		if err == nil && !failoverStatus(resp.StatusCode) {
+			s.health.success(candidate.source)
+			return resp, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, upstream.ErrForbiddenDestination) {
+			break
+		}
+		s.health.failure(candidate.source, s.now())
+	}
+	return resp, err
+}
+
+// mirrorURL maps target, a URL under the directory of source, to the same
+// place under the directory of mirror.
+func mirrorURL(source, mirror, target string) (*url.URL, error) {
+	mirrored, err := url.Parse(mirror)
+	if err != nil {
+		return nil, err
+	}
+	if target == source {
+		return mirrored, nil
+	}
+
+	base, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	baseDir := base.Path[:strings.LastIndex(base.Path, "/")+1]
+	if targetURL.Scheme != base.Scheme || targetURL.Host != base.Host || !strings.HasPrefix(targetURL.Path, baseDir) {
+		return nil, errOutsideSource
+	}
+
+	rel := &url.URL{Path: strings.TrimPrefix(targetURL.Path, baseDir), RawQuery: targetURL.RawQuery}
+	return mirrored.ResolveReference(rel), nil
+}
+
+var errOutsideSource = errors.New("url is outside the stream source directory")
+
+func sourceDir(source string) string {
+	if query := strings.IndexAny(source, "?#"); query >= 0 {
+		source = source[:query]
+	}
+	return source[:strings.LastIndex(source, "/")+1]
+}
@@ -0,0 +1,109 @@
+package movies
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/watermark"
+)
+
+const maxWatermarkMatches = 5
+
+// WithWatermark sets the marker deriving per-session A/B patterns and the
+// repository recording the sessions of watermarked movies.
+func WithWatermark(marker *watermark.Marker, sessions *repository.WatermarkRepository) Option {
+	return func(s *Service) {
+		s.marker = marker
+		s.watermarks = sessions
+	}
+}
+
+// Watermarked reports whether the stream has a B variant to mix in.
+func (a StreamAccess) Watermarked() bool {
+	return a.WatermarkURL != ""
+}
+
+// WatermarkMatch is a recorded session whose pattern fits a leaked A/B
+// sequence.
+type WatermarkMatch struct {
+	SessionID  string
+	ViewerID   string
+	IssuedAt   time.Time
+	Matched    int
+	Mismatched int
+	Coverage   int
+}
+
+// recordWatermarkSession remembers the pattern of a new playback session so
+// that a leak can later be traced back to it.
+func (s *Service) recordWatermarkSession(ctx context.Context, movie movies.Movie, sessionID, viewerID string) error {
+	if movie.WatermarkURL == "" {
+		return nil
+	}
+	return s.watermarks.RecordSession(ctx, repository.WatermarkSession{
+		MovieID:   movie.ID,
+		SessionID: sessionID,
+		ViewerID:  viewerID,
+		Pattern:   s.marker.Pattern(movie.ID, sessionID),
+		IssuedAt:  s.now().UTC(),
+	})
+}
+
+// WatermarkVariant fetches the B variant of the playlist at target, which
+// must lie under the directory of a stream source; the B stream mirrors that
+// layout. The second result is the URL the B playlist was fetched from.
+func (s *Service) WatermarkVariant(ctx context.Context, access StreamAccess, target string, reload url.Values) (hls.Playlist, *url.URL, error) {
+	source, ok := access.sourceOf(target)
+	if !ok {
+		return hls.Playlist{}, nil, errOutsideSource
+	}
+	mirrored, err := mirrorURL(source, access.WatermarkURL, target)
+	if err != nil {
+		return hls.Playlist{}, nil, err
+	}
+	playlist, err := s.FetchPlaylist(ctx, access, mirrored.String(), reload)
+	if err != nil {
+		return hls.Playlist{}, nil, err
+	}
+	return playlist, mirrored, nil
+}
+
+// DecodeWatermark ranks the recorded sessions of a movie by how well their
+// patterns explain the A/B variants observed in a leaked copy.
+func (s *Service) DecodeWatermark(ctx context.Context, slug string, observations []watermark.Observation) ([]WatermarkMatch, error) {
+	movie, err := s.repo.GetMovieWithStreams(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.watermarks.ListSessions(ctx, movie.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]uint64, len(sessions))
+	for i, session := range sessions {
+		patterns[i] = session.Pattern
+	}
+
+	scores := watermark.Rank(observations, patterns)
+	if len(scores) > maxWatermarkMatches {
+		scores = scores[:maxWatermarkMatches]
+	}
+	matches := make([]WatermarkMatch, 0, len(scores))
+	for _, score := range scores {
+		session := sessions[score.Index]
+		matches = append(matches, WatermarkMatch{
+			SessionID:  session.SessionID,
+			ViewerID:   session.ViewerID,
+			IssuedAt:   session.IssuedAt,
+			Matched:    score.Matched,
+			Mismatched: score.Mismatched,
+			Coverage:   score.Coverage,
+		})
+	}
+	return matches, nil
+}
@@ -0,0 +1,287 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrCircuitOpen  = errors.New("upstream circuit open")
+	ErrInvalidURL   = errors.New("invalid upstream url")
+	ErrUpstreamFail = errors.New("upstream request failed")
+)
+
+type Config struct {
+	ConnectTimeout time.Duration
+	TTFBTimeout    time.Duration
+	// TotalTimeout bounds each attempt until the response headers arrive,
+	// redirects included. Bodies are streamed for as long as the caller's
+	// context allows, so long segment transfers are not cut off.
+	TotalTimeout        time.Duration
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	MaxRetries          int
+	RetryBaseDelay      time.Duration
+	RetryMaxDelay       time.Duration
+	BreakerThreshold    int
+	BreakerCooldown     time.Duration
+	BlockingTimeout     time.Duration
+	// AllowedNetworks are internal ranges upstream fetches may still reach,
+	// for origins deployed next to the API.
+	AllowedNetworks []netip.Prefix
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ConnectTimeout:      3 * time.Second,
+		TTFBTimeout:         5 * time.Second,
+		TotalTimeout:        30 * time.Second,
+		MaxConnsPerHost:     64,
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+		MaxRetries:          2,
+		RetryBaseDelay:      100 * time.Millisecond,
+		RetryMaxDelay:       time.Second,
+		BreakerThreshold:    5,
+		BreakerCooldown:     30 * time.Second,
+		BlockingTimeout:     20 * time.Second,
+	}
+}
+
+// Fetcher is the shared HTTP client for requests to stream origins. Each
+// host gets its own pooled connections and circuit breaker, and idempotent
+// requests are retried on transport errors and transient 5xx responses.
+type Fetcher struct {
+	cfg      Config
+	client   *http.Client
+	blocking *http.Client
+	breakers *breakerSet
+	sleep    func(context.Context, time.Duration) error
+}
+
+func New(cfg Config) *Fetcher {
+	defaults := DefaultConfig()
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if cfg.TTFBTimeout <= 0 {
+		cfg.TTFBTimeout = defaults.TTFBTimeout
+	}
+	if cfg.TotalTimeout <= 0 {
+		cfg.TotalTimeout = defaults.TotalTimeout
+	}
+	if cfg.MaxConnsPerHost <= 0 {
+		cfg.MaxConnsPerHost = defaults.MaxConnsPerHost
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = defaults.RetryBaseDelay
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		cfg.RetryMaxDelay = cfg.RetryBaseDelay
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = defaults.BreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaults.BreakerCooldown
+	}
+	if cfg.BlockingTimeout <= 0 {
+		cfg.BlockingTimeout = defaults.BlockingTimeout
+	}
+
+	dialer := newGuardedDialer(&net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}, cfg.AllowedNetworks)
+	// No proxy: the guarded dialer has to see the origin address, not the
+	// proxy's.
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.TTFBTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost * 8,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+	}
+
+	// LL-HLS blocking playlist reloads are held by the origin until the
+	// requested part exists, so they get their own pool with a header
+	// timeout long enough for that.
+	blockingTransport := transport.Clone()
+	blockingTransport.ResponseHeaderTimeout = cfg.BlockingTimeout
+
+	return &Fetcher{
+		cfg: cfg,
+		client: &http.Client{
+			Transport:     transport,
+			CheckRedirect: checkRedirect,
+		},
+		blocking: &http.Client{
+			Transport:     blockingTransport,
+			CheckRedirect: checkRedirect,
+		},
+		breakers: newBreakerSet(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		sleep:    sleepContext,
+	}
+}
+
+// Fetch issues method against target. The caller owns the returned body.
+// Responses with status >= 400 are returned as-is once retries are exhausted
+// so callers can decide how to surface them. Redirects are only followed to
+// the hosts set with WithAllowedHosts, and never to internal addresses.
+func (f *Fetcher) Fetch(ctx context.Context, method, target string, header http.Header) (*http.Response, error) {
+	return f.do(ctx, f.client, f.cfg.TotalTimeout, method, target, header)
+}
+
+// FetchBlocking is Fetch for requests the origin may hold open, such as
+// playlist reloads carrying _HLS_msn.
+func (f *Fetcher) FetchBlocking(ctx context.Context, target string, header http.Header) (*http.Response, error) {
+	return f.do(ctx, f.blocking, f.cfg.BlockingTimeout+f.cfg.TotalTimeout, http.MethodGet, target, header)
+}
+
+// do runs the attempts of a request. Each attempt has to produce response
+// headers within headerTimeout; once it has, the body is only bound to ctx.
+func (f *Fetcher) do(ctx context.Context, client *http.Client, headerTimeout time.Duration, method, target string, header http.Header) (*http.Response, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	breaker := f.breakers.get(parsed.Host)
+	attempts := 1
+	if isIdempotent(method) {
+		attempts += f.cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := f.sleep(ctx, f.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		if !breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		attemptCtx, cancel := context.WithCancel(ctx)
+		req, err := http.NewRequestWithContext(attemptCtx, method, parsed.String(), nil)
+		if err != nil {
+			cancel()
+			return nil, ErrInvalidURL
+		}
+		for key, values := range header {
+			req.Header[key] = append([]string(nil), values...)
+		}
+
+		timer := time.AfterFunc(headerTimeout, cancel)
+		resp, err := client.Do(req)
+		if !timer.Stop() && err == nil {
+			// The deadline passed just as the headers arrived; the body
+			// would fail on the first read.
+			resp.Body.Close()
+			err = context.DeadlineExceeded
+		}
+		if err != nil {
+			cancel()
+			if ctx.Err() != nil {
+				breaker.release()
+				return nil, ctx.Err()
+			}
+			if errors.Is(err, ErrForbiddenDestination) {
+				breaker.release()
+				return nil, err
+			}
+			breaker.failure()
+			if attemptCtx.Err() != nil {
+				lastErr = fmt.Errorf("%w: no response within %s", ErrUpstreamFail, headerTimeout)
+			} else {
+				lastErr = fmt.Errorf("%w: %v", ErrUpstreamFail, err)
+			}
+			continue
+		}
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+
+		if !isRetryableStatus(resp.StatusCode) {
+			breaker.success()
+			return resp, nil
+		}
+
+		breaker.failure()
+		if attempt == attempts-1 {
+			return resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		lastErr = fmt.Errorf("%w: status %d", ErrUpstreamFail, resp.StatusCode)
+	}
+
+	return nil, lastErr
+}
+
+// cancelOnClose releases the context of an attempt with its response body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff returns a full-jitter delay for the given retry attempt.
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	ceiling := f.cfg.RetryBaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > f.cfg.RetryMaxDelay {
+		ceiling = f.cfg.RetryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling)) + 1)
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	fetcher := New(Config{MaxRetries: 2, RetryBaseDelay: time.Millisecond, BreakerThreshold: 10, AllowedNetworks: loopback})
+	resp, err := fetcher.Fetch(context.Background(), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after retries, got %d", resp.StatusCode)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", got)
+	}
+}
+
+func TestFetchDoesNotRetryNonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	fetcher := New(Config{MaxRetries: 3, RetryBaseDelay: time.Millisecond, BreakerThreshold: 10, AllowedNetworks: loopback})
+	resp, err := fetcher.Fetch(context.Background(), http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected a single call for POST, got %d", got)
+	}
+}
+
+func TestFetchTimeoutOnlyCoversHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			io.WriteString(w, "chunk")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	fetcher := New(Config{TotalTimeout: 100 * time.Millisecond, MaxRetries: 0, BreakerThreshold: 10, AllowedNetworks: loopback})
+	resp, err := fetcher.Fetch(context.Background(), http.MethodGet, server.URL+"/slow-body", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "chunkchunkchunk" {
+		t.Fatalf("expected the body to outlast the timeout, got %q (%v)", body, err)
+	}
+
+	if _, err := fetcher.Fetch(context.Background(), http.MethodGet, server.URL+"/slow-headers", nil); !errors.Is(err, ErrUpstreamFail) {
+		t.Fatalf("expected late headers to time out, got %v", err)
+	}
+}
+
+func TestFetchOpensCircuitPerHost(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	fetcher := New(Config{MaxRetries: 0, BreakerThreshold: 2, BreakerCooldown: time.Hour, AllowedNetworks: loopback})
+	for i := 0; i < 2; i++ {
+		resp, err := fetcher.Fetch(context.Background(), http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("attempt %d returned error: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := fetcher.Fetch(context.Background(), http.MethodGet, server.URL, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected open circuit to short-circuit upstream, got %d calls", got)
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := &breaker{threshold: 1, cooldown: time.Second, now: func() time.Time { return now }}
+
+	b.failure()
+	if b.allow() {
+		t.Fatalf("expected breaker to be open")
+	}
+
+	now = now.Add(2 * time.Second)
+	if !b.allow() {
+		t.Fatalf("expected a probe after cooldown")
+	}
+	if b.allow() {
+		t.Fatalf("expected only one concurrent probe")
+	}
+
+	b.success()
+	if !b.allow() {
+		t.Fatalf("expected breaker to close after a successful probe")
+	}
+}
@@ -0,0 +1,147 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ErrForbiddenDestination is returned when an upstream request would reach
+// an internal address or follow a redirect off the movie's allowed hosts.
+var ErrForbiddenDestination = errors.New("upstream destination not allowed")
+
+const maxRedirects = 5
+
+// reservedNetworks are blocked in addition to the loopback, private,
+// link-local, multicast and unspecified ranges netip already classifies.
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// guardedDialer resolves upstream host names itself and connects to the
+// first resolved address that is not internal, so the address that was
+// checked is the address that gets dialed.
+type guardedDialer struct {
+	dialer  *net.Dialer
+	allowed []netip.Prefix
+	lookup  func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+func newGuardedDialer(dialer *net.Dialer, allowed []netip.Prefix) *guardedDialer {
+	return &guardedDialer{
+		dialer:  dialer,
+		allowed: allowed,
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+	}
+}
+
+func (d *guardedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		addrs, err = d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastErr := fmt.Errorf("%w: %s resolves to a blocked address", ErrForbiddenDestination, host)
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if !d.permitted(addr) {
+			continue
+		}
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (d *guardedDialer) permitted(addr netip.Addr) bool {
+	for _, prefix := range d.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+type allowedHostsKey struct{}
+
+// WithAllowedHosts restricts the redirects followed by requests made with the
+// returned context to the given hosts, matched like HostAllowed.
+func WithAllowedHosts(ctx context.Context, hosts []string) context.Context {
+	return context.WithValue(ctx, allowedHostsKey{}, hosts)
+}
+
+// HostAllowed reports whether host matches an entry of allowed. Entries match
+// exactly or as a parent domain; a leading dot matches subdomains only.
+func HostAllowed(host string, allowed []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, entry := range allowed {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.EqualFold(host, entry) {
+			return true
+		}
+		if strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry) {
+			return true
+		}
+		if !strings.HasPrefix(entry, ".") && strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRedirect re-validates every redirect hop. Addresses are checked again
+// by the dialer when the hop is connected.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%w: too many redirects", ErrForbiddenDestination)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: redirect to %s scheme", ErrForbiddenDestination, req.URL.Scheme)
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: redirect downgrades to http", ErrForbiddenDestination)
+	}
+	if allowed, ok := req.Context().Value(allowedHostsKey{}).([]string); ok && !HostAllowed(req.URL.Hostname(), allowed) {
+		return fmt.Errorf("%w: redirect to %s", ErrForbiddenDestination, req.URL.Hostname())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var loopback = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}
+
+func TestFetchBlocksInternalAddressesByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "internal")
+	}))
+	defer server.Close()
+
+	fetcher := New(Config{})
+	for _, target := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := fetcher.Fetch(context.Background(), http.MethodGet, target, nil); !errors.Is(err, ErrForbiddenDestination) {
+			t.Fatalf("%s: expected ErrForbiddenDestination, got %v", target, err)
+		}
+	}
+}
+
+func TestGuardedDialerPinsResolvedAddress(t *testing.T) {
+	dialer := newGuardedDialer(nil, nil)
+	dialer.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("10.0.0.5"), netip.MustParseAddr("::ffff:169.254.169.254")}, nil
+	}
+	if _, err := dialer.DialContext(context.Background(), "tcp", "origin.example.com:443"); !errors.Is(err, ErrForbiddenDestination) {
+		t.Fatalf("expected ErrForbiddenDestination, got %v", err)
+	}
+
+	for _, raw := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fc00::1"} {
+		if dialer.permitted(netip.MustParseAddr(raw)) {
+			t.Fatalf("expected %s to be blocked", raw)
+		}
+	}
+	if !dialer.permitted(netip.MustParseAddr("93.184.216.34")) {
+		t.Fatal("expected public address to be permitted")
+	}
+}
+
+func TestFetchRevalidatesRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "segment")
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/segment.ts", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	targetURL, _ := url.Parse(target.URL)
+	fetcher := New(Config{AllowedNetworks: loopback})
+
+	ctx := WithAllowedHosts(context.Background(), []string{"origin.example.com"})
+	if _, err := fetcher.Fetch(ctx, http.MethodGet, origin.URL, nil); !errors.Is(err, ErrForbiddenDestination) {
+		t.Fatalf("expected redirect off the allowed hosts to fail, got %v", err)
+	}
+
+	ctx = WithAllowedHosts(context.Background(), []string{targetURL.Hostname()})
+	resp, err := fetcher.Fetch(ctx, http.MethodGet, origin.URL, nil)
+	if err != nil {
+		t.Fatalf("expected allowed redirect to be followed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "segment" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
@@ -0,0 +1,133 @@
+// Package watermark implements A/B forensic watermarking: every segment of a
+// watermarked stream exists in two visually marked variants, and each
+// playback session receives its own sequence of A and B segments. A leaked
+// copy reveals that sequence, which identifies the session.
+package watermark
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// PatternBits is the length of a session pattern. Segment n carries bit
+// n mod PatternBits, so any PatternBits consecutive segments reveal the
+// whole pattern.
+const PatternBits = 64
+
+type Variant byte
+
+const (
+	VariantA Variant = 'A'
+	VariantB Variant = 'B'
+)
+
+var ErrInvalidObservation = errors.New("invalid watermark observation")
+
+// Marker derives session patterns from playback tokens.
+type Marker struct {
+	secret []byte
+}
+
+// NewMarker returns a marker keyed with secret. An empty secret gets a random
+// one, which only suits development: patterns change on restart, so leaks
+// from earlier sessions can no longer be decoded.
+func NewMarker(secret []byte) *Marker {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &Marker{secret: secret}
+}
+
+// Pattern returns the A/B pattern of a playback session.
+func (m *Marker) Pattern(movieID, token string) uint64 {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(movieID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(token))
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// VariantFor returns the variant a session with pattern receives for the
+// segment with the given media sequence number.
+func VariantFor(pattern uint64, sequence int64) Variant {
+	bit := uint64(sequence) % PatternBits
+	if pattern>>bit&1 == 1 {
+		return VariantB
+	}
+	return VariantA
+}
+
+// Observation is the variant found in a leaked copy for one segment.
+type Observation struct {
+	Sequence int64
+	Variant  Variant
+}
+
+// ParseObservations reads a compact sequence such as "ABBA-B" starting at
+// media sequence start. '-' or '?' marks a segment whose variant could not
+// be determined.
+func ParseObservations(start int64, variants string) ([]Observation, error) {
+	observations := make([]Observation, 0, len(variants))
+	for i, c := range variants {
+		switch c {
+		case 'A', 'a':
+			observations = append(observations, Observation{Sequence: start + int64(i), Variant: VariantA})
+		case 'B', 'b':
+			observations = append(observations, Observation{Sequence: start + int64(i), Variant: VariantB})
+		case '-', '?':
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrInvalidObservation, c, i)
+		}
+	}
+	if len(observations) == 0 {
+		return nil, fmt.Errorf("%w: no known variants", ErrInvalidObservation)
+	}
+	return observations, nil
+}
+
+// Score is how well a candidate pattern explains a set of observations.
+type Score struct {
+	// Index is the position of the pattern in the candidates passed to Rank.
+	Index int
+	// Matched and Mismatched count the observed segments that agree and
+	// disagree with the pattern.
+	Matched    int
+	Mismatched int
+	// Coverage is the number of distinct pattern bits the observations
+	// touched. Below PatternBits several patterns may match equally well.
+	Coverage int
+}
+
+// Rank scores every candidate pattern against the observations and returns
+// the scores best first.
+func Rank(observations []Observation, candidates []uint64) []Score {
+	var touched uint64
+	for _, observation := range observations {
+		touched |= 1 << (uint64(observation.Sequence) % PatternBits)
+	}
+	coverage := bits.OnesCount64(touched)
+
+	scores := make([]Score, 0, len(candidates))
+	for i, pattern := range candidates {
+		score := Score{Index: i, Coverage: coverage}
+		for _, observation := range observations {
+			if VariantFor(pattern, observation.Sequence) == observation.Variant {
+				score.Matched++
+			} else {
+				score.Mismatched++
+			}
+		}
+		scores = append(scores, score)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Mismatched < scores[j].Mismatched
+	})
+	return scores
+}
@@ -0,0 +1,65 @@
+package watermark
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPatternIsStablePerSession(t *testing.T) {
+	marker := NewMarker([]byte("secret"))
+	first := marker.Pattern("movie-1", "token-1")
+	if first != marker.Pattern("movie-1", "token-1") {
+		t.Fatal("pattern must be deterministic")
+	}
+	if first == marker.Pattern("movie-1", "token-2") || first == marker.Pattern("movie-2", "token-1") {
+		t.Fatal("patterns of different sessions should differ")
+	}
+	if first == NewMarker([]byte("other")).Pattern("movie-1", "token-1") {
+		t.Fatal("pattern must depend on the secret")
+	}
+}
+
+func TestRankFindsLeakingSession(t *testing.T) {
+	marker := NewMarker([]byte("secret"))
+	candidates := []uint64{
+		marker.Pattern("movie", "alice"),
+		marker.Pattern("movie", "bob"),
+		marker.Pattern("movie", "carol"),
+	}
+
+	// Reconstruct what bob's copy looks like from segment 100 on, with a
+	// couple of segments that could not be classified.
+	var observed strings.Builder
+	for seq := int64(100); seq < 240; seq++ {
+		if seq%17 == 0 {
+			observed.WriteByte('-')
+			continue
+		}
+		observed.WriteByte(byte(VariantFor(candidates[1], seq)))
+	}
+	observations, err := ParseObservations(100, observed.String())
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	scores := Rank(observations, candidates)
+	if scores[0].Index != 1 || scores[0].Mismatched != 0 {
+		t.Fatalf("expected bob to match exactly, got %+v", scores)
+	}
+	if scores[0].Coverage != PatternBits {
+		t.Fatalf("expected full coverage, got %d", scores[0].Coverage)
+	}
+	if scores[1].Mismatched == 0 {
+		t.Fatalf("expected other sessions to mismatch, got %+v", scores)
+	}
+}
+
+func TestParseObservationsRejectsGarbage(t *testing.T) {
+	if _, err := ParseObservations(0, "ABX"); !errors.Is(err, ErrInvalidObservation) {
+		t.Fatalf("expected ErrInvalidObservation, got %v", err)
+	}
+	if _, err := ParseObservations(0, "--"); !errors.Is(err, ErrInvalidObservation) {
+		t.Fatalf("expected ErrInvalidObservation for unknown-only input, got %v", err)
+	}
+}
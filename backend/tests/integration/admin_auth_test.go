@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apimiddleware "github.com/leak-streaming/leak-streaming/backend/internal/api/middleware"
+	"github.com/leak-streaming/leak-streaming/backend/internal/api/router"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/config"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+const testAdminToken = "admin-token-0123456789"
+
+// newAdminServer serves the full router with a single admin, alice.
+func newAdminServer(t *testing.T, movieService *service.Service) *httptest.Server {
+	t.Helper()
+
+	adminTokens, err := apimiddleware.ParseAdminTokens("alice:" + testAdminToken)
+	if err != nil {
+		t.Fatalf("failed to parse admin tokens: %v", err)
+	}
+	cfg := config.Config{HTTP: config.HTTPConfig{WriteTimeout: 10 * time.Second}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(router.NewServer(cfg, log, nil, movieService, adminTokens).Handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// adminRequest sends a request to the admin API, as alice unless token is
+// given.
+func adminRequest(t *testing.T, method, target, body string, token ...string) *http.Response {
+	t.Helper()
+
+	var reader io.Reader = http.NoBody
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	bearer := testAdminToken
+	if len(token) > 0 {
+		bearer = token[0]
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, target, err)
+	}
+	return resp
+}
+
+func TestAdminRoutesRequireAdminToken(t *testing.T) {
+	t.Parallel()
+
+	movieService := service.NewService(repository.NewMovieRepository(nil), service.NewInMemoryTokenSigner(), time.Minute)
+	server := newAdminServer(t, movieService)
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "not-the-admin-token", http.StatusForbidden},
+		{"admin token", testAdminToken, http.StatusOK},
+	} {
+		resp := adminRequest(t, http.MethodGet, server.URL+"/admin/metrics/segment-cache", "", tc.token)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, resp.StatusCode)
+		}
+	}
+
+	assertStatus(t, server.URL+"/metrics/segment-cache", http.StatusNotFound)
+}
+
+func TestAdminRoutesRefusedWithoutAdmins(t *testing.T) {
+	t.Parallel()
+
+	movieService := service.NewService(repository.NewMovieRepository(nil), service.NewInMemoryTokenSigner(), time.Minute)
+	cfg := config.Config{HTTP: config.HTTPConfig{WriteTimeout: 10 * time.Second}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(router.NewServer(cfg, log, nil, movieService, nil).Handler)
+	defer server.Close()
+
+	resp := adminRequest(t, http.MethodGet, server.URL+"/admin/metrics/segment-cache", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without configured admins, got %d", resp.StatusCode)
+	}
+}
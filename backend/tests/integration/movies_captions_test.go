@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestCaptionProxyConvertsToWebVTT(t *testing.T) {
+	t.Parallel()
+
+	var srtFetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/subs/en.srt":
+			srtFetches.Add(1)
+			io.WriteString(w, "1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n")
+		case "/subs/th.dfxp":
+			io.WriteString(w, `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="1s" end="2.5s">สวัสดี</p></div></body></tt>`)
+		case "/subs/broken.srt":
+			io.WriteString(w, "1\n00:00:01,000 => 00:00:02,000\nHello\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-captions",
+		Slug:      "captions-movie",
+		Title:     "Captions Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+		Captions: []movies.Caption{
+			{LanguageCode: "en", Label: "English", CaptionURL: upstream.URL + "/subs/en.srt"},
+			{LanguageCode: "th", Label: "ไทย", CaptionURL: upstream.URL + "/subs/th.dfxp"},
+			{LanguageCode: "fr", Label: "Français", CaptionURL: upstream.URL + "/subs/broken.srt"},
+			{LanguageCode: "de", Label: "Deutsch", CaptionURL: "/captions/sample-de.vtt"},
+		},
+	}, service.WithCaptionCache(cache.NewCaptionCache(cache.CaptionCacheConfig{})))
+
+	base := server.URL + "/movies/captions-movie/captions/"
+	if body := getBody(t, base+"en?token="+token); body != "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n" {
+		t.Fatalf("unexpected SRT conversion:\n%s", body)
+	}
+	getBody(t, base+"en?token="+token)
+	if srtFetches.Load() != 1 {
+		t.Fatalf("expected the converted caption to be cached, got %d fetches", srtFetches.Load())
+	}
+	if body := getBody(t, base+"th?token="+token); body != "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nสวัสดี\n" {
+		t.Fatalf("unexpected TTML conversion:\n%s", body)
+	}
+
+	resp, err := http.Get(base + "fr?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to fetch caption: %v", err)
+	}
+	var payload struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&payload)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusBadGateway || payload.Code != "upstream_invalid_captions" {
+		t.Fatalf("expected 502 upstream_invalid_captions, got %d %+v (%v)", resp.StatusCode, payload, err)
+	}
+	if !strings.Contains(payload.Error, "line 2") {
+		t.Fatalf("expected the error to point at the broken line, got %q", payload.Error)
+	}
+
+	assertStatus(t, base+"en?token=invalid", http.StatusUnauthorized)
+	assertStatus(t, base+"es?token="+token, http.StatusNotFound)
+	assertStatus(t, base+"de?token="+token, http.StatusNotFound)
+}
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestContentKeyServer(t *testing.T) {
+	t.Parallel()
+
+	var originKeyRequested atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/enc/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=AES-128,URI=\"origin.key\",IV=0x00000000000000000000000000000001\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/enc/origin.key":
+			originKeyRequested.Store(true)
+			io.WriteString(w, "ORIGIN-KEY-BYTES")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	contentKey := bytes.Repeat([]byte{0x5a}, keys.KeySize)
+	manager := keys.NewManager(repository.NewContentKeyRepository(nil), secrets.NewBox([]byte("test-master")))
+	if _, err := manager.Import(context.Background(), "key-movie-km", contentKey); err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	if err := manager.Assign(context.Background(), "key-movie-km", "key-movie-id"); err != nil {
+		t.Fatalf("failed to assign key: %v", err)
+	}
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "key-movie-id",
+		Slug:      "key-movie",
+		Title:     "Key Movie",
+		StreamURL: upstream.URL + "/enc/index.m3u8",
+		DRMKeyID:  "key-movie-km",
+	}, service.WithKeyManager(manager))
+
+	playlist := getBody(t, server.URL+"/movies/key-movie/manifest.m3u8?token="+token)
+	keyURI := tagURI(playlist, "#EXT-X-KEY")
+	if !strings.HasPrefix(keyURI, "/movies/key-movie/content-key?") {
+		t.Fatalf("expected key URI to point at the key server, got: %s", playlist)
+	}
+	if !strings.Contains(playlist, "IV=0x00000000000000000000000000000001") {
+		t.Fatalf("expected the IV to be preserved: %s", playlist)
+	}
+
+	if body := getBody(t, server.URL+keyURI); body != string(contentKey) {
+		t.Fatalf("unexpected key %x", body)
+	}
+	if originKeyRequested.Load() {
+		t.Fatal("origin key must not be fetched when the key server is used")
+	}
+
+	assertStatus(t, server.URL+"/movies/key-movie/content-key?token=invalid", http.StatusUnauthorized)
+
+	// A token for another movie must not unlock this movie's key.
+	resp, err := http.Post(server.URL+"/movies/sample-movie/playback-token", "application/json", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	var other struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&other)
+	resp.Body.Close()
+	if other.Token == "" {
+		t.Fatal("expected a token for the sample movie")
+	}
+	assertStatus(t, server.URL+"/movies/key-movie/content-key?token="+other.Token, http.StatusUnauthorized)
+	assertStatus(t, server.URL+"/movies/sample-movie/content-key?token="+other.Token, http.StatusNotFound)
+
+	// Nor does a token for another movie naming the same key ID.
+	repository.NewMovieRepository(nil).UpsertSampleMovie(movies.Movie{
+		ID:                "key-thief-id",
+		Slug:              "key-thief",
+		Title:             "Key Thief",
+		IsVisible:         true,
+		AvailabilityStart: time.Now().Add(-time.Hour),
+		AvailabilityEnd:   time.Now().Add(time.Hour),
+		StreamURL:         upstream.URL + "/enc/index.m3u8",
+		DRMKeyID:          "key-movie-km",
+		TokenBinding:      movies.DefaultTokenBinding,
+	})
+	resp, err = http.Post(server.URL+"/movies/key-thief/playback-token", "application/json", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	var thief struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&thief)
+	resp.Body.Close()
+	if thief.Token == "" {
+		t.Fatal("expected a token for the other movie")
+	}
+	assertStatus(t, server.URL+"/movies/key-thief/content-key?token="+thief.Token, http.StatusNotFound)
+}
+
+func assertStatus(t *testing.T, target string, want int) {
+	t.Helper()
+
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", target, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Fatalf("GET %s: expected %d, got %d", target, want, resp.StatusCode)
+	}
+}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestDASHManifestIsProxied(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dash/manifest.mpd":
+			w.Header().Set("Content-Type", "application/dash+xml")
+			w.Write([]byte(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%03d$.m4s" initialization="$RepresentationID$/init.mp4" startNumber="1"/>
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`))
+		default:
+			w.Write([]byte("DASH " + r.URL.Path))
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "dash-movie-id",
+		Slug:      "dash-movie",
+		Title:     "DASH Movie",
+		StreamURL: upstream.URL + "/dash/manifest.mpd",
+	})
+	defer server.Close()
+
+	manifest := getBody(t, server.URL+"/movies/dash-movie/manifest.mpd?token="+token)
+	if strings.Contains(manifest, upstream.URL) {
+		t.Fatalf("manifest leaks the upstream host:\n%s", manifest)
+	}
+
+	media := attributeValue(manifest, "media")
+	initialization := attributeValue(manifest, "initialization")
+	if !strings.HasPrefix(media, "/movies/dash-movie/segment?") || !strings.Contains(media, "$Number%03d$") {
+		t.Fatalf("expected media template to go through the segment proxy, got %q", media)
+	}
+
+	// Fill in the template the way a DASH player does.
+	segment := strings.NewReplacer("$RepresentationID$", "v1", "$Number%03d$", "007").Replace(media)
+	if body := getBody(t, server.URL+segment); body != "DASH /dash/v1/seg-007.m4s" {
+		t.Fatalf("unexpected segment body %q", body)
+	}
+	init := strings.ReplaceAll(initialization, "$RepresentationID$", "v1")
+	if body := getBody(t, server.URL+init); body != "DASH /dash/v1/init.mp4" {
+		t.Fatalf("unexpected init body %q", body)
+	}
+
+	traversal := strings.NewReplacer("$RepresentationID$", "..", "$Number%03d$", "1").Replace(media)
+	resp, err := http.Get(server.URL + traversal)
+	if err != nil {
+		t.Fatalf("failed to fetch segment: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid template value, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/movies/dash-movie/manifest.m3u8?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to fetch hls manifest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for HLS manifest of a DASH stream, got %d", resp.StatusCode)
+	}
+}
+
+func attributeValue(document, name string) string {
+	start := strings.Index(document, " "+name+`="`)
+	if start < 0 {
+		return ""
+	}
+	value := document[start+len(name)+3:]
+	return html.UnescapeString(value[:strings.IndexByte(value, '"')])
+}
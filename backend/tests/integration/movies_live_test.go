@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+// fakeLiveOrigin serves a low-latency live playlist whose window advances by
+// one segment on every plain reload. Blocking reloads jump straight to the
+// requested media sequence number.
+type fakeLiveOrigin struct {
+	mu        sync.Mutex
+	sequence  int
+	stale     bool
+	lastQuery string
+}
+
+func (o *fakeLiveOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/live.m3u8" {
+		io.WriteString(w, "SEGMENT "+r.URL.Path)
+		return
+	}
+
+	o.mu.Lock()
+	o.lastQuery = r.URL.RawQuery
+	if msn := r.URL.Query().Get("_HLS_msn"); msn != "" {
+		o.sequence, _ = strconv.Atoi(msn)
+	} else if o.stale {
+		o.sequence -= 2
+	} else {
+		o.sequence++
+	}
+	seq := o.sequence
+	o.mu.Unlock()
+
+	fmt.Fprintf(w, "#EXTM3U\n#EXT-X-VERSION:9\n#EXT-X-TARGETDURATION:4\n"+
+		"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3.0\n"+
+		"#EXT-X-PART-INF:PART-TARGET=1.0\n"+
+		"#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-DISCONTINUITY-SEQUENCE:2\n"+
+		"#EXTINF:4,\nseg%d.ts\n"+
+		"#EXT-X-PART:DURATION=1.0,URI=\"seg%d.part0.ts\",INDEPENDENT=YES\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"seg%d.part1.ts\"\n"+
+		"#EXT-X-RENDITION-REPORT:URI=\"audio.m3u8\",LAST-MSN=%d,LAST-PART=0\n",
+		seq, seq, seq+1, seq+1, seq)
+}
+
+func TestLiveLowLatencyPlaylistProxy(t *testing.T) {
+	t.Parallel()
+
+	origin := &fakeLiveOrigin{sequence: 100}
+	upstream := httptest.NewServer(origin)
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-live",
+		Slug:      "live-movie",
+		Title:     "Live Movie",
+		StreamURL: upstream.URL + "/live.m3u8",
+	}, service.WithPlaylistCache(cache.NewPlaylistCache(cache.PlaylistCacheConfig{})))
+
+	manifestURL := server.URL + "/movies/live-movie/manifest.m3u8?token=" + token
+
+	first := getBody(t, manifestURL)
+	second := getBody(t, manifestURL)
+	if !strings.Contains(first, "#EXT-X-MEDIA-SEQUENCE:101") || !strings.Contains(second, "#EXT-X-MEDIA-SEQUENCE:102") {
+		t.Fatalf("expected live playlist to bypass the cache, got:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(second, "#EXT-X-DISCONTINUITY-SEQUENCE:2") {
+		t.Fatalf("expected discontinuity sequence to be preserved:\n%s", second)
+	}
+	for _, want := range []string{
+		`#EXT-X-PART:DURATION=1.0,URI="/movies/live-movie/segment?`,
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="/movies/live-movie/segment?`,
+		`#EXT-X-RENDITION-REPORT:URI="/movies/live-movie/variant.m3u8?`,
+	} {
+		if !strings.Contains(second, want) {
+			t.Fatalf("expected playlist to contain %q:\n%s", want, second)
+		}
+	}
+
+	blocking := getBody(t, manifestURL+"&_HLS_msn=110&_HLS_part=0")
+	if !strings.Contains(blocking, "#EXT-X-MEDIA-SEQUENCE:110") {
+		t.Fatalf("expected blocking reload to reach the origin:\n%s", blocking)
+	}
+	origin.mu.Lock()
+	query := origin.lastQuery
+	origin.stale = true
+	origin.mu.Unlock()
+	if !strings.Contains(query, "_HLS_msn=110") || !strings.Contains(query, "_HLS_part=0") {
+		t.Fatalf("expected delivery directives to be forwarded, got query %q", query)
+	}
+
+	regressed := getBody(t, manifestURL)
+	if !strings.Contains(regressed, "#EXT-X-MEDIA-SEQUENCE:110") {
+		t.Fatalf("expected stale origin response to be masked:\n%s", regressed)
+	}
+
+	partPath := tagURI(regressed, "#EXT-X-PART:")
+	if body := getBody(t, server.URL+partPath); body != "SEGMENT /seg111.part0.ts" {
+		t.Fatalf("unexpected part body %q", body)
+	}
+}
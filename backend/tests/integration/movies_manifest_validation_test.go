@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestInvalidUpstreamPlaylistsReturnErrorCodes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html.m3u8":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>Please enable JavaScript</body></html>"))
+		case "/huge.m3u8":
+			w.Write([]byte("#EXTM3U\n" + strings.Repeat("#EXTINF:4,\nsegment.ts\n", 1000)))
+		case "/broken.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:four\n#EXTINF:4,\nsegment.ts\n"))
+		}
+	}))
+	defer upstream.Close()
+
+	cases := []struct {
+		path string
+		code string
+	}{
+		{"/html.m3u8", "upstream_not_hls"},
+		{"/huge.m3u8", "upstream_too_large"},
+		{"/broken.m3u8", "upstream_invalid_playlist"},
+	}
+	for i, tc := range cases {
+		slug := "invalid-playlist-" + string(rune('a'+i))
+		server, token := newPlaybackServer(t, movies.Movie{
+			ID:        slug + "-id",
+			Slug:      slug,
+			Title:     slug,
+			StreamURL: upstream.URL + tc.path,
+		}, service.WithMaxPlaylistBytes(4<<10))
+
+		resp, err := http.Get(server.URL + "/movies/" + slug + "/manifest.m3u8?token=" + token)
+		if err != nil {
+			t.Fatalf("failed to fetch manifest: %v", err)
+		}
+		var payload struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&payload)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: failed to decode error body: %v", tc.path, err)
+		}
+		if resp.StatusCode != http.StatusBadGateway || payload.Code != tc.code {
+			t.Fatalf("%s: expected 502 %s, got %d %+v", tc.path, tc.code, resp.StatusCode, payload)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apimovies "github.com/leak-streaming/leak-streaming/backend/internal/api/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestStreamMetadataIsProbedAndExposed(t *testing.T) {
+	t.Parallel()
+
+	var encrypted atomic.Bool
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vod/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"Thai\",LANGUAGE=\"th\",DEFAULT=YES,URI=\"audio/th.m3u8\"\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\",AUDIO=\"aud\"\n"+
+				"low/index.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS=\"avc1.640028,mp4a.40.2\",AUDIO=\"aud\"\n"+
+				"high/index.m3u8\n")
+		case "/vod/low/index.m3u8":
+			key := ""
+			if encrypted.Load() {
+				key = "#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n"
+			}
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:600\n"+key+
+				"#EXTINF:600,\nseg0.ts\n#EXTINF:600,\nseg1.ts\n#EXTINF:30.5,\nseg2.ts\n#EXT-X-ENDLIST\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	repo := repository.NewMovieRepository(nil)
+	movieService := service.NewService(repo, service.NewInMemoryTokenSigner(), time.Minute, loopbackUpstream())
+
+	movie, err := movieService.CreateMovie(context.Background(), service.CreateMovieInput{
+		Title:             "Probed Premiere",
+		Synopsis:          "Metadata comes from the upstream playlists.",
+		PosterURL:         "https://example.com/poster.jpg",
+		AvailabilityStart: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		AvailabilityEnd:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		IsVisible:         true,
+		StreamURL:         origin.URL + "/vod/master.m3u8",
+	})
+	if err != nil {
+		t.Fatalf("CreateMovie returned error: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/movies/{slug}", apimovies.NewDetailsHandler(movieService).ServeHTTP)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	type details struct {
+		DurationMinutes int `json:"durationMinutes"`
+		StreamMetadata  *struct {
+			DurationSeconds float64 `json:"durationSeconds"`
+			Renditions      []struct {
+				Bandwidth  int64  `json:"bandwidth"`
+				Resolution string `json:"resolution"`
+				Codecs     string `json:"codecs"`
+				Audio      string `json:"audio"`
+			} `json:"renditions"`
+			Media []struct {
+				Type     string `json:"type"`
+				GroupID  string `json:"groupId"`
+				Language string `json:"language"`
+				Default  bool   `json:"default"`
+			} `json:"media"`
+			Encryption string `json:"encryption"`
+			ProbedAt   string `json:"probedAt"`
+		} `json:"streamMetadata"`
+	}
+	load := func() details {
+		var payload details
+		if err := json.Unmarshal([]byte(getBody(t, server.URL+"/movies/"+movie.Slug)), &payload); err != nil {
+			t.Fatalf("failed to decode details: %v", err)
+		}
+		if payload.StreamMetadata == nil {
+			t.Fatalf("expected stream metadata in details")
+		}
+		return payload
+	}
+
+	payload := load()
+	if payload.DurationMinutes != 21 || payload.StreamMetadata.DurationSeconds != 1230.5 {
+		t.Fatalf("expected 1230.5s rounded up to 21 minutes, got %+v", payload)
+	}
+	renditions := payload.StreamMetadata.Renditions
+	if len(renditions) != 2 || renditions[1].Bandwidth != 2400000 || renditions[1].Resolution != "1280x720" || renditions[1].Codecs != "avc1.640028,mp4a.40.2" || renditions[1].Audio != "aud" {
+		t.Fatalf("unexpected renditions %+v", renditions)
+	}
+	media := payload.StreamMetadata.Media
+	if len(media) != 1 || media[0].Type != "AUDIO" || media[0].GroupID != "aud" || media[0].Language != "th" || !media[0].Default {
+		t.Fatalf("unexpected media %+v", media)
+	}
+	if payload.StreamMetadata.Encryption != "NONE" || payload.StreamMetadata.ProbedAt == "" {
+		t.Fatalf("unexpected encryption or probe time %+v", payload.StreamMetadata)
+	}
+
+	encrypted.Store(true)
+	if _, err := movieService.ReprobeMovie(context.Background(), movie.Slug); err != nil {
+		t.Fatalf("ReprobeMovie returned error: %v", err)
+	}
+	if payload := load(); payload.StreamMetadata.Encryption != "AES-128" {
+		t.Fatalf("expected the re-probe to find AES-128, got %q", payload.StreamMetadata.Encryption)
+	}
+}
@@ -0,0 +1,171 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestRenditionPolicyFiltersMasterPlaylist(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\"\nsd/index.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\"\nhd/index.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,CODECS=\"avc1.640028,mp4a.40.2\"\nfhd/index.m3u8\n"+
+				"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=300000,RESOLUTION=1920x1080,URI=\"fhd/iframes.m3u8\"\n")
+		case "/sd/index.m3u8", "/hd/index.m3u8", "/fhd/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n"+
+				"#EXT-X-RENDITION-REPORT:URI=\"../fhd/index.m3u8\",LAST-MSN=0\n#EXT-X-ENDLIST\n")
+		case "/hd/seg0.ts", "/fhd/seg0.ts":
+			io.WriteString(w, "SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-renditions",
+		Slug:      "renditions-movie",
+		Title:     "Renditions Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	}, service.WithRenditionPolicies(map[string]service.RenditionPolicy{
+		service.DefaultRenditionTier: {MaxHeight: 720},
+		"premium":                    {},
+	}))
+
+	master := getBody(t, server.URL+"/movies/renditions-movie/manifest.m3u8?token="+token)
+	if strings.Contains(master, "1920x1080") {
+		t.Fatalf("expected the 1080p renditions to be filtered out: %s", master)
+	}
+	if count := strings.Count(master, "#EXT-X-STREAM-INF"); count != 2 {
+		t.Fatalf("expected 2 variants, got %d: %s", count, master)
+	}
+
+	// The 720p media playlist would report the 1080p rendition, handing the
+	// session a reference to it.
+	var hdPath string
+	for _, line := range strings.Split(master, "\n") {
+		if strings.HasPrefix(line, "/movies/") {
+			hdPath = line
+		}
+	}
+	media := getBody(t, server.URL+hdPath)
+	if strings.Contains(media, "#EXT-X-RENDITION-REPORT") {
+		t.Fatalf("expected the rendition report to be dropped: %s", media)
+	}
+	assertStatus(t, server.URL+firstProxyLine(media), http.StatusOK)
+
+	premiumToken := tierPlaybackToken(t, server.URL, "renditions-movie", "premium")
+	premium := getBody(t, server.URL+"/movies/renditions-movie/manifest.m3u8?token="+premiumToken)
+	if count := strings.Count(premium, "#EXT-X-STREAM-INF"); count != 3 {
+		t.Fatalf("expected every variant for the premium tier, got %d: %s", count, premium)
+	}
+	var premiumHDPath string
+	for _, line := range strings.Split(premium, "\n") {
+		if strings.HasPrefix(line, "/movies/") && premiumHDPath == "" {
+			premiumHDPath = line
+		}
+	}
+	premiumMedia := getBody(t, server.URL+premiumHDPath)
+	fhdPath := tagURI(premiumMedia, "#EXT-X-RENDITION-REPORT")
+	if fhdPath == "" {
+		t.Fatalf("expected a rewritten rendition report for the premium tier: %s", premiumMedia)
+	}
+	assertStatus(t, server.URL+fhdPath, http.StatusOK)
+}
+
+func TestRenditionPolicyIgnoresTierFromClients(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720\nhd/index.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080\nfhd/index.m3u8\n")
+	}))
+	defer upstream.Close()
+
+	policies := service.WithRenditionPolicies(map[string]service.RenditionPolicy{
+		service.DefaultRenditionTier: {MaxHeight: 720},
+		"premium":                    {},
+	})
+	newPlaybackServer(t, movies.Movie{
+		ID:        "movie-renditions-spoofed",
+		Slug:      "renditions-spoofed-movie",
+		Title:     "Renditions Spoofed Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	}, policies)
+
+	// Without trusted proxies the tier header comes from the client.
+	movieService := service.NewService(repository.NewMovieRepository(nil), service.NewInMemoryTokenSigner(), time.Minute, loopbackUpstream(), policies)
+	server := newAdminServer(t, movieService)
+
+	token := tierPlaybackToken(t, server.URL, "renditions-spoofed-movie", "premium")
+	master := getBody(t, server.URL+"/movies/renditions-spoofed-movie/manifest.m3u8?token="+token)
+	if strings.Contains(master, "1920x1080") || !strings.Contains(master, "1280x720") {
+		t.Fatalf("expected the default tier for a client claiming premium: %s", master)
+	}
+}
+
+// tierPlaybackToken requests a playback token with the X-Viewer-Tier header
+// the gateway sets.
+func tierPlaybackToken(t *testing.T, serverURL, slug, tier string) string {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodPost, serverURL+"/movies/"+slug+"/playback-token", http.NoBody)
+	req.Header.Set("X-Viewer-Tier", tier)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.Token == "" {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+	return payload.Token
+}
+
+func TestRenditionPolicyKeepsOneVariant(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,CODECS=\"hvc1.1.6.L120.90\"\nhevc/fhd.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1280x720,CODECS=\"hvc1.1.6.L93.90\"\nhevc/hd.m3u8\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-renditions-fallback",
+		Slug:      "renditions-fallback-movie",
+		Title:     "Renditions Fallback Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	}, service.WithRenditionPolicies(map[string]service.RenditionPolicy{
+		service.DefaultRenditionTier: {Codecs: []string{"avc1", "mp4a"}},
+	}))
+
+	master := getBody(t, server.URL+"/movies/renditions-fallback-movie/manifest.m3u8?token="+token)
+	if count := strings.Count(master, "#EXT-X-STREAM-INF"); count != 1 || !strings.Contains(master, "1280x720") {
+		t.Fatalf("expected only the lowest variant to be kept: %s", master)
+	}
+}
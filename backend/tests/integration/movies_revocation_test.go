@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestRevokedPlaybackTokenIsRefused(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-revocation",
+		Slug:      "revocation-movie",
+		Title:     "Revocation Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	})
+	manifestURL := server.URL + "/movies/revocation-movie/manifest.m3u8?token="
+	assertStatus(t, manifestURL+token, http.StatusOK)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"scope":"everything"}`, http.StatusBadRequest},
+		{`{"scope":"token","movie":"revocation-movie"}`, http.StatusBadRequest},
+		{`{"scope":"token","movie":"revocation-movie","token":"forged"}`, http.StatusUnprocessableEntity},
+		{`{"scope":"movie","movie":"missing-movie"}`, http.StatusNotFound},
+		{`{"scope":"viewer"}`, http.StatusBadRequest},
+	} {
+		resp := adminRequest(t, http.MethodPost, server.URL+"/admin/revocations", tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.body, tc.want, resp.StatusCode)
+		}
+	}
+
+	revoke := `{"scope":"token","movie":"revocation-movie","token":"` + token + `","reason":"leaked"}`
+	resp := adminRequest(t, http.MethodPost, server.URL+"/admin/revocations", revoke, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected revocations without credentials to be refused, got %d", resp.StatusCode)
+	}
+	assertStatus(t, manifestURL+token, http.StatusOK)
+
+	resp = adminRequest(t, http.MethodPost, server.URL+"/admin/revocations", revoke)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	for name, send := range map[string]func() (*http.Response, error){
+		"manifest": func() (*http.Response, error) { return http.Get(manifestURL + token) },
+		"refresh": func() (*http.Response, error) {
+			return http.Post(server.URL+"/movies/revocation-movie/playback-token/refresh", "application/json",
+				strings.NewReader(`{"token":"`+token+`"}`))
+		},
+	} {
+		resp, err := send()
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", name, err)
+		}
+		var payload struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&payload)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden || payload.Code != "token_revoked" {
+			t.Fatalf("%s: expected 403 token_revoked, got %d %q", name, resp.StatusCode, payload.Code)
+		}
+	}
+
+	var audit struct {
+		Revocations []struct {
+			Scope     string `json:"scope"`
+			MovieID   string `json:"movieId"`
+			SessionID string `json:"sessionId"`
+			Reason    string `json:"reason"`
+			Actor     string `json:"actor"`
+		} `json:"revocations"`
+	}
+	resp = adminRequest(t, http.MethodGet, server.URL+"/admin/revocations?limit=10", "")
+	err := json.NewDecoder(resp.Body).Decode(&audit)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to decode revocations: %v", err)
+	}
+	if len(audit.Revocations) != 1 {
+		t.Fatalf("expected one audited revocation, got %+v", audit.Revocations)
+	}
+	if got := audit.Revocations[0]; got.Scope != "token" || got.MovieID != "movie-revocation" || got.SessionID == "" || got.Reason != "leaked" || got.Actor != "alice" {
+		t.Fatalf("unexpected audit record %+v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/cache"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestSegmentCacheSharesUpstreamFetches(t *testing.T) {
+	t.Parallel()
+
+	var segmentFetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/movie.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/seg0.ts":
+			segmentFetches.Add(1)
+			w.Header().Set("Content-Type", "video/mp2t")
+			io.WriteString(w, "CACHED-SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	segmentCache, err := cache.NewSegmentCache(cache.SegmentCacheConfig{MaxMemoryBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("failed to create segment cache: %v", err)
+	}
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-cache",
+		Slug:      "cache-movie",
+		Title:     "Cache Movie",
+		StreamURL: upstream.URL + "/movie.m3u8",
+	}, service.WithSegmentCache(segmentCache))
+
+	segmentURL := server.URL + firstProxyLine(getBody(t, server.URL+"/movies/cache-movie/manifest.m3u8?token="+token))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(segmentURL)
+			if err != nil {
+				t.Errorf("segment request failed: %v", err)
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "CACHED-SEGMENT" {
+				t.Errorf("unexpected segment body %q", body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	req, _ := http.NewRequest(http.MethodGet, segmentURL, nil)
+	req.Header.Set("Range", "bytes=0-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("range request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "CACHED" {
+		t.Fatalf("expected ranged response from cache, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Cache") != "HIT" {
+		t.Fatalf("expected cache hit for range request")
+	}
+
+	if got := segmentFetches.Load(); got != 1 {
+		t.Fatalf("expected exactly one upstream segment fetch, got %d", got)
+	}
+	if stats := segmentCache.Stats(); stats.Hits+stats.Misses != 21 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestOversizedSegmentIsFetchedOnce(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("L", 3<<10)
+	var segmentFetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/movie.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nsized.ts\n#EXTINF:4,\nchunked.ts\n#EXT-X-ENDLIST\n")
+		case "/sized.ts":
+			segmentFetches.Add(1)
+			w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+			io.WriteString(w, large)
+		case "/chunked.ts":
+			segmentFetches.Add(1)
+			// Flushing before the body leaves the length unknown up front.
+			w.(http.Flusher).Flush()
+			io.WriteString(w, large)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	segmentCache, err := cache.NewSegmentCache(cache.SegmentCacheConfig{MaxMemoryBytes: 1 << 20, MaxEntryBytes: 1 << 10})
+	if err != nil {
+		t.Fatalf("failed to create segment cache: %v", err)
+	}
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-oversized",
+		Slug:      "oversized-movie",
+		Title:     "Oversized Movie",
+		StreamURL: upstream.URL + "/movie.m3u8",
+	}, service.WithSegmentCache(segmentCache))
+
+	manifest := getBody(t, server.URL+"/movies/oversized-movie/manifest.m3u8?token="+token)
+	var segments []string
+	for _, line := range strings.Split(manifest, "\n") {
+		if strings.HasPrefix(line, "/movies/") {
+			segments = append(segments, line)
+		}
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected two proxied segments, got %q", manifest)
+	}
+	for _, segment := range segments {
+		if body := getBody(t, server.URL+segment); body != large {
+			t.Fatalf("expected the whole oversized segment, got %d bytes", len(body))
+		}
+	}
+	if got := segmentFetches.Load(); got != 2 {
+		t.Fatalf("expected each oversized segment to be fetched once, got %d fetches", got)
+	}
+}
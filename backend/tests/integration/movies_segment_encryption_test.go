@@ -0,0 +1,191 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/hls"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/keys"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestClearSegmentsAreEncryptedOnTheFly(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/clear/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n#EXTINF:4,\nseg10.ts\n#EXTINF:4,\nseg11.ts\n#EXT-X-ENDLIST\n")
+		case "/clear/seg10.ts", "/clear/seg11.ts":
+			io.WriteString(w, "CLEAR "+r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	manager := keys.NewManager(repository.NewContentKeyRepository(nil), secrets.NewBox([]byte("test-master")))
+	if err := manager.Generate(context.Background(), "encrypted-movie-km"); err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	if err := manager.Assign(context.Background(), "encrypted-movie-km", "encrypted-movie-id"); err != nil {
+		t.Fatalf("failed to assign key: %v", err)
+	}
+	contentKey, _ := manager.Key(context.Background(), "encrypted-movie-km", "encrypted-movie-id")
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:              "encrypted-movie-id",
+		Slug:            "encrypted-movie",
+		Title:           "Encrypted Movie",
+		StreamURL:       upstream.URL + "/clear/index.m3u8",
+		DRMKeyID:        "encrypted-movie-km",
+		EncryptSegments: true,
+	}, service.WithKeyManager(manager))
+
+	playlist := getBody(t, server.URL+"/movies/encrypted-movie/manifest.m3u8?token="+token)
+	lines := strings.Split(playlist, "\n")
+	if !strings.HasPrefix(lines[1], "#EXT-X-KEY:METHOD=AES-128,URI=\"/movies/encrypted-movie/content-key?") {
+		t.Fatalf("expected an injected key tag after #EXTM3U, got:\n%s", playlist)
+	}
+	if body := getBody(t, server.URL+tagURI(playlist, "#EXT-X-KEY")); body != string(contentKey) {
+		t.Fatalf("key server returned a different key")
+	}
+
+	var segments []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "/movies/") {
+			segments = append(segments, line)
+		}
+	}
+	if len(segments) != 2 || !strings.Contains(segments[0], "eref=") {
+		t.Fatalf("expected encrypted segment references, got:\n%s", playlist)
+	}
+
+	for i, segment := range segments {
+		encrypted := []byte(getBody(t, server.URL+segment))
+		block, _ := aes.NewCipher(contentKey)
+		plain := make([]byte, len(encrypted))
+		cipher.NewCBCDecrypter(block, hls.SequenceIV(uint64(10+i))).CryptBlocks(plain, encrypted)
+		plain = plain[:len(plain)-int(plain[len(plain)-1])]
+		want := []byte("CLEAR /clear/seg1" + string(rune('0'+i)) + ".ts")
+		if !bytes.Equal(plain, want) {
+			t.Fatalf("segment %d decrypted to %q", i, plain)
+		}
+	}
+
+	// An encrypted segment reference cannot be replayed as a clear one.
+	parsed, _ := url.Parse(segments[0])
+	query := parsed.Query()
+	query.Set("ref", query.Get("eref"))
+	query.Del("eref")
+	assertStatus(t, server.URL+parsed.Path+"?"+query.Encode(), http.StatusForbidden)
+}
+
+func TestFragmentedMP4SegmentsAreNotEncrypted(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fmp4/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\nseg0.m4s\n#EXT-X-ENDLIST\n")
+		case "/fmp4/seg0.m4s":
+			io.WriteString(w, "FMP4-SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:              "fmp4-movie-id",
+		Slug:            "fmp4-movie",
+		Title:           "fMP4 Movie",
+		StreamURL:       upstream.URL + "/fmp4/index.m3u8",
+		DRMKeyID:        "fmp4-movie-km",
+		EncryptSegments: true,
+	})
+
+	playlist := getBody(t, server.URL+"/movies/fmp4-movie/manifest.m3u8?token="+token)
+	if strings.Contains(playlist, "#EXT-X-KEY") {
+		t.Fatalf("fMP4 playlists must not get an injected key:\n%s", playlist)
+	}
+	if body := getBody(t, server.URL+firstProxyLine(playlist)); body != "FMP4-SEGMENT" {
+		t.Fatalf("unexpected segment body %q", body)
+	}
+}
+
+func TestDeltaPlaylistSegmentsUseTheirOwnIVs(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live/index.m3u8":
+			if r.URL.Query().Get("_HLS_skip") != "YES" {
+				http.Error(w, "expected a delta update request", http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, "#EXTM3U\n#EXT-X-VERSION:9\n#EXT-X-TARGETDURATION:4\n#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=24\n#EXT-X-MEDIA-SEQUENCE:100\n#EXT-X-SKIP:SKIPPED-SEGMENTS=6\n#EXTINF:4,\nseg106.ts\n#EXTINF:4,\nseg107.ts\n")
+		case "/live/seg106.ts", "/live/seg107.ts":
+			io.WriteString(w, "CLEAR "+r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	manager := keys.NewManager(repository.NewContentKeyRepository(nil), secrets.NewBox([]byte("test-master")))
+	if err := manager.Generate(context.Background(), "delta-movie-km"); err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	if err := manager.Assign(context.Background(), "delta-movie-km", "delta-movie-id"); err != nil {
+		t.Fatalf("failed to assign key: %v", err)
+	}
+	contentKey, _ := manager.Key(context.Background(), "delta-movie-km", "delta-movie-id")
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:              "delta-movie-id",
+		Slug:            "delta-movie",
+		Title:           "Delta Movie",
+		StreamURL:       upstream.URL + "/live/index.m3u8",
+		DRMKeyID:        "delta-movie-km",
+		EncryptSegments: true,
+	}, service.WithKeyManager(manager))
+
+	playlist := getBody(t, server.URL+"/movies/delta-movie/manifest.m3u8?token="+token+"&_HLS_skip=YES")
+	var segments []string
+	for _, line := range strings.Split(playlist, "\n") {
+		if strings.HasPrefix(line, "/movies/") {
+			segments = append(segments, line)
+		}
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected two encrypted segments, got:\n%s", playlist)
+	}
+
+	// Segments after the skip are numbered from MEDIA-SEQUENCE plus
+	// SKIPPED-SEGMENTS, and so are their IVs.
+	for i, segment := range segments {
+		encrypted := []byte(getBody(t, server.URL+segment))
+		block, _ := aes.NewCipher(contentKey)
+		plain := make([]byte, len(encrypted))
+		cipher.NewCBCDecrypter(block, hls.SequenceIV(uint64(106+i))).CryptBlocks(plain, encrypted)
+		if pad := int(plain[len(plain)-1]); pad > 0 && pad <= aes.BlockSize {
+			plain = plain[:len(plain)-pad]
+		}
+		want := []byte("CLEAR /live/seg10" + string(rune('6'+i)) + ".ts")
+		if !bytes.Equal(plain, want) {
+			t.Fatalf("segment %d decrypted to %q", 106+i, plain)
+		}
+	}
+}
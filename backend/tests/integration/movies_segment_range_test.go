@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestSegmentRangeRequests(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/movie.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-TARGETDURATION:4\n#EXT-X-BYTERANGE:10@0\n#EXTINF:4,\nmovie.ts\n#EXT-X-BYTERANGE:10@10\n#EXTINF:4,\nmovie.ts\n#EXT-X-ENDLIST\n")
+		case "/movie.ts":
+			w.Header().Set("Content-Type", "video/mp2t")
+			w.Header().Set("ETag", `"movie-v1"`)
+			http.ServeContent(w, r, "movie.ts", modified, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-range",
+		Slug:      "range-movie",
+		Title:     "Range Movie",
+		StreamURL: upstream.URL + "/movie.m3u8",
+	})
+
+	playlist := getBody(t, server.URL+"/movies/range-movie/manifest.m3u8?token="+token)
+	if !strings.Contains(playlist, "#EXT-X-BYTERANGE:10@10") {
+		t.Fatalf("expected byte range tags to be preserved, got:\n%s", playlist)
+	}
+	segmentURL := server.URL + firstProxyLine(playlist)
+
+	req, _ := http.NewRequest(http.MethodGet, segmentURL, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("range request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", resp.StatusCode)
+	}
+	if string(body) != "ABCDEFGHIJ" {
+		t.Fatalf("unexpected range body %q", body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 10-19/36" {
+		t.Fatalf("unexpected Content-Range %q", got)
+	}
+	if got := resp.Header.Get("Accept-Ranges"); got != "bytes" {
+		t.Fatalf("unexpected Accept-Ranges %q", got)
+	}
+	if got := resp.Header.Get("ETag"); got != `"movie-v1"` {
+		t.Fatalf("unexpected ETag %q", got)
+	}
+	if got := resp.Header.Get("Last-Modified"); got != modified.Format(http.TimeFormat) {
+		t.Fatalf("unexpected Last-Modified %q", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodHead, segmentURL, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("head request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for HEAD, got %d", resp.StatusCode)
+	}
+	if resp.ContentLength != int64(len(content)) {
+		t.Fatalf("expected Content-Length %d for HEAD, got %d", len(content), resp.ContentLength)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, segmentURL, nil)
+	req.Header.Set("Range", "bytes=100-200")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unsatisfiable range request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", resp.StatusCode)
+	}
+}
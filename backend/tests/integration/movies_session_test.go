@@ -0,0 +1,93 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestPlaybackTokenRefreshKeepsSession(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/seg0.ts":
+			io.WriteString(w, "segment-0")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-session",
+		Slug:      "session-movie",
+		Title:     "Session Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	})
+
+	segment := firstProxyLine(getBody(t, server.URL+"/movies/session-movie/manifest.m3u8?token="+token))
+	if segment == "" {
+		t.Fatalf("expected a proxied segment line")
+	}
+
+	refreshURL := server.URL + "/movies/session-movie/playback-token/refresh"
+	resp, err := http.Post(refreshURL, "application/json", strings.NewReader(`{"token":"`+token+`"}`))
+	if err != nil {
+		t.Fatalf("failed to refresh token: %v", err)
+	}
+	var refreshed struct {
+		Token            string    `json:"token"`
+		ExpiresAt        time.Time `json:"expiresAt"`
+		SessionExpiresAt time.Time `json:"sessionExpiresAt"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&refreshed)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || refreshed.Token == "" || refreshed.Token == token {
+		t.Fatalf("expected a new token, got %d %+v (%v)", resp.StatusCode, refreshed, err)
+	}
+	if refreshed.ExpiresAt.IsZero() || refreshed.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("expected the new token to expire in the future, got %v", refreshed.ExpiresAt)
+	}
+
+	// The player swaps the token into URLs of playlists it already loaded.
+	target, _ := url.Parse(server.URL + segment)
+	query := target.Query()
+	query.Set("token", refreshed.Token)
+	target.RawQuery = query.Encode()
+	if body := getBody(t, target.String()); body != "segment-0" {
+		t.Fatalf("expected the segment through the refreshed token, got %q", body)
+	}
+
+	for name, target := range map[string]string{
+		"refresh":  "",
+		"manifest": server.URL + "/movies/session-movie/manifest.m3u8?token=invalid",
+	} {
+		var resp *http.Response
+		if target == "" {
+			resp, err = http.Post(refreshURL, "application/json", strings.NewReader(`{"token":"invalid"}`))
+		} else {
+			resp, err = http.Get(target)
+		}
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", name, err)
+		}
+		var payload struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&payload)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || payload.Code != "token_invalid" {
+			t.Fatalf("%s: expected 401 token_invalid, got %d %q", name, resp.StatusCode, payload.Code)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestStreamSourceChecksReportDeadLinks(t *testing.T) {
+	t.Parallel()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n")
+		case "/ok/low/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/ok/low/seg0.ts":
+			io.WriteString(w, "SEGMENT")
+		case "/broken/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nmissing.ts\n#EXT-X-ENDLIST\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	repo := repository.NewMovieRepository(nil)
+	repo.UpsertSampleMovie(movies.Movie{
+		ID:                 "movie-checks",
+		Slug:               "checked-movie",
+		Title:              "Checked Movie",
+		IsVisible:          true,
+		AvailabilityStart:  time.Now().Add(-time.Hour),
+		AvailabilityEnd:    time.Now().Add(time.Hour),
+		StreamURL:          origin.URL + "/ok/master.m3u8",
+		MirrorURLs:         []string{origin.URL + "/broken/master.m3u8", origin.URL + "/gone/master.m3u8"},
+		AllowedStreamHosts: []string{},
+	})
+	movieService := service.NewService(repo, service.NewInMemoryTokenSigner(), time.Minute, loopbackUpstream())
+
+	checks, err := movieService.CheckMovieSources(context.Background(), "checked-movie")
+	if err != nil {
+		t.Fatalf("CheckMovieSources returned error: %v", err)
+	}
+	want := []movies.ValidationStatus{movies.ValidationValid, movies.ValidationInvalid, movies.ValidationInvalid}
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %+v", len(want), checks)
+	}
+	for i, check := range checks {
+		if check.Status != want[i] || check.Priority != i {
+			t.Fatalf("check %d: expected %s at priority %d, got %+v", i, want[i], i, check)
+		}
+	}
+	if checks[1].Reason == "" || checks[1].CheckedAt.IsZero() {
+		t.Fatalf("expected a failure reason and check time, got %+v", checks[1])
+	}
+
+	server := newAdminServer(t, movieService)
+
+	var payload struct {
+		Sources []struct {
+			Slug      string     `json:"slug"`
+			StreamURL string     `json:"streamUrl"`
+			Status    string     `json:"status"`
+			Reason    string     `json:"reason"`
+			LatencyMS *int64     `json:"latencyMs"`
+			CheckedAt *time.Time `json:"checkedAt"`
+		} `json:"sources"`
+	}
+	resp := adminRequest(t, http.MethodGet, server.URL+"/admin/stream-sources?status=invalid", "")
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode stream checks: %v", err)
+	}
+	if len(payload.Sources) != 2 {
+		t.Fatalf("expected the 2 dead mirrors, got %+v", payload.Sources)
+	}
+	for _, source := range payload.Sources {
+		if source.Slug != "checked-movie" || source.Status != "INVALID" || source.Reason == "" || source.LatencyMS == nil || source.CheckedAt == nil {
+			t.Fatalf("unexpected stream check %+v", source)
+		}
+	}
+	if payload.Sources[0].StreamURL != origin.URL+"/broken/master.m3u8" {
+		t.Fatalf("expected checks in priority order, got %+v", payload.Sources)
+	}
+
+	for _, tc := range []struct {
+		token string
+		want  int
+	}{
+		{testAdminToken, http.StatusBadRequest},
+		{"", http.StatusUnauthorized},
+	} {
+		resp := adminRequest(t, http.MethodGet, server.URL+"/admin/stream-sources?status=bogus", "", tc.token)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("expected %d, got %d", tc.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestStreamSourceChecksIncludeHiddenMovies(t *testing.T) {
+	t.Parallel()
+
+	repo := repository.NewMovieRepository(nil)
+	repo.UpsertSampleMovie(movies.Movie{
+		ID:                "movie-hidden-check",
+		Slug:              "hidden-checked-movie",
+		Title:             "Hidden Checked Movie",
+		AvailabilityStart: time.Now().Add(24 * time.Hour),
+		StreamURL:         "https://cdn.example.com/hidden/master.m3u8",
+	})
+
+	slugs, err := repo.ListStreamSlugs(context.Background())
+	if err != nil {
+		t.Fatalf("ListStreamSlugs returned error: %v", err)
+	}
+	if !slices.Contains(slugs, "hidden-checked-movie") {
+		t.Fatalf("expected the hidden, scheduled movie to be checked, got %v", slugs)
+	}
+}
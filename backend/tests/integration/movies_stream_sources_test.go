@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestStreamFailsOverToMirrorSource(t *testing.T) {
+	t.Parallel()
+
+	var primaryDown atomic.Bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if primaryDown.Load() {
+			http.Error(w, "origin down", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/vod/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n")
+		case "/vod/low/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/vod/low/seg0.ts":
+			io.WriteString(w, "PRIMARY-SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/backup/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n")
+		case "/backup/low/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/backup/low/seg0.ts":
+			io.WriteString(w, "MIRROR-SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mirror.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:         "movie-mirrors",
+		Slug:       "mirror-movie",
+		Title:      "Mirror Movie",
+		StreamURL:  primary.URL + "/vod/master.m3u8",
+		MirrorURLs: []string{mirror.URL + "/backup/master.m3u8"},
+	})
+
+	master := getBody(t, server.URL+"/movies/mirror-movie/manifest.m3u8?token="+token)
+	variantPath := firstProxyLine(master)
+	media := getBody(t, server.URL+variantPath)
+	segmentPath := firstProxyLine(media)
+	if body := getBody(t, server.URL+segmentPath); body != "PRIMARY-SEGMENT" {
+		t.Fatalf("expected segment from the primary source, got %q", body)
+	}
+
+	primaryDown.Store(true)
+
+	if body := getBody(t, server.URL+segmentPath); body != "MIRROR-SEGMENT" {
+		t.Fatalf("expected segment from the mirror after the primary failed, got %q", body)
+	}
+	if media := getBody(t, server.URL+variantPath); !strings.Contains(media, "/movies/mirror-movie/segment?") {
+		t.Fatalf("expected media playlist from the mirror, got: %s", media)
+	}
+
+	master = getBody(t, server.URL+"/movies/mirror-movie/manifest.m3u8?token="+token)
+	if !strings.Contains(master, "/movies/mirror-movie/variant.m3u8?") {
+		t.Fatalf("expected master playlist from the mirror with the same token, got: %s", master)
+	}
+}
+
+func TestStreamWithoutHealthySourceReportsUpstreamError(t *testing.T) {
+	t.Parallel()
+
+	down := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "origin down", http.StatusBadGateway)
+	}
+	primary := httptest.NewServer(http.HandlerFunc(down))
+	defer primary.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(down))
+	defer mirror.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:         "movie-mirrors-down",
+		Slug:       "mirrors-down",
+		Title:      "Mirrors Down",
+		StreamURL:  primary.URL + "/master.m3u8",
+		MirrorURLs: []string{mirror.URL + "/master.m3u8"},
+	})
+
+	resp, err := http.Get(server.URL + "/movies/mirrors-down/manifest.m3u8?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusInternalServerError {
+		t.Fatalf("expected an upstream error status, got %d", resp.StatusCode)
+	}
+}
@@ -58,7 +58,7 @@ func TestMovieStreamFlow(t *testing.T) {
 	})
 
 	signer := service.NewInMemoryTokenSigner()
-	movieService := service.NewService(repo, signer, time.Minute)
+	movieService := service.NewService(repo, signer, time.Minute, loopbackUpstream())
 
 	r := chi.NewRouter()
 	r.Use(apimiddleware.SecureHeaders())
@@ -175,15 +175,33 @@ func TestMovieStreamFlow(t *testing.T) {
 		t.Fatalf("unexpected segment body: %s", string(data))
 	}
 
-	// Step 5: invalid host should be blocked
-	badURL := server.URL + "/movies/integration-movie/segment?token=" + playbackToken + "&target=" + url.QueryEscape("https://evil.example.com/segment.ts")
+	// Step 5: raw upstream URLs and tampered references should be blocked
+	badURL := server.URL + "/movies/integration-movie/segment?token=" + playbackToken + "&ref=" + url.QueryEscape("https://evil.example.com/segment.ts")
 	badResp, err := http.Get(badURL)
 	if err != nil {
-		t.Fatalf("failed to fetch segment with bad host: %v", err)
+		t.Fatalf("failed to fetch segment with raw url: %v", err)
 	}
 	defer badResp.Body.Close()
 	if badResp.StatusCode != http.StatusForbidden {
-		t.Fatalf("expected 403 for forbidden host, got %d", badResp.StatusCode)
+		t.Fatalf("expected 403 for raw upstream url, got %d", badResp.StatusCode)
+	}
+
+	parsedSegment, err := url.Parse(segmentPath)
+	if err != nil {
+		t.Fatalf("failed to parse segment path: %v", err)
+	}
+	query := parsedSegment.Query()
+	ref := []byte(query.Get("ref"))
+	ref[len(ref)/2] ^= 'A' ^ 'B'
+	query.Set("ref", string(ref))
+	parsedSegment.RawQuery = query.Encode()
+	tamperedResp, err := http.Get(server.URL + parsedSegment.String())
+	if err != nil {
+		t.Fatalf("failed to fetch segment with tampered ref: %v", err)
+	}
+	defer tamperedResp.Body.Close()
+	if tamperedResp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for tampered ref, got %d", tamperedResp.StatusCode)
 	}
 
 	// Step 6: invalid token should be rejected
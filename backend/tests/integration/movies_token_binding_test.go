@@ -0,0 +1,153 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestPlaybackTokenBoundToViewer(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer upstream.Close()
+
+	server, _ := newPlaybackServer(t, movies.Movie{
+		ID:           "movie-bound",
+		Slug:         "bound-movie",
+		Title:        "Bound Movie",
+		StreamURL:    upstream.URL + "/master.m3u8",
+		TokenBinding: movies.TokenBinding{IPv4Prefix: 24, UserAgent: true},
+	})
+
+	viewer := http.Header{
+		"X-Viewer-Id":     {"viewer-1"},
+		"User-Agent":      {"Player/1.0"},
+		"X-Forwarded-For": {"203.0.113.10"},
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/movies/bound-movie/playback-token", http.NoBody)
+	req.Header = viewer.Clone()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	var payload struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&payload)
+	resp.Body.Close()
+	if err != nil || payload.Token == "" {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+
+	manifest := server.URL + "/movies/bound-movie/manifest.m3u8?token=" + payload.Token
+	for name, tc := range map[string]struct {
+		header string
+		value  string
+		want   int
+	}{
+		"same viewer":   {want: http.StatusOK},
+		"same network":  {header: "X-Forwarded-For", value: "203.0.113.99", want: http.StatusOK},
+		"other network": {header: "X-Forwarded-For", value: "198.51.100.10", want: http.StatusForbidden},
+		"other browser": {header: "User-Agent", value: "curl/8.0", want: http.StatusForbidden},
+		"other viewer":  {header: "X-Viewer-Id", value: "viewer-2", want: http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, manifest, nil)
+		req.Header = viewer.Clone()
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: failed to fetch manifest: %v", name, err)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if tc.want != http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&body)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", name, tc.want, resp.StatusCode)
+		}
+		if tc.want != http.StatusOK && body.Code != "token_binding_mismatch" {
+			t.Fatalf("%s: expected code token_binding_mismatch, got %q", name, body.Code)
+		}
+	}
+
+	// A copied token presented from another network with the victim's
+	// headers: the gateway appends the real address to the spoofed
+	// X-Forwarded-For, and only that entry counts.
+	req, _ = http.NewRequest(http.MethodGet, manifest, nil)
+	req.Header = viewer.Clone()
+	req.Header.Set("X-Forwarded-For", "203.0.113.10, 198.51.100.10")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a copied token with spoofed headers to be refused, got %d", resp.StatusCode)
+	}
+
+	assertStatus(t, server.URL+"/movies/bound-movie/manifest.m3u8?token=invalid", http.StatusUnauthorized)
+}
+
+func TestPlaybackTokenIgnoresViewerHeadersFromClients(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer upstream.Close()
+
+	newPlaybackServer(t, movies.Movie{
+		ID:           "movie-bound-direct",
+		Slug:         "bound-direct-movie",
+		Title:        "Bound Direct Movie",
+		StreamURL:    upstream.URL + "/master.m3u8",
+		TokenBinding: movies.DefaultTokenBinding,
+	})
+
+	// Without trusted proxies, forwarding and viewer headers come from the
+	// client and the token is bound to the connection's address.
+	signer := service.NewInMemoryTokenSigner()
+	server := newAdminServer(t, service.NewService(repository.NewMovieRepository(nil), signer, time.Minute, loopbackUpstream()))
+	spoofed := http.Header{
+		"X-Viewer-Id":     {"viewer-1"},
+		"X-Forwarded-For": {"203.0.113.10"},
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/movies/bound-direct-movie/playback-token", http.NoBody)
+	req.Header = spoofed.Clone()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	var payload struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&payload)
+	resp.Body.Close()
+	if err != nil || payload.Token == "" {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+
+	claims, ok, err := signer.ValidateToken(payload.Token, "movie-bound-direct")
+	if err != nil || !ok {
+		t.Fatalf("expected the token to validate: %v", err)
+	}
+	if claims.ViewerID != "ip:127.0.0.1" || claims.IPPrefix != "127.0.0.0/24" {
+		t.Fatalf("expected the token to be bound to the connection, got %+v", claims)
+	}
+}
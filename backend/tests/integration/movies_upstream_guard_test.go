@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestRedirectsOffAllowedHostsAreRejected(t *testing.T) {
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// localhost reaches the same listener but is not an allowed host.
+		offHost := strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)
+		switch r.URL.Path {
+		case "/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg.ts\n#EXT-X-ENDLIST\n"))
+		case "/seg.ts":
+			http.Redirect(w, r, offHost+"/internal.ts", http.StatusFound)
+		case "/moved.m3u8":
+			http.Redirect(w, r, offHost+"/index.m3u8", http.StatusFound)
+		default:
+			w.Write([]byte("INTERNAL"))
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "guard-movie-id",
+		Slug:      "guard-movie",
+		Title:     "Guard Movie",
+		StreamURL: upstream.URL + "/index.m3u8",
+	})
+	defer server.Close()
+
+	manifest := getBody(t, server.URL+"/movies/guard-movie/manifest.m3u8?token="+token)
+	segmentPath := firstProxyLine(manifest)
+	if segmentPath == "" {
+		t.Fatalf("expected a proxied segment, got: %s", manifest)
+	}
+
+	resp, err := http.Get(server.URL + segmentPath)
+	if err != nil {
+		t.Fatalf("failed to fetch segment: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 for redirect off the allowed hosts, got %d", resp.StatusCode)
+	}
+
+	moved, token := newPlaybackServer(t, movies.Movie{
+		ID:        "guard-moved-id",
+		Slug:      "guard-moved",
+		Title:     "Guard Moved",
+		StreamURL: upstream.URL + "/moved.m3u8",
+	})
+	defer moved.Close()
+
+	resp, err = http.Get(moved.URL + "/movies/guard-moved/manifest.m3u8?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 for redirected manifest, got %d", resp.StatusCode)
+	}
+}
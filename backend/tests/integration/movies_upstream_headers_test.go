@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/platform/secrets"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+)
+
+func TestUpstreamHeadersAreSentOnEveryFetch(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://player.example.com/" || r.Header.Get("Cookie") != "cf_clearance=ok" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\"\n#EXTINF:4,\nseg.ts\n#EXT-X-ENDLIST\n"))
+		case "/key.bin":
+			w.Write([]byte("0123456789abcdef"))
+		case "/seg.ts":
+			w.Write([]byte("SEGMENT"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	box := secrets.NewBox([]byte("integration"))
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "headers-movie-id",
+		Slug:      "headers-movie",
+		Title:     "Headers Movie",
+		StreamURL: upstream.URL + "/index.m3u8",
+		UpstreamHeaders: []movies.UpstreamHeader{
+			{Name: "Cookie", Value: box.Seal("cf_clearance=ok", "Cookie"), Secret: true},
+			{Name: "Referer", Value: "https://player.example.com/"},
+		},
+	}, service.WithSecretBox(box))
+	defer server.Close()
+
+	manifest := getBody(t, server.URL+"/movies/headers-movie/manifest.m3u8?token="+token)
+	if body := getBody(t, server.URL+firstProxyLine(manifest)); body != "SEGMENT" {
+		t.Fatalf("unexpected segment body: %s", body)
+	}
+
+	keyPath := tagURI(manifest, "#EXT-X-KEY:")
+	if keyPath == "" {
+		t.Fatalf("expected rewritten key URI, got: %s", manifest)
+	}
+	if body := getBody(t, server.URL+keyPath); body != "0123456789abcdef" {
+		t.Fatalf("unexpected key body: %s", body)
+	}
+}
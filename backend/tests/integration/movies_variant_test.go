@@ -0,0 +1,250 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apimiddleware "github.com/leak-streaming/leak-streaming/backend/internal/api/middleware"
+	apimovies "github.com/leak-streaming/leak-streaming/backend/internal/api/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/persistence/repository"
+	service "github.com/leak-streaming/leak-streaming/backend/internal/service/movies"
+	"github.com/leak-streaming/leak-streaming/backend/internal/service/upstream"
+)
+
+func TestMasterPlaylistVariantsAreRewritten(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720\nhigh/index.m3u8\n")
+		case "/low/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n")
+		case "/low/seg0.ts":
+			io.WriteString(w, "LOW-SEGMENT")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-variant",
+		Slug:      "variant-movie",
+		Title:     "Variant Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	})
+
+	master := getBody(t, server.URL+"/movies/variant-movie/manifest.m3u8?token="+token)
+	variantPath := firstProxyLine(master)
+	if !strings.HasPrefix(variantPath, "/movies/variant-movie/variant.m3u8?") {
+		t.Fatalf("expected variant URI to use variant endpoint, got: %s", master)
+	}
+	if strings.Contains(master, "/movies/variant-movie/segment?") {
+		t.Fatalf("master playlist should not reference the segment endpoint: %s", master)
+	}
+
+	media := getBody(t, server.URL+variantPath)
+	segmentPath := firstProxyLine(media)
+	if !strings.HasPrefix(segmentPath, "/movies/variant-movie/segment?") {
+		t.Fatalf("expected media playlist segments to be rewritten, got: %s", media)
+	}
+
+	if body := getBody(t, server.URL+segmentPath); body != "LOW-SEGMENT" {
+		t.Fatalf("unexpected segment body: %s", body)
+	}
+
+	resp, err := http.Get(server.URL + "/movies/variant-movie/variant.m3u8?token=invalid&ref=opaque")
+	if err != nil {
+		t.Fatalf("failed to call variant endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid token, got %d", resp.StatusCode)
+	}
+}
+
+func newPlaybackServer(t *testing.T, movie movies.Movie, opts ...service.Option) (*httptest.Server, string) {
+	t.Helper()
+
+	movie.IsVisible = true
+	movie.AvailabilityStart = time.Now().Add(-time.Hour)
+	movie.AvailabilityEnd = time.Now().Add(time.Hour)
+	if movie.AllowedStreamHosts == nil {
+		movie.AllowedStreamHosts = []string{}
+	}
+
+	repo := repository.NewMovieRepository(nil)
+	repo.UpsertSampleMovie(movie)
+	opts = append([]service.Option{loopbackUpstream()}, opts...)
+	movieService := service.NewService(repo, service.NewInMemoryTokenSigner(), time.Minute, opts...)
+
+	// The tests stand in for the gateway, so it sits on loopback.
+	r := chi.NewRouter()
+	r.Use(apimiddleware.TrustedProxies([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}))
+	r.Post("/movies/{slug}/playback-token", apimovies.NewStreamTokenHandler(movieService).ServeHTTP)
+	r.Post("/movies/{slug}/playback-token/refresh", apimovies.NewStreamTokenRefreshHandler(movieService).ServeHTTP)
+	r.Get("/movies/{slug}/manifest.m3u8", apimovies.NewManifestHandler(movieService).ServeHTTP)
+	r.Get("/movies/{slug}/manifest.mpd", apimovies.NewDASHManifestHandler(movieService).ServeHTTP)
+	r.Get("/movies/{slug}/variant.m3u8", apimovies.NewVariantHandler(movieService).ServeHTTP)
+	segmentHandler := apimovies.NewSegmentHandler(movieService)
+	r.Get("/movies/{slug}/segment", segmentHandler.ServeHTTP)
+	r.Head("/movies/{slug}/segment", segmentHandler.ServeHTTP)
+	r.Get("/movies/{slug}/key", apimovies.NewKeyHandler(movieService).ServeHTTP)
+	r.Get("/movies/{slug}/content-key", apimovies.NewContentKeyHandler(movieService).ServeHTTP)
+	r.Get("/movies/{slug}/captions/{lang}", apimovies.NewCaptionHandler(movieService).ServeHTTP)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(apimiddleware.AdminAuth(apimiddleware.AdminTokens{testAdminToken: "alice"}))
+		r.Post("/movies/{slug}/watermark/decode", apimovies.NewWatermarkDecodeHandler(movieService).ServeHTTP)
+		r.Get("/revocations", apimovies.NewRevocationsHandler(movieService).ServeHTTP)
+		r.Post("/revocations", apimovies.NewRevokeHandler(movieService).ServeHTTP)
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Post(server.URL+"/movies/"+movie.Slug+"/playback-token", "application/json", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+	if payload.Token == "" {
+		t.Fatalf("expected non-empty token")
+	}
+
+	return server, payload.Token
+}
+
+// loopbackUpstream lets the proxy reach httptest origins, which the upstream
+// fetcher blocks by default.
+func loopbackUpstream() service.Option {
+	return service.WithUpstream(upstream.New(upstream.Config{
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	}))
+}
+
+func getBody(t *testing.T, target string) string {
+	t.Helper()
+
+	resp, err := http.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d: %s", target, resp.StatusCode, data)
+	}
+	return string(data)
+}
+
+// tagURI returns the URI attribute of the first line starting with tag.
+func tagURI(playlist, tag string) string {
+	for _, line := range strings.Split(playlist, "\n") {
+		if !strings.HasPrefix(line, tag) {
+			continue
+		}
+		start := strings.Index(line, `URI="`)
+		if start < 0 {
+			return ""
+		}
+		uri := line[start+len(`URI="`):]
+		if end := strings.IndexByte(uri, '"'); end >= 0 {
+			return uri[:end]
+		}
+		return ""
+	}
+	return ""
+}
+
+func firstProxyLine(playlist string) string {
+	for _, line := range strings.Split(playlist, "\n") {
+		if strings.HasPrefix(line, "/movies/") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestTagURIAttributesAreRewritten(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-SESSION-KEY:METHOD=AES-128,URI=\"keys/session.key\"\n"+
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",NAME=\"English, Stereo\",URI=\"audio/en.m3u8\"\n"+
+				"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=90000,URI=\"iframe.m3u8\"\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO=\"aud\"\nvideo.m3u8\n")
+		case "/video.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n"+
+				"#EXT-X-MAP:URI=\"init.mp4\"\n"+
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"keys/media.key\",IV=0x00000000000000000000000000000001\n"+
+				"#EXTINF:4,\nseg0.m4s\n#EXT-X-ENDLIST\n")
+		case "/keys/media.key":
+			io.WriteString(w, "0123456789abcdef")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:        "movie-tags",
+		Slug:      "tags-movie",
+		Title:     "Tags Movie",
+		StreamURL: upstream.URL + "/master.m3u8",
+	})
+
+	master := getBody(t, server.URL+"/movies/tags-movie/manifest.m3u8?token="+token)
+	for _, want := range []string{
+		`#EXT-X-SESSION-KEY:METHOD=AES-128,URI="/movies/tags-movie/key?`,
+		`NAME="English, Stereo",URI="/movies/tags-movie/variant.m3u8?`,
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=90000,URI="/movies/tags-movie/variant.m3u8?`,
+	} {
+		if !strings.Contains(master, want) {
+			t.Fatalf("expected master playlist to contain %q, got:\n%s", want, master)
+		}
+	}
+
+	media := getBody(t, server.URL+firstProxyLine(master))
+	if !strings.Contains(media, `#EXT-X-MAP:URI="/movies/tags-movie/segment?`) {
+		t.Fatalf("expected EXT-X-MAP to be rewritten, got:\n%s", media)
+	}
+	keyLine := ""
+	for _, line := range strings.Split(media, "\n") {
+		if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			keyLine = line
+		}
+	}
+	if !strings.Contains(keyLine, `URI="/movies/tags-movie/key?`) || !strings.HasSuffix(keyLine, ",IV=0x00000000000000000000000000000001") {
+		t.Fatalf("unexpected key line: %s", keyLine)
+	}
+
+	keyPath := keyLine[strings.Index(keyLine, `URI="`)+5:]
+	keyPath = keyPath[:strings.IndexByte(keyPath, '"')]
+	if key := getBody(t, server.URL+keyPath); key != "0123456789abcdef" {
+		t.Fatalf("unexpected key body: %q", key)
+	}
+}
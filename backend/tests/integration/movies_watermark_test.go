@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leak-streaming/leak-streaming/backend/internal/domain/movies"
+)
+
+func TestWatermarkedSessionsCanBeTraced(t *testing.T) {
+	t.Parallel()
+
+	const segments = 64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variant, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		switch {
+		case rest == "master.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720/index.m3u8\n")
+		case rest == "720/index.m3u8":
+			var playlist strings.Builder
+			playlist.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:100\n")
+			for i := 0; i < segments; i++ {
+				fmt.Fprintf(&playlist, "#EXTINF:4,\nseg%d.ts\n", 100+i)
+			}
+			playlist.WriteString("#EXT-X-ENDLIST\n")
+			io.WriteString(w, playlist.String())
+		case strings.HasPrefix(rest, "720/seg"):
+			io.WriteString(w, strings.ToUpper(variant))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	server, token := newPlaybackServer(t, movies.Movie{
+		ID:           "watermark-movie-id",
+		Slug:         "watermark-movie",
+		Title:        "Watermark Movie",
+		StreamURL:    upstream.URL + "/a/master.m3u8",
+		WatermarkURL: upstream.URL + "/b/master.m3u8",
+	})
+	otherToken := playbackToken(t, server.URL, "watermark-movie")
+
+	first := observeVariants(t, server.URL, token)
+	second := observeVariants(t, server.URL, otherToken)
+	if first == second {
+		t.Fatalf("expected sessions to get different A/B sequences, both got %s", first)
+	}
+	if !strings.Contains(first, "A") || !strings.Contains(first, "B") {
+		t.Fatalf("expected a mix of variants, got %s", first)
+	}
+
+	// Half of the leaked copy is enough to single out the session.
+	leaked := first[:32]
+	body := fmt.Sprintf(`{"startSequence":100,"variants":%q}`, leaked)
+	decodeURL := server.URL + "/admin/movies/watermark-movie/watermark/decode"
+	resp := adminRequest(t, http.MethodPost, decodeURL, body, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected decoding to require an admin, got %d", resp.StatusCode)
+	}
+
+	resp = adminRequest(t, http.MethodPost, decodeURL, body)
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if strings.Contains(string(raw), token) || strings.Contains(string(raw), otherToken) {
+		t.Fatalf("expected no playback tokens in the decoded matches, got %s", raw)
+	}
+
+	var decoded struct {
+		Matches []struct {
+			SessionID  string    `json:"sessionId"`
+			IssuedAt   time.Time `json:"issuedAt"`
+			Matched    int       `json:"matched"`
+			Mismatched int       `json:"mismatched"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(decoded.Matches) != 2 {
+		t.Fatalf("expected both sessions to be ranked, got %+v", decoded.Matches)
+	}
+	leaker, other := decoded.Matches[0], decoded.Matches[1]
+	if leaker.SessionID == "" || leaker.SessionID == other.SessionID || leaker.IssuedAt.IsZero() {
+		t.Fatalf("expected distinct sessions with their issue time, got %+v", decoded.Matches)
+	}
+	if leaker.Mismatched != 0 || leaker.Matched != 32 {
+		t.Fatalf("expected the leaking session first, got %+v", decoded.Matches)
+	}
+	if other.Mismatched == 0 {
+		t.Fatalf("expected the other session to mismatch, got %+v", decoded.Matches)
+	}
+
+	resp = adminRequest(t, http.MethodPost, decodeURL, `{"variants":"AXB"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an invalid sequence, got %d", resp.StatusCode)
+	}
+}
+
+// observeVariants plays the watermarked movie with token and returns the
+// variant of every segment, as a leak investigation would reconstruct it.
+func observeVariants(t *testing.T, baseURL, token string) string {
+	t.Helper()
+
+	master := getBody(t, baseURL+"/movies/watermark-movie/manifest.m3u8?token="+token)
+	media := getBody(t, baseURL+firstProxyLine(master))
+
+	var variants strings.Builder
+	for _, line := range strings.Split(media, "\n") {
+		if strings.HasPrefix(line, "/movies/") {
+			variants.WriteString(getBody(t, baseURL+line))
+		}
+	}
+	return variants.String()
+}
+
+func playbackToken(t *testing.T, baseURL, slug string) string {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/movies/"+slug+"/playback-token", "application/json", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to request playback token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.Token == "" {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+	return payload.Token
+}
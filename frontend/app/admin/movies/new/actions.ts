@@ -29,6 +29,11 @@ export async function createMovieAction(values: CreateMovieFormValues): Promise<
 		.map((value) => value.trim())
 		.filter((value) => value.length > 0);
 
+	const mirrorUrls = (data.mirrorUrls ?? '')
+		.split(/\r?\n/)
+		.map((value) => value.trim())
+		.filter((value) => value.length > 0);
+
 	const availabilityStartISO = data.availabilityStart ? new Date(data.availabilityStart).toISOString() : undefined;
 	const availabilityEndISO = data.availabilityEnd ? new Date(data.availabilityEnd).toISOString() : undefined;
 
@@ -40,13 +45,18 @@ export async function createMovieAction(values: CreateMovieFormValues): Promise<
 		availabilityEnd: availabilityEndISO,
 		isVisible: data.isVisible,
 		streamUrl: data.streamUrl,
+		mirrorUrls: mirrorUrls.length > 0 ? mirrorUrls : undefined,
 		drmKeyId: data.drmKeyId?.trim() ? data.drmKeyId.trim() : undefined,
+		contentKey: data.contentKey?.trim() ? data.contentKey.trim() : undefined,
+		encryptSegments: data.encryptSegments,
+		watermarkUrl: data.watermarkUrl?.trim() ? data.watermarkUrl.trim() : undefined,
 		allowedHosts,
 		captions: data.captions.map((caption) => ({
 			languageCode: caption.languageCode,
 			label: caption.label.trim(),
 			captionUrl: caption.captionUrl
-		}))
+		})),
+		tokenBinding: data.tokenBinding
 	};
 
 	const sanitizedPayload = Object.fromEntries(
@@ -76,7 +86,12 @@ export async function createMovieAction(values: CreateMovieFormValues): Promise<
 			if (raw && typeof raw === 'object') {
 				const data = raw as { error?: string; details?: Record<string, string> };
 				if (response.status === 422 && data.details) {
-					return { success: false, fieldErrors: data.details };
+					const fieldErrors: ActionErrorMap = {};
+					for (const [field, message] of Object.entries(data.details)) {
+						// Mirror URLs are edited as one textarea; errors come back per line.
+						fieldErrors[field.startsWith('mirrorUrls.') ? 'mirrorUrls' : field] = message;
+					}
+					return { success: false, fieldErrors };
 				}
 				return { success: false, formError: data.error ?? 'ไม่สามารถบันทึกข้อมูลได้' };
 			}
@@ -37,9 +37,14 @@ export function AdminNewMovieForm() {
 			availabilityEnd: '',
 			isVisible: true,
 			streamUrl: '',
+			mirrorUrls: '',
 			drmKeyId: '',
+			contentKey: '',
+			encryptSegments: false,
+			watermarkUrl: '',
 			allowedHosts: '',
-			captions: []
+			captions: [],
+			tokenBinding: { ipv4Prefix: 24, ipv6Prefix: 64, userAgent: false }
 		}
 	});
 
@@ -161,7 +166,7 @@ export function AdminNewMovieForm() {
 					name="streamUrl"
 					render={({ field }) => (
 						<FormItem>
-							<FormLabel>ลิงก์สตรีม (.m3u8) *</FormLabel>
+							<FormLabel>ลิงก์สตรีม (.m3u8 / .mpd) *</FormLabel>
 							<FormControl>
 								<Input placeholder="https://cdn.example.com/path/master.m3u8" {...field} />
 							</FormControl>
@@ -171,6 +176,36 @@ export function AdminNewMovieForm() {
 					)}
 				/>
 
+				<FormField
+					control={form.control}
+					name="mirrorUrls"
+					render={({ field }) => (
+						<FormItem>
+							<FormLabel>ลิงก์สตรีมสำรอง (ถ้ามี)</FormLabel>
+							<FormControl>
+								<Textarea rows={3} placeholder={['ตัวอย่าง:', 'https://backup.example.com/path/master.m3u8'].join('\n')} {...field} />
+							</FormControl>
+							<FormDescription>ใส่ 1 ลิงก์ต่อ 1 บรรทัด เรียงตามลำดับความสำคัญ ต้องมีโครงสร้างไฟล์เหมือนลิงก์หลัก ระบบจะสลับไปใช้เมื่อลิงก์หลักล่ม</FormDescription>
+							<FormMessage />
+						</FormItem>
+					)}
+				/>
+
+				<FormField
+					control={form.control}
+					name="watermarkUrl"
+					render={({ field }) => (
+						<FormItem>
+							<FormLabel>ลิงก์สตรีมลายน้ำ B (ถ้ามี)</FormLabel>
+							<FormControl>
+								<Input placeholder="https://cdn.example.com/movie-b/master.m3u8" {...field} />
+							</FormControl>
+							<FormDescription>สตรีมชุด B ที่มีโครงสร้างไฟล์เหมือนลิงก์หลัก ผู้ชมแต่ละคนจะได้รับเซกเมนต์ A/B สลับกันแบบเฉพาะตัวเพื่อติดตามการรั่วไหล</FormDescription>
+							<FormMessage />
+						</FormItem>
+					)}
+				/>
+
 				<FormField
 					control={form.control}
 					name="allowedHosts"
@@ -200,6 +235,103 @@ export function AdminNewMovieForm() {
 					)}
 				/>
 
+				<FormField
+					control={form.control}
+					name="contentKey"
+					render={({ field }) => (
+						<FormItem>
+							<FormLabel>AES-128 Content Key (ถ้ามี)</FormLabel>
+							<FormControl>
+								<Input placeholder="เลขฐานสิบหก 32 ตัว" autoComplete="off" {...field} />
+							</FormControl>
+							<FormDescription>คีย์ที่ใช้เข้ารหัสสตรีมนี้ หากเว้นว่างระบบจะสร้างคีย์ใหม่ให้ DRM Key ID ต้องไม่ซ้ำกับภาพยนตร์เรื่องอื่น</FormDescription>
+							<FormMessage />
+						</FormItem>
+					)}
+				/>
+
+				<FormField
+					control={form.control}
+					name="encryptSegments"
+					render={({ field }) => (
+						<FormItem className="flex items-center justify-between rounded-2xl border border-border/70 bg-background/60 px-4 py-3">
+							<div>
+								<FormLabel className="text-sm font-medium">เข้ารหัสเซกเมนต์ระหว่างสตรีม</FormLabel>
+								<FormDescription>เข้ารหัส AES-128 ให้สตรีม .m3u8 ที่ต้นทางไม่ได้เข้ารหัส โดยใช้คีย์ของ DRM Key ID ด้านบน</FormDescription>
+								<FormMessage />
+							</div>
+							<FormControl>
+								<input
+									type="checkbox"
+									className="size-5 rounded border border-border transition focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+									checked={field.value}
+									onChange={(event) => field.onChange(event.target.checked)}
+								/>
+							</FormControl>
+						</FormItem>
+					)}
+				/>
+
+				<div className="space-y-4">
+					<div>
+						<h3 className="text-base font-medium">ผูกโทเคนกับผู้ชม</h3>
+						<p className="text-sm text-muted-foreground">
+							โทเคนการเล่นใช้ได้เฉพาะจากเครือข่ายที่ขอโทเคน (ค่าเริ่มต้น /24 สำหรับ IPv4 และ /64 สำหรับ IPv6) และจำกัดเพิ่มเติมตามเบราว์เซอร์ได้ ใส่ 0 เพื่อไม่ผูกกับ IP
+						</p>
+					</div>
+					<div className="grid gap-4 md:grid-cols-2">
+						<FormField
+							control={form.control}
+							name="tokenBinding.ipv4Prefix"
+							render={({ field }) => (
+								<FormItem>
+									<FormLabel>IPv4 prefix</FormLabel>
+									<FormControl>
+										<Input type="number" min={0} max={32} inputMode="numeric" {...field} />
+									</FormControl>
+									<FormDescription>เช่น 24 เพื่อให้ใช้ได้ภายในเครือข่าย /24 เดียวกัน</FormDescription>
+									<FormMessage />
+								</FormItem>
+							)}
+						/>
+						<FormField
+							control={form.control}
+							name="tokenBinding.ipv6Prefix"
+							render={({ field }) => (
+								<FormItem>
+									<FormLabel>IPv6 prefix</FormLabel>
+									<FormControl>
+										<Input type="number" min={0} max={128} inputMode="numeric" {...field} />
+									</FormControl>
+									<FormDescription>เช่น 64 เพื่อให้ใช้ได้ภายในเครือข่าย /64 เดียวกัน</FormDescription>
+									<FormMessage />
+								</FormItem>
+							)}
+						/>
+					</div>
+					<FormField
+						control={form.control}
+						name="tokenBinding.userAgent"
+						render={({ field }) => (
+							<FormItem className="flex items-center justify-between rounded-2xl border border-border/70 bg-background/60 px-4 py-3">
+								<div>
+									<FormLabel className="text-sm font-medium">ผูกกับเบราว์เซอร์</FormLabel>
+									<FormDescription>ปฏิเสธโทเคนที่ถูกนำไปใช้จากเบราว์เซอร์ (User-Agent) อื่น</FormDescription>
+									<FormMessage />
+								</div>
+								<FormControl>
+									<input
+										type="checkbox"
+										className="size-5 rounded border border-border transition focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+										checked={field.value}
+										onChange={(event) => field.onChange(event.target.checked)}
+									/>
+								</FormControl>
+							</FormItem>
+						)}
+					/>
+				</div>
+
 				<div className="space-y-4">
 					<div className="flex items-center justify-between">
 						<div>
@@ -48,12 +48,56 @@ export const createMovieFormSchema = z
 			.string({ required_error: 'กรุณาระบุลิงก์สตรีม' })
 			.trim()
 			.url('ต้องเป็น URL แบบ http(s)')
-			.refine((value) => value.toLowerCase().includes('.m3u8'), {
-				message: 'ต้องเป็นลิงก์ไฟล์ .m3u8'
+			.refine((value) => /\.(m3u8|mpd)(\?|$)/i.test(value), {
+				message: 'ต้องเป็นลิงก์ไฟล์ .m3u8 หรือ .mpd'
 			}),
+		mirrorUrls: z
+			.string()
+			.trim()
+			.optional()
+			.refine(
+				(value) =>
+					!value ||
+					value
+						.split(/\r?\n/)
+						.map((line) => line.trim())
+						.filter((line) => line.length > 0)
+						.every((line) => /^https?:\/\/.+\.(m3u8|mpd)(\?|$)/i.test(line)),
+				{ message: 'ทุกบรรทัดต้องเป็นลิงก์ไฟล์ .m3u8 หรือ .mpd' }
+			),
 		drmKeyId: z.string().trim().optional(),
+		contentKey: z
+			.string()
+			.trim()
+			.optional()
+			.refine((value) => !value || /^[0-9a-f]{32}$/i.test(value), {
+				message: 'คีย์ต้องเป็นเลขฐานสิบหก 32 ตัว'
+			}),
+		encryptSegments: z.boolean().default(false),
+		watermarkUrl: z
+			.string()
+			.trim()
+			.optional()
+			.refine((value) => !value || /^https?:\/\/.+\.m3u8(\?|$)/i.test(value), {
+				message: 'ต้องเป็นลิงก์ไฟล์ .m3u8'
+			}),
 		allowedHosts: z.string({ required_error: 'กรุณาระบุ allowed hosts' }).trim().min(1, 'กรุณาระบุ allowed hosts อย่างน้อย 1 host'),
-		captions: z.array(captionInputSchema).default([])
+		captions: z.array(captionInputSchema).default([]),
+		tokenBinding: z
+			.object({
+				ipv4Prefix: z.coerce
+					.number({ invalid_type_error: 'กรุณาระบุตัวเลข' })
+					.int('กรุณาระบุจำนวนเต็ม')
+					.min(0, 'ต้องอยู่ระหว่าง 0 ถึง 32')
+					.max(32, 'ต้องอยู่ระหว่าง 0 ถึง 32'),
+				ipv6Prefix: z.coerce
+					.number({ invalid_type_error: 'กรุณาระบุตัวเลข' })
+					.int('กรุณาระบุจำนวนเต็ม')
+					.min(0, 'ต้องอยู่ระหว่าง 0 ถึง 128')
+					.max(128, 'ต้องอยู่ระหว่าง 0 ถึง 128'),
+				userAgent: z.boolean().default(false)
+			})
+			.default({ ipv4Prefix: 24, ipv6Prefix: 64, userAgent: false })
 	})
 	.superRefine((data, ctx) => {
 		if (data.availabilityStart && Number.isNaN(Date.parse(data.availabilityStart))) {
@@ -12,7 +12,7 @@ type PlayerProps = {
 
 export function MoviePlayer({ movie }: PlayerProps) {
   const videoRef = useRef<HTMLVideoElement>(null);
-  const { state, retry, activeCaption, selectCaption } = usePlayback({
+  const { state, retry, activeCaption, selectCaption, captionSource, withCurrentToken, keepSessionAlive } = usePlayback({
     slug: movie.slug,
     captions: movie.captions ?? []
   });
@@ -53,8 +53,20 @@ export function MoviePlayer({ movie }: PlayerProps) {
         return;
       }
 
-      const canPlayHLSNatively = video.canPlayType('application/vnd.apple.mpegurl');
-      if (canPlayHLSNatively) {
+      const { default: Hls } = await import('hls.js');
+      if (destroyed) {
+        return;
+      }
+
+      if (!Hls.isSupported()) {
+        // Native HLS requests cannot be rewritten: playlists keep the first
+        // token and playback stops once it expires, so this is only a
+        // fallback for browsers without MSE (iOS Safari). The token is not
+        // refreshed either, since a refresh would supersede it.
+        if (!video.canPlayType('application/vnd.apple.mpegurl')) {
+          setPlayerError('เบราว์เซอร์ไม่รองรับการเล่นสตรีม HLS');
+          return;
+        }
         video.src = state.src;
         video.load();
         try {
@@ -65,22 +77,18 @@ export function MoviePlayer({ movie }: PlayerProps) {
         return;
       }
 
-      const { default: Hls } = await import('hls.js');
-      if (destroyed) {
-        return;
-      }
-
-      if (!Hls.isSupported()) {
-        setPlayerError('เบราว์เซอร์ไม่รองรับการเล่นสตรีม HLS');
-        return;
-      }
-
       const instance = new Hls({
         enableWorker: true,
         lowLatencyMode: true,
-        backBufferLength: 90
+        backBufferLength: 90,
+        // Playlists loaded earlier carry the token they were requested with;
+        // the session refreshes it in the background.
+        xhrSetup: (xhr, url) => {
+          xhr.open('GET', withCurrentToken(url), true);
+        }
       });
       hlsInstance = instance;
+      keepSessionAlive();
 
       instance.attachMedia(video);
       instance.on(Hls.Events.MEDIA_ATTACHED, () => {
@@ -111,7 +119,7 @@ export function MoviePlayer({ movie }: PlayerProps) {
         video.load();
       }
     };
-  }, [state.status, state.src, state.token]);
+  }, [state.status, state.src, state.token, withCurrentToken, keepSessionAlive]);
 
   return (
     <div className="space-y-4">
@@ -155,6 +163,7 @@ export function MoviePlayer({ movie }: PlayerProps) {
               autoPlay
               playsInline
               preload="auto"
+              crossOrigin="anonymous"
               onError={() => {
                 setPlayerError('ไม่สามารถเล่นสตรีมได้');
               }}
@@ -165,7 +174,7 @@ export function MoviePlayer({ movie }: PlayerProps) {
                   label={caption.label}
                   kind="subtitles"
                   srcLang={caption.languageCode}
-                  src={caption.captionUrl}
+                  src={captionSource(caption)}
                   default={caption.languageCode === activeCaption}
                 />
               ))}
@@ -21,7 +21,10 @@ const urlOrPathSchema = z
 export const captionSchema = z.object({
   languageCode: z.string().min(2).max(10),
   label: z.string().min(1),
-  captionUrl: urlOrPathSchema
+  captionUrl: urlOrPathSchema,
+  // Proxied captions are served as WebVTT by the backend and need the
+  // playback token appended to captionUrl.
+  proxied: z.boolean().optional()
 });
 
 export const movieSummarySchema = z.object({
@@ -35,8 +38,34 @@ export const movieSummarySchema = z.object({
   isVisible: z.boolean()
 });
 
+export const streamMetadataSchema = z.object({
+  durationSeconds: z.number(),
+  renditions: z
+    .object({
+      bandwidth: z.number(),
+      resolution: z.string().optional(),
+      codecs: z.string().optional(),
+      audio: z.string().optional(),
+      subtitles: z.string().optional()
+    })
+    .array(),
+  media: z
+    .object({
+      type: z.string(),
+      groupId: z.string(),
+      name: z.string(),
+      language: z.string().optional(),
+      default: z.boolean()
+    })
+    .array(),
+  encryption: z.string(),
+  probedAt: z.string().datetime()
+});
+
 export const movieSchema = movieSummarySchema.extend({
-  captions: captionSchema.array().default([])
+  captions: captionSchema.array().default([]),
+  durationMinutes: z.number().int().optional(),
+  streamMetadata: streamMetadataSchema.optional()
 });
 
 export const createMoviePayloadSchema = z.object({
@@ -46,15 +75,19 @@ export const createMoviePayloadSchema = z.object({
   availabilityStart: z.string().datetime().optional(),
   availabilityEnd: z.string().datetime().optional(),
   isVisible: z.boolean().default(true),
-  streamUrl: z.string().url().endsWith('.m3u8', 'ต้องเป็นลิงก์ .m3u8'),
+  streamUrl: z.string().url().regex(/\.(m3u8|mpd)$/i, 'ต้องเป็นลิงก์ .m3u8 หรือ .mpd'),
+  mirrorUrls: z.array(z.string().url().regex(/\.(m3u8|mpd)$/i, 'ต้องเป็นลิงก์ .m3u8 หรือ .mpd')).optional(),
   drmKeyId: z.string().optional(),
+  contentKey: z.string().regex(/^[0-9a-f]{32}$/i).optional(),
+  encryptSegments: z.boolean().default(false),
+  watermarkUrl: z.string().url().regex(/\.m3u8$/i, 'ต้องเป็นลิงก์ .m3u8').optional(),
   allowedHosts: z.array(z.string()).default([]),
   captions: captionSchema.array().default([])
 });
 
 export const streamSchema = z.object({
   movieId: z.string(),
-  streamUrl: z.string().url().endsWith('.m3u8', 'ต้องเป็นลิงก์ .m3u8'),
+  streamUrl: z.string().url().regex(/\.(m3u8|mpd)$/i, 'ต้องเป็นลิงก์ .m3u8 หรือ .mpd'),
   drmKeyId: z.string().optional()
 });
 
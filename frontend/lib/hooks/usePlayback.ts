@@ -1,6 +1,6 @@
 'use client';
 
-import { useCallback, useEffect, useMemo, useState } from 'react';
+import { useCallback, useEffect, useMemo, useRef, useState } from 'react';
 import type { Caption } from '@/lib/api';
 
 export type PlaybackStatus = 'idle' | 'loading' | 'ready' | 'error';
@@ -12,6 +12,10 @@ type UsePlaybackArgs = {
 
 type PlaybackState = {
   status: PlaybackStatus;
+  // token is the first token of the playback session. Refreshed tokens are
+  // swapped into requests with withCurrentToken, so the player is not
+  // restarted every time the token is renewed. Tokens are only refreshed
+  // once the player calls keepSessionAlive.
   token?: string;
   src?: string;
   error?: string;
@@ -22,13 +26,93 @@ type UsePlaybackResult = {
   retry: () => Promise<void>;
   activeCaption: string | null;
   selectCaption: (languageCode: string | null) => void;
+  captionSource: (caption: Caption) => string;
+  withCurrentToken: (url: string) => string;
+  // keepSessionAlive starts refreshing the token in the background. Only
+  // players that send every request through withCurrentToken may call it: a
+  // refresh supersedes the previous token shortly after.
+  keepSessionAlive: () => void;
+};
+
+type TokenResponse = {
+  token?: string;
+  url?: string;
+  expiresAt?: string;
+  sessionExpiresAt?: string;
 };
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_BASE_URL ?? 'http://localhost:8080';
+const REFRESH_RETRY_MS = 15_000;
+const MIN_REFRESH_DELAY_MS = 5_000;
+
+// refreshDelay renews the token halfway through its lifetime, leaving room
+// for a failed heartbeat to be retried before it expires.
+function refreshDelay(expiresAt: string | undefined): number | null {
+  if (!expiresAt) {
+    return null;
+  }
+  const remaining = Date.parse(expiresAt) - Date.now();
+  if (Number.isNaN(remaining)) {
+    return null;
+  }
+  return Math.max(remaining / 2, MIN_REFRESH_DELAY_MS);
+}
 
 export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackResult {
   const [state, setState] = useState<PlaybackState>({ status: 'idle' });
   const [activeCaption, setActiveCaption] = useState<string | null>(captions[0]?.languageCode ?? null);
+  const currentToken = useRef<string | undefined>(undefined);
+  const refreshTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
+  const firstExpiry = useRef<string | undefined>(undefined);
+  const refreshing = useRef(false);
+
+  const cancelRefresh = useCallback(() => {
+    if (refreshTimer.current) {
+      clearTimeout(refreshTimer.current);
+      refreshTimer.current = null;
+    }
+  }, []);
+
+  const scheduleRefresh = useCallback(
+    (delay: number | null) => {
+      cancelRefresh();
+      if (delay === null) {
+        return;
+      }
+      refreshTimer.current = setTimeout(async () => {
+        refreshTimer.current = null;
+        try {
+          const response = await fetch(`${API_BASE_URL}/movies/${slug}/playback-token/refresh`, {
+            method: 'POST',
+            headers: {
+              'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ token: currentToken.current }),
+            cache: 'no-store'
+          });
+          if (response.status === 401 || response.status === 403) {
+            // session_expired, token_superseded, token_binding_mismatch or
+            // token_invalid: the session cannot be renewed, playback stops
+            // at the token expiry.
+            return;
+          }
+          if (!response.ok) {
+            throw new Error(`refresh failed (${response.status})`);
+          }
+          const json = (await response.json()) as TokenResponse;
+          if (json.token) {
+            currentToken.current = json.token;
+          }
+          scheduleRefresh(refreshDelay(json.expiresAt));
+        } catch {
+          scheduleRefresh(REFRESH_RETRY_MS);
+        }
+      }, delay);
+    },
+    [slug, cancelRefresh]
+  );
+
+  useEffect(() => cancelRefresh, [cancelRefresh]);
 
   const sourceBuilder = useCallback(
     (token: string | undefined, url: string | undefined) => {
@@ -45,6 +129,8 @@ export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackRes
   );
 
   const requestToken = useCallback(async () => {
+    cancelRefresh();
+    refreshing.current = false;
     setState({ status: 'loading' });
     try {
       const response = await fetch(`${API_BASE_URL}/movies/${slug}/playback-token`, {
@@ -60,7 +146,7 @@ export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackRes
         throw new Error(message || 'ไม่สามารถขอโทเคนสำหรับเล่นได้');
       }
 
-      const json = (await response.json()) as { token?: string; url?: string };
+      const json = (await response.json()) as TokenResponse;
       if (!json?.token && !json?.url) {
         throw new Error('ข้อมูลสตรีมไม่ถูกต้อง');
       }
@@ -70,6 +156,8 @@ export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackRes
         throw new Error('ไม่สามารถสร้าง URL สำหรับสตรีมได้');
       }
 
+      currentToken.current = json.token;
+      firstExpiry.current = json.expiresAt;
       setState({ status: 'ready', token: json.token, src });
     } catch (error) {
       setState({
@@ -77,7 +165,15 @@ export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackRes
         error: error instanceof Error ? error.message : 'เกิดข้อผิดพลาดไม่คาดคิด'
       });
     }
-  }, [slug, sourceBuilder]);
+  }, [slug, sourceBuilder, cancelRefresh]);
+
+  const keepSessionAlive = useCallback(() => {
+    if (refreshing.current || !currentToken.current) {
+      return;
+    }
+    refreshing.current = true;
+    scheduleRefresh(refreshDelay(firstExpiry.current));
+  }, [scheduleRefresh]);
 
   useEffect(() => {
     void requestToken();
@@ -91,13 +187,41 @@ export function usePlayback({ slug, captions }: UsePlaybackArgs): UsePlaybackRes
     setActiveCaption(languageCode);
   }, []);
 
+  const withCurrentToken = useCallback((url: string) => {
+    const token = currentToken.current;
+    if (!token || !url.startsWith(API_BASE_URL)) {
+      return url;
+    }
+    const parsed = new URL(url);
+    if (!parsed.searchParams.has('token')) {
+      return url;
+    }
+    parsed.searchParams.set('token', token);
+    return parsed.toString();
+  }, []);
+
+  const captionSource = useCallback(
+    (caption: Caption) => {
+      if (!caption.proxied) {
+        return caption.captionUrl;
+      }
+      // Read at render time, so tracks rendered after a refresh use the
+      // current token.
+      return `${API_BASE_URL}${caption.captionUrl}?token=${encodeURIComponent(currentToken.current ?? state.token ?? '')}`;
+    },
+    [state.token]
+  );
+
   return useMemo(
     () => ({
       state,
       retry,
       activeCaption,
-      selectCaption
+      selectCaption,
+      captionSource,
+      withCurrentToken,
+      keepSessionAlive
     }),
-    [state, retry, activeCaption, selectCaption]
+    [state, retry, activeCaption, selectCaption, captionSource, withCurrentToken, keepSessionAlive]
   );
 }